@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"eth-tx-parser/eth_parser"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (cli *CLI) HandleAddRule(args []string) {
+	args, flags := ParseFlags(args)
+	if len(args) != 1 {
+		cli.Usage("Usage: add_rule [name] [options]")
+		return
+	}
+	if cli.Alerts == nil {
+		cli.Fail("Alert rules are only evaluated by a local parser.")
+		return
+	}
+	rule, err := buildRule(args[0], flags)
+	if err != nil {
+		cli.Usage(err)
+		return
+	}
+	if err := cli.Alerts.AddRule(rule); err != nil {
+		cli.Fail("Failed to add rule:", err)
+		return
+	}
+	fmt.Fprintf(cli.output, "Added rule %s.\n", rule.Name)
+}
+
+// buildRule converts the options of add_rule to a rule.
+func buildRule(name string, flags map[string]string) (eth_parser.Rule, error) {
+	rule := eth_parser.Rule{Name: name}
+	list := func(value string) []string {
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ',' })
+	}
+	var err error
+	for option, value := range flags {
+		switch option {
+		case "address":
+			rule.Addresses = list(value)
+		case "direction":
+			rule.Direction, err = eth_parser.ParseDirection(value)
+		case "min-value":
+			rule.MinValue, err = eth_parser.ParseETHAmount(value)
+		case "max-value":
+			rule.MaxValue, err = eth_parser.ParseETHAmount(value)
+		case "counterparty":
+			rule.Counterparties = list(value)
+		case "exclude":
+			rule.ExcludeCounterparties = list(value)
+		case "new-counterparty":
+			rule.NewCounterparty = true
+		case "method":
+			rule.Methods = list(value)
+		case "rate":
+			rule.RateCount, rule.RateWindow, err = eth_parser.ParseRate(value)
+		case "cooldown":
+			rule.Cooldown, err = time.ParseDuration(value)
+		default:
+			err = fmt.Errorf("unknown option --%s", option)
+		}
+		if err != nil {
+			return rule, fmt.Errorf("invalid --%s: %w", option, err)
+		}
+	}
+	return rule, rule.Validate()
+}
+
+func (cli *CLI) HandleRemoveRule(args []string) {
+	if len(args) != 1 {
+		cli.Usage("Usage: remove_rule [name]")
+		return
+	}
+	if cli.Alerts == nil || !cli.Alerts.RemoveRule(args[0]) {
+		cli.Failf("There is no rule %s.\n", args[0])
+		return
+	}
+	fmt.Fprintf(cli.output, "Removed rule %s.\n", args[0])
+}
+
+func (cli *CLI) HandleRules(args []string) {
+	if len(args) != 0 {
+		cli.Usage("Usage: rules")
+		return
+	}
+	var rules []eth_parser.Rule
+	if cli.Alerts != nil {
+		rules = cli.Alerts.Rules()
+	}
+	if len(rules) == 0 {
+		fmt.Fprintln(cli.output, "There are no alert rules.")
+		return
+	}
+	fmt.Fprintf(cli.output, "Alert rules (%d):\n", len(rules))
+	for _, rule := range rules {
+		fmt.Fprintf(cli.output, "=> %s: %s\n", rule.Name, rule)
+	}
+}
+
+func (cli *CLI) HandleAlerts(args []string) {
+	if len(args) != 0 {
+		cli.Usage("Usage: alerts")
+		return
+	}
+	var alerts []eth_parser.Alert
+	if cli.Alerts != nil {
+		alerts = cli.Alerts.Recent()
+	}
+	if len(alerts) == 0 {
+		fmt.Fprintln(cli.output, "No alert was raised.")
+		return
+	}
+	fmt.Fprintf(cli.output, "Last alerts (%d):\n", len(alerts))
+	for _, alert := range alerts {
+		fmt.Fprintf(cli.output, "=> %s %s\n", alert.At.Format(time.RFC3339), alert)
+	}
+}
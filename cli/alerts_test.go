@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"eth-tx-parser/eth_parser"
+	"eth-tx-parser/eth_parser/test"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_CLI_AlertRules(t *testing.T) {
+	var outBuf, errBuf bytes.Buffer
+	cli := NewCLI(context.Background(), &test.ParserMock{})
+	cli.output = &outBuf
+	cli.errOutput = &errBuf
+	cli.Alerts = eth_parser.NewAlertEngine()
+
+	for _, command := range []string{
+		"rules",
+		"add_rule treasury-outflow --address=Treasury --direction=out --min-value=10",
+		"add_rule burst --rate=5/10m --method=transfer,approve",
+		"add_rule treasury-outflow",
+		"add_rule broken --rate=5",
+		"rules",
+		"remove_rule burst",
+		"remove_rule burst",
+		"alerts",
+	} {
+		cli.handleCommand(strings.Fields(command))
+	}
+
+	want := strings.Join([]string{
+		"There are no alert rules.",
+		"Added rule treasury-outflow.",
+		"Added rule burst.",
+		"Alert rules (2):",
+		"=> treasury-outflow: --address=Treasury --direction=outgoing --min-value=10",
+		"=> burst: --method=transfer,approve --rate=5/10m0s",
+		"Removed rule burst.",
+		"No alert was raised.",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, outBuf.String()); diff != "" {
+		t.Errorf("output mismatch (-want +got):\n%s", diff)
+	}
+	wantErr := strings.Join([]string{
+		"Failed to add rule: rule treasury-outflow already exists",
+		`invalid --rate: invalid rate "5", expected count/window such as 5/10m`,
+		"There is no rule burst.",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(wantErr, errBuf.String()); diff != "" {
+		t.Errorf("error output mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_CLI_HandleAlerts(t *testing.T) {
+	var outBuf bytes.Buffer
+	cli := NewCLI(context.Background(), &test.ParserMock{})
+	cli.output = &outBuf
+	cli.Alerts = eth_parser.NewAlertEngine()
+	cli.Alerts.AddRule(eth_parser.Rule{Name: "any"})
+	cli.Alerts.Evaluate(eth_parser.Transaction{Subscriber: "0x123", Hash: "hash1", From: "0xabc", To: "0x123", Value: "0x0", Timestamp: "0x65920080"}, nil)
+
+	cli.HandleAlerts(nil)
+
+	want := "Last alerts (1):\n=> 2024-01-01T00:00:00Z [any] incoming transfer of 0.00000000 ETH from 0xabc to 0x123, tx hash1\n"
+	if diff := cmp.Diff(want, outBuf.String()); diff != "" {
+		t.Errorf("HandleAlerts() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_CLI_AlertRules_Remote(t *testing.T) {
+	var outBuf bytes.Buffer
+	cli := NewCLI(context.Background(), &test.ParserMock{})
+	cli.output = &outBuf
+
+	cli.HandleAddRule([]string{"burst", "--rate=5/10m"})
+
+	if want := "Alert rules are only evaluated by a local parser.\n"; outBuf.String() != want {
+		t.Errorf("expected output to be %q, got %q", want, outBuf.String())
+	}
+}
@@ -7,140 +7,659 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type CLI struct {
-	ctx     context.Context
-	parser  eth_parser.Parser
-	scanner *bufio.Scanner
-	output  io.Writer // Adds flexibility to read the CLI output for testing purpouses
+	ctx         context.Context
+	parser      eth_parser.Parser
+	input       *bufio.Reader // Shared by the prompt and the commands reading lines, as each buffers ahead
+	output      io.Writer     // Adds flexibility to read the CLI output for testing purpouses
+	errOutput   io.Writer     // Failures go there when set, to output otherwise
+	interactive bool          // Reading commands from the prompt rather than running a single one
+	exitCode    int           // Of the last command
+	format      OutputFormat
+	columns     []string // Of the structured formats, the defaults when empty
+	commands    []Command
+	done        bool // An exit command was entered
+
+	// HistoryFile keeps the commands entered at the prompt across sessions,
+	// they only last for the session when empty
+	HistoryFile string
+
+	// Alerts evaluates the alert rules managed by the rule commands, which
+	// are unavailable when nil, e.g. against a remote parser
+	Alerts *eth_parser.AlertEngine
 }
 
-func NewCLI(ctx context.Context, p eth_parser.Parser) *CLI {
-	return &CLI{
-		ctx:     ctx,
-		parser:  p,
-		scanner: bufio.NewScanner(os.Stdin),
-		output:  os.Stdout,
+// NewCLI returns a CLI of the built-in commands and the given ones, which
+// can't reuse the name or alias of another command.
+func NewCLI(ctx context.Context, p eth_parser.Parser, commands ...Command) *CLI {
+	cli := &CLI{
+		ctx:    ctx,
+		parser: p,
+		input:  bufio.NewReader(os.Stdin),
+		output: os.Stdout,
+	}
+	for _, cmd := range append(builtinCommands, commands...) {
+		cli.register(cmd)
 	}
+	return cli
 }
 
 func (cli *CLI) Run() {
+	cli.interactive = true
 	fmt.Fprintln(cli.output, "\nEthereum Transaction Monitor CLI")
-	fmt.Fprintln(cli.output, "\nCommands:")
-	fmt.Fprintln(cli.output, "- subscribe [eth_address]: monitor transactions for a given Ethereum address.")
-	fmt.Fprintln(cli.output, "- get_txs [eth_address]: get all transactions stored for a given Ethereum address.")
-	fmt.Fprintln(cli.output, "- live [*|eth_address]: show live transactions for all or a specific subscribed Ethereum address.")
-	fmt.Fprintln(cli.output, "\nPress ENTER (without typing a command) at any time to exit.")
+	cli.printHelp()
+	fmt.Fprintln(cli.output, "\nType help [command] for the usage of a command, and exit or quit to leave.")
 
-	for {
+	reader := cli.newLineReader()
+	defer reader.Close()
+	for !cli.done {
 		if err := cli.ctx.Err(); err != nil {
 			break // Will break out of the loop if the context was cancelled
 		}
 
-		fmt.Print("\nEnter command: ")
-		if !cli.scanner.Scan() {
-			break // Exit on scan failure
-		}
-
-		line := cli.scanner.Text()
-		if line == "" {
-			break // Exit on empty line
+		line, err := reader.ReadLine("Enter command: ")
+		if err == io.EOF {
+			break // Exit at the end of the input, e.g. on Ctrl-D
+		} else if err != nil {
+			fmt.Fprintln(cli.output, "error reading from cli input:", err)
+			break
 		}
 
 		cli.handleCommand(strings.Fields(line))
 	}
 
-	if err := cli.scanner.Err(); err != nil {
-		fmt.Fprintln(cli.output, "error reading from cli input:", err)
-	}
-
 	cli.parser.Stop()
 }
 
 func (cli *CLI) handleCommand(parts []string) {
+	cli.exitCode = ExitOK
 	if len(parts) == 0 {
 		return
 	}
 
-	switch parts[0] {
-	case "subscribe":
-		cli.HandleSubscribe(parts[1:])
-	case "get_txs":
-		cli.HandleGetTxs(parts[1:])
-	case "live":
-		cli.HandleLive(parts[1:])
-	default:
-		fmt.Fprintln(cli.output, "Unknown command:", parts[0])
+	parts, chain := chainOption(parts)
+	if chain != "" {
+		target, ok := cli.chainParser(chain)
+		if !ok {
+			return
+		}
+		defer func(all eth_parser.Parser) { cli.parser = all }(cli.parser)
+		cli.parser = target
+	}
+
+	cmd, ok := cli.lookup(parts[0])
+	if !ok {
+		cli.Usage("Unknown command:", parts[0])
+		return
 	}
+	if err := cmd.check(parts[1:]); err != nil {
+		if err != errArgCount {
+			cli.Usage(err)
+		}
+		cli.Usage("Usage:", cmd.synopsis())
+		return
+	}
+	cmd.Run(cli, parts[1:])
 }
 
 func (cli *CLI) HandleSubscribe(args []string) {
+	args, flags := ParseFlags(args)
 	if len(args) != 1 {
-		fmt.Fprintln(cli.output, "Usage: subscribe [eth_address]")
+		cli.Usage("Usage: subscribe [eth_address] [--label=name] [--tags=a,b] [--start-block=n]")
 		return
 	}
 	address := args[0]
-	if cli.parser.Subscribe(address) {
+	sub := eth_parser.Subscription{Address: address, Label: flags["label"]}
+	if tags := flags["tags"]; tags != "" {
+		sub.Tags = strings.Split(tags, ",")
+	}
+	if startBlock := flags["start-block"]; startBlock != "" {
+		num, err := strconv.ParseUint(startBlock, 10, 64)
+		if err != nil {
+			cli.Usage("Invalid start block:", startBlock)
+			return
+		}
+		sub.StartBlock = num
+	}
+	if cli.parser.AddSubscription(sub) {
 		fmt.Fprintf(cli.output, "Subscribed to %s.\n", address)
 	} else {
-		fmt.Fprintln(cli.output, "Invalid address format or already subscribed to address.")
+		cli.Fail("Invalid address format or already subscribed to address.")
 	}
 }
 
-func (cli *CLI) HandleGetTxs(args []string) {
+func (cli *CLI) HandleSubscribeFile(args []string) {
+	if len(args) != 1 {
+		cli.Usage("Usage: subscribe_file [path]")
+		return
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		cli.Fail("Failed to open address file:", err)
+		return
+	}
+	defer file.Close()
+
+	subs, err := eth_parser.ReadAddressList(file)
+	if err != nil {
+		cli.Fail("Failed to read address file:", err)
+		return
+	}
+	added := cli.parser.SubscribeMany(subs)
+	fmt.Fprintf(cli.output, "Subscribed to %d of %d addresses, the others were invalid or already subscribed.\n", added, len(subs))
+}
+
+func (cli *CLI) HandleImportSubs(args []string) {
+	args, flags := ParseFlags(args)
 	if len(args) != 1 {
-		fmt.Fprintln(cli.output, "Usage: get_txs [eth_address]")
+		cli.Usage("Usage: import_subs [path] [--format=csv|json]")
+		return
+	}
+	cli.importFile(args[0], flags["format"], "subscriptions", eth_parser.ImportSubscriptions)
+}
+
+func (cli *CLI) HandleExportSubs(args []string) {
+	args, flags := ParseFlags(args)
+	if len(args) != 1 {
+		cli.Usage("Usage: export_subs [path] [--format=csv|json]")
+		return
+	}
+	path := args[0]
+	err := writeFile(path, func(w io.Writer) error {
+		return eth_parser.ExportSubscriptions(cli.parser, w, fileFormat(path, flags["format"]))
+	})
+	if err != nil {
+		cli.Fail("Failed to export subscriptions:", err)
+		return
+	}
+	fmt.Fprintf(cli.output, "Exported the subscriptions to %s.\n", path)
+}
+
+func (cli *CLI) HandleUnsubscribe(args []string) {
+	args, flags := ParseFlags(args)
+	if len(args) != 1 {
+		cli.Usage("Usage: unsubscribe [eth_address] [--purge]")
 		return
 	}
 	address := args[0]
-	transactions := cli.parser.GetTransactions(address)
-	if len(transactions) == 0 {
+	_, purge := flags["purge"]
+	if !cli.parser.Unsubscribe(address, purge) {
+		cli.Failf("You are not subscribed to %s.\n", address)
+	} else if purge {
+		fmt.Fprintf(cli.output, "Unsubscribed from %s and removed its transactions.\n", address)
+	} else {
+		fmt.Fprintf(cli.output, "Unsubscribed from %s.\n", address)
+	}
+}
+
+func (cli *CLI) HandleList(args []string) {
+	if len(args) != 0 {
+		cli.Usage("Usage: list")
+		return
+	}
+	subs := cli.parser.ListSubscriptions()
+	if len(subs) == 0 {
+		fmt.Fprintln(cli.output, "You are not subscribed to any address.")
+		return
+	}
+	fmt.Fprintf(cli.output, "Subscribed addresses (%d):\n", len(subs))
+	for _, sub := range subs {
+		fmt.Fprintf(cli.output, "=> %s", sub.Address)
+		if sub.Label != "" {
+			fmt.Fprintf(cli.output, " (%s)", sub.Label)
+		}
+		fmt.Fprint(cli.output, cli.chainSuffix(sub.Chain))
+		fmt.Fprintf(cli.output, "\n   Since block %d, created at %s\n", sub.StartBlock, sub.CreatedAt.Format(time.RFC3339))
+		if len(sub.Tags) > 0 {
+			fmt.Fprintf(cli.output, "   Tags: %s\n", strings.Join(sub.Tags, ", "))
+		}
+	}
+}
+
+const getTxsUsage = "Usage: get_txs [eth_address|label] [--from-block=n] [--to-block=n] [--since=time] [--until=time] [--direction=in|out|self] [--min-value=eth] [--order=asc|desc] [--limit=n] [--cursor=c] [--label=text] [--format=text|table|json|jsonl|csv] [--columns=a,b]"
+
+func (cli *CLI) HandleGetTxs(args []string) {
+	args, flags := ParseFlags(args)
+	if len(args) != 1 {
+		cli.Usage(getTxsUsage)
+		return
+	}
+	address, err := cli.resolveAddress(args[0])
+	if err != nil {
+		cli.Usage(err)
+		return
+	}
+	tw, err := cli.newTxWriter(flags, false)
+	if err != nil {
+		cli.Usage(err)
+		return
+	}
+	var counterparties []string
+	if text, ok := flags["label"]; ok {
+		if counterparties, err = cli.labelledLike(text); err != nil {
+			cli.Usage(err)
+			return
+		}
+		delete(flags, "label")
+	}
+	q, err := buildTxQuery(address, flags)
+	if err != nil {
+		cli.Usage(err)
+		cli.Usage(getTxsUsage)
+		return
+	}
+	q.Counterparties = counterparties
+	page, err := cli.parser.QueryTransactions(q)
+	if err != nil {
+		cli.Fail("Failed to get transactions:", err)
+		return
+	}
+
+	// Structured output is left to the transactions, notes go to the errors
+	if tw.structured() {
+		for _, tx := range page.Transactions {
+			tw.Write(tx)
+		}
+		if err := tw.Close(); err != nil {
+			cli.Fail("Failed to print transactions:", err)
+			return
+		}
+		if page.NextCursor != "" {
+			fmt.Fprintf(cli.errWriter(), "More transactions available, continue with --cursor=%s\n", page.NextCursor)
+		}
+		return
+	}
+	if len(page.Transactions) == 0 {
 		fmt.Fprintf(cli.output, "There are still no transactions for %s or you are not subscribed to it.\n", address)
 	} else {
 		fmt.Fprintf(cli.output, "Transactions for %s:\n", address)
-		for _, tx := range transactions {
+		for _, tx := range page.Transactions {
 			cli.printTx(tx)
 		}
 	}
+	if page.NextCursor != "" {
+		fmt.Fprintf(cli.output, "More transactions available, continue with --cursor=%s\n", page.NextCursor)
+	}
+}
+
+// buildTxQuery translates the get_txs flags into a query. Times are accepted
+// as RFC3339 timestamps or as YYYY-MM-DD dates.
+func buildTxQuery(address string, flags map[string]string) (eth_parser.TxQuery, error) {
+	q := eth_parser.TxQuery{Address: address, Cursor: flags["cursor"]}
+	var err error
+	for name, value := range flags {
+		switch name {
+		case "from-block":
+			q.FromBlock, err = strconv.ParseUint(value, 10, 64)
+		case "to-block":
+			q.ToBlock, err = strconv.ParseUint(value, 10, 64)
+		case "since":
+			q.FromTime, err = parseTime(value)
+		case "until":
+			q.ToTime, err = parseTime(value)
+		case "direction":
+			q.Direction, err = eth_parser.ParseDirection(value)
+		case "min-value":
+			q.MinValue, err = eth_parser.ParseETHAmount(value)
+		case "order":
+			q.Order = eth_parser.SortOrder(value)
+		case "limit":
+			q.Limit, err = strconv.Atoi(value)
+		case "cursor":
+		default:
+			err = fmt.Errorf("unknown option --%s", name)
+		}
+		if err != nil {
+			return q, fmt.Errorf("invalid --%s: %w", name, err)
+		}
+	}
+	return q, q.Validate()
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func (cli *CLI) HandleImportTxs(args []string) {
+	args, flags := ParseFlags(args)
+	if len(args) != 1 {
+		cli.Usage("Usage: import_txs [path] [--format=csv|jsonl]")
+		return
+	}
+	cli.importFile(args[0], flags["format"], "transactions", eth_parser.ImportTransactions)
+}
+
+func (cli *CLI) HandleExportTxs(args []string) {
+	args, flags := ParseFlags(args)
+	if len(args) != 2 {
+		cli.Usage("Usage: export_txs [*|eth_address] [path] [--format=csv|jsonl]")
+		return
+	}
+	address, path := args[0], args[1]
+	if address == "*" {
+		address = ""
+	}
+	var written int
+	err := writeFile(path, func(w io.Writer) (err error) {
+		written, err = eth_parser.ExportTransactions(cli.parser, w, address, fileFormat(path, flags["format"]))
+		return err
+	})
+	if err != nil {
+		cli.Fail("Failed to export transactions:", err)
+		return
+	}
+	fmt.Fprintf(cli.output, "Exported %d transactions to %s.\n", written, path)
+}
+
+type importFunc func(p eth_parser.Parser, r io.Reader, format eth_parser.Format) (eth_parser.ImportReport, error)
+
+func (cli *CLI) importFile(path, format, what string, importer importFunc) {
+	file, err := os.Open(path)
+	if err != nil {
+		cli.Failf("Failed to open %s file: %v\n", what, err)
+		return
+	}
+	defer file.Close()
+
+	report, err := importer(cli.parser, file, fileFormat(path, format))
+	if err != nil {
+		cli.Failf("Failed to import %s: %v\n", what, err)
+		return
+	}
+	fmt.Fprintf(cli.output, "Imported %d of %d %s.\n", report.Imported, report.Read, what)
+	if len(report.Rejected) > 0 {
+		fmt.Fprintf(cli.output, "Rejected entries (%d):\n", len(report.Rejected))
+		for _, rejected := range report.Rejected {
+			fmt.Fprintf(cli.output, "=> Line %d: %s\n", rejected.Line, rejected.Reason)
+		}
+	}
+}
+
+// fileFormat picks the --format flag, or guesses it from the file extension.
+func fileFormat(path, flag string) eth_parser.Format {
+	if flag != "" {
+		return eth_parser.Format(flag)
+	}
+	return eth_parser.FormatOf(path)
+}
+
+// writeFile creates the file at path with the output of write, and removes
+// it again when writing fails.
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
 }
 
 func (cli *CLI) HandleLive(args []string) {
+	args, flags := ParseFlags(args)
 	if len(args) != 1 {
-		fmt.Fprintln(cli.output, "Usage: live [*|eth_address]")
+		cli.Usage("Usage: live [*|eth_address|label] [--direction=in|out|self] [--label=text] [--format=text|table|json|jsonl|csv] [--columns=a,b]")
+		return
+	}
+	filter, err := cli.resolveAddress(args[0])
+	if err != nil {
+		cli.Usage(err)
+		return
+	}
+	direction, err := eth_parser.ParseDirection(flags["direction"])
+	if err != nil {
+		cli.Usage(err)
+		return
+	}
+	var counterparties []string
+	if text, ok := flags["label"]; ok {
+		if counterparties, err = cli.labelledLike(text); err != nil {
+			cli.Usage(err)
+			return
+		}
+		delete(flags, "label")
+	}
+	tw, err := cli.newTxWriter(flags, true)
+	if err != nil {
+		cli.Usage(err)
 		return
 	}
-	filter := args[0]
 
 	fmt.Fprintln(cli.output, "Starting live transaction monitoring... Press ENTER to leave this mode.")
 	ctx, cancel := context.WithCancel(cli.ctx)
+	feed, stopListening := cli.listen()
+	defer stopListening()
 
 	go func() {
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case tx, ok := <-cli.parser.Listen():
+			case tx, ok := <-feed:
 				if !ok {
 					return
 				}
-				if filter == "*" || filter == tx.From || filter == tx.To {
-					cli.printTx(tx)
+				// Each subscriber party gets its own event, so matching on it
+				// prints a transfer between two subscribers only once per side
+				if (filter == "*" || strings.EqualFold(filter, tx.Subscriber)) && tx.MatchesDirection(direction) && tx.HasCounterparty(counterparties) {
+					tw.Write(tx)
 				}
 			}
 		}
 	}()
 
-	cli.scanner.Scan()
+	cli.readLine()
 	cancel()
 	fmt.Fprintln(cli.output, "Stopped live transaction monitoring.")
 }
 
+func (cli *CLI) HandleBalance(args []string) {
+	if len(args) != 1 {
+		cli.Usage("Usage: balance [eth_address]")
+		return
+	}
+	report, err := cli.parser.Balance(args[0])
+	if err != nil {
+		cli.Fail("Failed to get balance:", err)
+		return
+	}
+
+	chain := eth_parser.ChainOf(report.Chain)
+	fmt.Fprintf(cli.output, "Balance of %s at block %d:\n", report.Address, report.Block)
+	fmt.Fprintf(cli.output, "   Tracked: %s (since block %d)\n", chain.FormatAmount(report.Tracked), report.AnchorBlock)
+	fmt.Fprintf(cli.output, "   Reported: %s\n", chain.FormatAmount(report.Reported))
+	if discrepancy := report.Discrepancy(); discrepancy.Sign() != 0 {
+		fmt.Fprintf(cli.output, "   Discrepancy: %s, transfers missing from the stored ones such as internal transfers or withdrawals\n", chain.FormatAmount(discrepancy))
+	}
+	if report.MissingFees > 0 {
+		fmt.Fprintf(cli.output, "   Warning: %d sent transfers have no receipt, their fees are missing from the tracked balance\n", report.MissingFees)
+	}
+}
+
+func (cli *CLI) HandleChains(args []string) {
+	if len(args) != 0 {
+		cli.Usage("Usage: chains")
+		return
+	}
+	router, ok := cli.parser.(eth_parser.ChainRouter)
+	if !ok {
+		fmt.Fprintf(cli.output, "Following a single chain, at block %d.\n", cli.parser.GetCurrentBlock())
+		return
+	}
+	chains := router.Chains()
+	fmt.Fprintf(cli.output, "Chains (%d):\n", len(chains))
+	for _, chain := range chains {
+		parser, _ := router.Chain(chain.Name)
+		fmt.Fprintf(cli.output, "=> %s: at block %d, native currency %s\n", chain, parser.GetCurrentBlock(), chain.NativeSymbol)
+	}
+}
+
+// chainOption splits the --chain option, which any command takes, from the
+// command line.
+func chainOption(parts []string) ([]string, string) {
+	rest := make([]string, 0, len(parts))
+	chain := ""
+	for _, part := range parts {
+		if value, found := strings.CutPrefix(part, "--chain="); found {
+			chain = value
+			continue
+		}
+		rest = append(rest, part)
+	}
+	return rest, chain
+}
+
+// chainParser returns the parser of a single chain of a multi-chain parser,
+// telling the user when there is none.
+func (cli *CLI) chainParser(chain string) (eth_parser.Parser, bool) {
+	router, ok := cli.parser.(eth_parser.ChainRouter)
+	if !ok {
+		cli.Usage("The parser follows a single chain, --chain can't be used.")
+		return nil, false
+	}
+	parser, ok := router.Chain(chain)
+	if !ok {
+		cli.Usagef("Unknown chain %s, see the chains command.\n", chain)
+	}
+	return parser, ok
+}
+
+// chainSuffix names the chain of a record when following several chains.
+func (cli *CLI) chainSuffix(chainID uint64) string {
+	router, ok := cli.parser.(eth_parser.ChainRouter)
+	if !ok || len(router.Chains()) < 2 {
+		return ""
+	}
+	return " on " + eth_parser.ChainOf(chainID).Name
+}
+
 func (cli *CLI) printTx(tx eth_parser.Transaction) {
-	fmt.Fprintf(cli.output, "=> Transaction for address [%s]:\n", tx.Subscriber)
-	fmt.Fprintf(cli.output, "   Hash: %s\n", tx.Hash)
-	fmt.Fprintf(cli.output, "   From: %s\n", tx.From)
-	fmt.Fprintf(cli.output, "   To: %s\n", tx.To)
-	fmt.Fprintf(cli.output, "   Amount: %s ETH\n\n", tx.ETHAmount())
+	switch tx.EventKind() {
+	case eth_parser.KindContractEvent:
+		fmt.Fprintf(cli.output, "=> Event for contract [%s]%s:\n", tx.Subscriber, cli.chainSuffix(tx.Chain))
+		fmt.Fprintf(cli.output, "   Hash: %s\n", tx.Hash)
+		if tx.Log != nil && len(tx.Log.Topics) > 0 {
+			fmt.Fprintf(cli.output, "   Topic: %s\n", tx.Log.Topics[0])
+			fmt.Fprintf(cli.output, "   Data: %s\n", tx.Log.Data)
+		}
+		fmt.Fprintln(cli.output)
+	case eth_parser.KindTokenTransfer:
+		fmt.Fprintf(cli.output, "=> Token transfer for address [%s]%s:\n", withLabel(tx.Subscriber, tx.SubscriberLabel()), cli.chainSuffix(tx.Chain))
+		fmt.Fprintf(cli.output, "   Hash: %s\n", tx.Hash)
+		fmt.Fprintf(cli.output, "   From: %s\n", withLabel(tx.From, tx.FromLabel))
+		fmt.Fprintf(cli.output, "   To: %s\n", withLabel(tx.To, tx.ToLabel))
+		fmt.Fprintf(cli.output, "   Direction: %s\n", tx.TxDirection())
+		if tx.Log != nil {
+			fmt.Fprintf(cli.output, "   Token: %s\n", tx.Log.Address)
+		}
+		fmt.Fprintf(cli.output, "   Amount: %s (token base units)\n\n", tx.ValueWei())
+	default:
+		fmt.Fprintf(cli.output, "=> Transaction for address [%s]%s:\n", withLabel(tx.Subscriber, tx.SubscriberLabel()), cli.chainSuffix(tx.Chain))
+		fmt.Fprintf(cli.output, "   Hash: %s\n", tx.Hash)
+		fmt.Fprintf(cli.output, "   From: %s\n", withLabel(tx.From, tx.FromLabel))
+		fmt.Fprintf(cli.output, "   To: %s\n", withLabel(tx.To, tx.ToLabel))
+		fmt.Fprintf(cli.output, "   Direction: %s\n", tx.TxDirection())
+		switch tx.Status {
+		case eth_parser.StatusPending, eth_parser.StatusDropped:
+			fmt.Fprintf(cli.output, "   Status: %s\n", tx.Status)
+		case eth_parser.StatusReplaced:
+			fmt.Fprintf(cli.output, "   Status: replaced by %s\n", tx.ReplacedBy)
+		}
+		if tx.Call != nil {
+			fmt.Fprintf(cli.output, "   Call: %s\n", tx.Call)
+		}
+		if tx.SenderCheck == eth_parser.SenderMismatch {
+			fmt.Fprintln(cli.output, "   Warning: the signature doesn't match the sender reported by the node")
+		}
+		if len(tx.Fiat) > 0 {
+			fmt.Fprintf(cli.output, "   Amount: %s (%s)\n\n", tx.ETHAmount(), tx.Fiat)
+		} else {
+			fmt.Fprintf(cli.output, "   Amount: %s\n\n", tx.ETHAmount())
+		}
+	}
+}
+
+// listen returns a live feed of the parser's records, of its own when the
+// parser broadcasts them, so a gRPC server of the same parser doesn't take
+// some of them.
+func (cli *CLI) listen() (<-chan eth_parser.Transaction, func()) {
+	if b, ok := cli.parser.(eth_parser.Broadcaster); ok {
+		return b.NewListener()
+	}
+	return cli.parser.Listen(), func() {}
+}
+
+// Fail reports a command that failed, a non-interactive run then exits with
+// ExitFailure.
+func (cli *CLI) Fail(a ...interface{}) {
+	fmt.Fprintln(cli.errWriter(), a...)
+	cli.exitCode = ExitFailure
+}
+
+func (cli *CLI) Failf(format string, a ...interface{}) {
+	fmt.Fprintf(cli.errWriter(), format, a...)
+	cli.exitCode = ExitFailure
+}
+
+// Usage reports a malformed command, a non-interactive run then exits with
+// ExitUsage.
+func (cli *CLI) Usage(a ...interface{}) {
+	fmt.Fprintln(cli.errWriter(), a...)
+	cli.exitCode = ExitUsage
+}
+
+func (cli *CLI) Usagef(format string, a ...interface{}) {
+	fmt.Fprintf(cli.errWriter(), format, a...)
+	cli.exitCode = ExitUsage
+}
+
+// Output of the command results, for commands registered by other packages.
+func (cli *CLI) Output() io.Writer {
+	return cli.output
+}
+
+// Parser the command runs against, the parser of a single chain when it is
+// given --chain.
+func (cli *CLI) Parser() eth_parser.Parser {
+	return cli.parser
+}
+
+// Context of the session, done once the CLI is interrupted.
+func (cli *CLI) Context() context.Context {
+	return cli.ctx
+}
+
+func (cli *CLI) errWriter() io.Writer {
+	if cli.errOutput != nil {
+		return cli.errOutput
+	}
+	return cli.output
+}
+
+// ParseFlags splits "--name=value" and "--name" arguments from the positional ones.
+func ParseFlags(args []string) ([]string, map[string]string) {
+	positional := []string{}
+	flags := make(map[string]string)
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		flags[name] = value
+	}
+	return positional, flags
 }
@@ -4,9 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"eth-tx-parser/eth_parser"
 	"eth-tx-parser/eth_parser/test"
+	"math/big"
+	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -51,6 +56,318 @@ func Test_CLI_HandleSubscribe(t *testing.T) {
 	}
 }
 
+func Test_CLI_HandleSubscribeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "addresses.txt")
+	os.WriteFile(path, []byte("# Deposit addresses\n0x123\n0x456\n\n0x789\n"), 0o644)
+
+	var outBuf bytes.Buffer
+	parserMock := &test.ParserMock{ReturnSubscribeMany: 2}
+
+	cli := NewCLI(context.Background(), parserMock)
+	cli.output = &outBuf
+
+	cli.HandleSubscribeFile([]string{path})
+
+	want := "Subscribed to 2 of 3 addresses, the others were invalid or already subscribed.\n"
+	if outBuf.String() != want {
+		t.Errorf("expected output to be %q, got %q", want, outBuf.String())
+	}
+	if len(parserMock.LastSubscribeMany) != 3 || parserMock.LastSubscribeMany[2].Address != "0x789" {
+		t.Errorf("SubscribeMany() received %+v", parserMock.LastSubscribeMany)
+	}
+}
+
+func Test_CLI_HandleImportSubs(t *testing.T) {
+	valid := "0x" + strings.Repeat("a", 40)
+	path := filepath.Join(t.TempDir(), "subs.csv")
+	os.WriteFile(path, []byte("address,label\n"+valid+",Treasury\n0x123,Broken\n"), 0o644)
+
+	var outBuf bytes.Buffer
+	parserMock := &test.ParserMock{ReturnSubscribeMany: 1}
+
+	cli := NewCLI(context.Background(), parserMock)
+	cli.output = &outBuf
+
+	cli.HandleImportSubs([]string{path})
+
+	want := strings.Join([]string{
+		"Imported 1 of 2 subscriptions.",
+		"Rejected entries (1):",
+		`=> Line 3: invalid address "0x123"`,
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, outBuf.String()); diff != "" {
+		t.Errorf("HandleImportSubs() mismatch (-want +got):\n%s", diff)
+	}
+	if len(parserMock.LastSubscribeMany) != 1 || parserMock.LastSubscribeMany[0].Label != "Treasury" {
+		t.Errorf("SubscribeMany() received %+v", parserMock.LastSubscribeMany)
+	}
+}
+
+func Test_CLI_HandleExportTxs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	var outBuf bytes.Buffer
+	parserMock := &test.ParserMock{
+		ReturnGetTransactions: []eth_parser.Transaction{{Subscriber: "0x123", Hash: "0xabc"}, {Subscriber: "0x123", Hash: "0xdef"}},
+	}
+
+	cli := NewCLI(context.Background(), parserMock)
+	cli.output = &outBuf
+
+	cli.HandleExportTxs([]string{"0x123", path})
+
+	want := "Exported 2 transactions to " + path + ".\n"
+	if outBuf.String() != want {
+		t.Errorf("expected output to be %q, got %q", want, outBuf.String())
+	}
+	if parserMock.LastQuery.Address != "0x123" {
+		t.Errorf("QueryTransactions() received %+v", parserMock.LastQuery)
+	}
+	exported, _ := os.ReadFile(path)
+	if lines := strings.Count(string(exported), "\n"); lines != 2 {
+		t.Errorf("expected 2 JSON lines, got %d:\n%s", lines, exported)
+	}
+
+	outBuf.Reset()
+	cli.HandleExportTxs([]string{"0x123", path, "--format=xml"})
+	if want := "Failed to export transactions: unsupported transaction format \"xml\"\n"; outBuf.String() != want {
+		t.Errorf("expected output to be %q, got %q", want, outBuf.String())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the failed export to be removed, got %v", err)
+	}
+}
+
+func Test_CLI_HandleUnsubscribe(t *testing.T) {
+	tt := []struct {
+		name              string
+		args              []string
+		returnUnsubscribe bool
+		expected          string
+	}{
+		{
+			name:              "Unsubscribe successful",
+			args:              []string{"0x123"},
+			returnUnsubscribe: true,
+			expected:          "Unsubscribed from 0x123.\n",
+		},
+		{
+			name:              "Unsubscribe and purge",
+			args:              []string{"0x123", "--purge"},
+			returnUnsubscribe: true,
+			expected:          "Unsubscribed from 0x123 and removed its transactions.\n",
+		},
+		{
+			name:              "Not subscribed",
+			args:              []string{"0x123"},
+			returnUnsubscribe: false,
+			expected:          "You are not subscribed to 0x123.\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf bytes.Buffer
+			parserMock := &test.ParserMock{
+				ReturnUnsubscribe: tc.returnUnsubscribe,
+			}
+
+			cli := NewCLI(context.Background(), parserMock)
+			cli.output = &outBuf
+
+			cli.HandleUnsubscribe(tc.args)
+
+			if outBuf.String() != tc.expected {
+				t.Errorf("expected output to be %q, got %q", tc.expected, outBuf.String())
+			}
+		})
+	}
+}
+
+func Test_CLI_HandleList(t *testing.T) {
+	var outBuf bytes.Buffer
+	parserMock := &test.ParserMock{
+		ReturnListSubscriptions: []eth_parser.Subscription{
+			{Address: "0x123", Label: "Treasury", StartBlock: 10, CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Tags: []string{"cold", "eth"}},
+			{Address: "0x456", StartBlock: 20, CreatedAt: time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)},
+		},
+	}
+
+	cli := NewCLI(context.Background(), parserMock)
+	cli.output = &outBuf
+
+	cli.HandleList(nil)
+
+	want := strings.Join([]string{
+		"Subscribed addresses (2):",
+		"=> 0x123 (Treasury)",
+		"   Since block 10, created at 2024-01-02T03:04:05Z",
+		"   Tags: cold, eth",
+		"=> 0x456",
+		"   Since block 20, created at 2024-02-03T04:05:06Z",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, outBuf.String()); diff != "" {
+		t.Errorf("HandleList() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_CLI_HandleBalance(t *testing.T) {
+	eth := func(amount string) *big.Int {
+		wei, _ := eth_parser.ParseETHAmount(amount)
+		return wei
+	}
+	tt := []struct {
+		name     string
+		report   eth_parser.BalanceReport
+		err      error
+		expected []string
+	}{
+		{
+			name:   "Balances match",
+			report: eth_parser.BalanceReport{Address: "0x123", Block: 120, AnchorBlock: 100, Tracked: eth("1.5"), Reported: eth("1.5")},
+			expected: []string{
+				"Balance of 0x123 at block 120:",
+				"   Tracked: 1.50000000 ETH (since block 100)",
+				"   Reported: 1.50000000 ETH",
+				"",
+			},
+		},
+		{
+			name:   "Discrepancy and missing fees",
+			report: eth_parser.BalanceReport{Address: "0x123", Block: 120, AnchorBlock: 100, Tracked: eth("1.5"), Reported: eth("1.25"), MissingFees: 2},
+			expected: []string{
+				"Balance of 0x123 at block 120:",
+				"   Tracked: 1.50000000 ETH (since block 100)",
+				"   Reported: 1.25000000 ETH",
+				"   Discrepancy: -0.25000000 ETH, transfers missing from the stored ones such as internal transfers or withdrawals",
+				"   Warning: 2 sent transfers have no receipt, their fees are missing from the tracked balance",
+				"",
+			},
+		},
+		{
+			name:     "Not subscribed",
+			err:      errors.New("not subscribed to 0x123"),
+			expected: []string{"Failed to get balance: not subscribed to 0x123", ""},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf bytes.Buffer
+			cli := NewCLI(context.Background(), &test.ParserMock{ReturnBalance: tc.report, ReturnBalanceErr: tc.err})
+			cli.output = &outBuf
+
+			cli.HandleBalance([]string{"0x123"})
+
+			if diff := cmp.Diff(strings.Join(tc.expected, "\n"), outBuf.String()); diff != "" {
+				t.Errorf("HandleBalance() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_CLI_ChainOption(t *testing.T) {
+	router := &test.ChainRouterMock{
+		ParserMock: test.ParserMock{
+			ReturnGetTransactions: []eth_parser.Transaction{
+				{Subscriber: "0x123", Hash: "hash1", From: "0xabc", To: "0x123", Value: "0xde0b6b3a7640000", Chain: eth_parser.Mainnet.ChainID},
+				{Subscriber: "0x123", Hash: "hash2", From: "0xabc", To: "0x123", Value: "0xde0b6b3a7640000", Chain: eth_parser.Polygon.ChainID},
+			},
+		},
+		ReturnChains: []eth_parser.ChainConfig{eth_parser.Mainnet, eth_parser.Polygon},
+		ChainParsers: map[uint64]*test.ParserMock{
+			eth_parser.Mainnet.ChainID: {ReturnGetCurrentBlock: 19000000},
+			eth_parser.Polygon.ChainID: {ReturnGetCurrentBlock: 55000000, ReturnBalance: eth_parser.BalanceReport{
+				Address: "0x123", Block: 55000000, AnchorBlock: 54000000, Tracked: big.NewInt(2e18), Reported: big.NewInt(2e18), Chain: eth_parser.Polygon.ChainID,
+			}},
+		},
+	}
+
+	tt := []struct {
+		name     string
+		parser   eth_parser.Parser
+		command  string
+		expected []string
+	}{
+		{
+			name:    "Chains",
+			parser:  router,
+			command: "chains",
+			expected: []string{
+				"Chains (2):",
+				"=> mainnet (chain 1): at block 19000000, native currency ETH",
+				"=> polygon (chain 137): at block 55000000, native currency POL",
+				"",
+			},
+		},
+		{
+			name:    "Targeted chain",
+			parser:  router,
+			command: "balance --chain=polygon 0x123",
+			expected: []string{
+				"Balance of 0x123 at block 55000000:",
+				"   Tracked: 2.00000000 POL (since block 54000000)",
+				"   Reported: 2.00000000 POL",
+				"",
+			},
+		},
+		{
+			name:    "Every chain",
+			parser:  router,
+			command: "get_txs 0x123",
+			expected: []string{
+				"Transactions for 0x123:",
+				"=> Transaction for address [0x123] on mainnet:",
+				"   Hash: hash1",
+				"   From: 0xabc",
+				"   To: 0x123",
+				"   Direction: incoming",
+				"   Amount: 1.00000000 ETH",
+				"",
+				"=> Transaction for address [0x123] on polygon:",
+				"   Hash: hash2",
+				"   From: 0xabc",
+				"   To: 0x123",
+				"   Direction: incoming",
+				"   Amount: 1.00000000 POL",
+				"",
+				"",
+			},
+		},
+		{
+			name:     "Unknown chain",
+			parser:   router,
+			command:  "list --chain=optimism",
+			expected: []string{"Unknown chain optimism, see the chains command.", ""},
+		},
+		{
+			name:     "Single chain",
+			parser:   &test.ParserMock{ReturnGetCurrentBlock: 19000000},
+			command:  "list --chain=polygon",
+			expected: []string{"The parser follows a single chain, --chain can't be used.", ""},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf bytes.Buffer
+			cli := NewCLI(context.Background(), tc.parser)
+			cli.output = &outBuf
+
+			cli.handleCommand(strings.Fields(tc.command))
+
+			if diff := cmp.Diff(strings.Join(tc.expected, "\n"), outBuf.String()); diff != "" {
+				t.Errorf("handleCommand(%q) mismatch (-want +got):\n%s", tc.command, diff)
+			}
+			if cli.parser != tc.parser {
+				t.Error("the targeted chain outlived the command")
+			}
+		})
+	}
+}
+
 func Test_CLI_HandleGetTxs(t *testing.T) {
 	tt := []struct {
 		name             string
@@ -71,12 +388,14 @@ func Test_CLI_HandleGetTxs(t *testing.T) {
 				"   Hash: hash1",
 				"   From: 0x123",
 				"   To: 0xdef",
+				"   Direction: outgoing",
 				"   Amount: 100.00000000 ETH",
 				"",
 				"=> Transaction for address [0x123]:",
 				"   Hash: hash2",
 				"   From: 0xabc",
 				"   To: 0x123",
+				"   Direction: incoming",
 				"   Amount: 200.00000000 ETH",
 				"",
 				"",
@@ -112,6 +431,175 @@ func Test_CLI_HandleGetTxs(t *testing.T) {
 	}
 }
 
+// getTxsOutput returns what HandleGetTxs prints for the given transactions
+// of 0x123.
+func getTxsOutput(t *testing.T, txs []eth_parser.Transaction) string {
+	t.Helper()
+
+	var outBuf bytes.Buffer
+	cli := NewCLI(context.Background(), &test.ParserMock{ReturnGetTransactions: txs})
+	cli.output = &outBuf
+
+	cli.HandleGetTxs([]string{"0x123"})
+	return outBuf.String()
+}
+
+func Test_CLI_HandleGetTxsCall(t *testing.T) {
+	txs := []eth_parser.Transaction{
+		{Subscriber: "0x123", Hash: "hash1", From: "0x123", To: "0xc0ffee", Value: "0x0", Call: &eth_parser.Call{
+			Selector: "0x095ea7b3",
+			Method:   "approve",
+			Args:     []eth_parser.CallArg{{Name: "spender", Type: "address", Value: "0xabc"}, {Name: "value", Type: "uint256", Value: "10"}},
+		}},
+		{Subscriber: "0x123", Hash: "hash2", From: "0x123", To: "0xc0ffee", Value: "0x0", Call: &eth_parser.Call{Selector: "0xdeadbeef"}},
+	}
+	expected := []string{
+		"Transactions for 0x123:",
+		"=> Transaction for address [0x123]:",
+		"   Hash: hash1",
+		"   From: 0x123",
+		"   To: 0xc0ffee",
+		"   Direction: outgoing",
+		"   Call: approve(spender=0xabc, value=10)",
+		"   Amount: 0.00000000 ETH",
+		"",
+		"=> Transaction for address [0x123]:",
+		"   Hash: hash2",
+		"   From: 0x123",
+		"   To: 0xc0ffee",
+		"   Direction: outgoing",
+		"   Call: unknown method 0xdeadbeef",
+		"   Amount: 0.00000000 ETH",
+		"",
+		"",
+	}
+
+	if diff := cmp.Diff(strings.Join(expected, "\n"), getTxsOutput(t, txs)); diff != "" {
+		t.Errorf("HandleGetTxs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_CLI_HandleGetTxsSenderCheck(t *testing.T) {
+	txs := []eth_parser.Transaction{
+		{Subscriber: "0x123", Hash: "hash1", From: "0x123", To: "0xdef", Value: "0x0", SenderCheck: eth_parser.SenderMismatch},
+	}
+	expected := []string{
+		"Transactions for 0x123:",
+		"=> Transaction for address [0x123]:",
+		"   Hash: hash1",
+		"   From: 0x123",
+		"   To: 0xdef",
+		"   Direction: outgoing",
+		"   Warning: the signature doesn't match the sender reported by the node",
+		"   Amount: 0.00000000 ETH",
+		"",
+		"",
+	}
+
+	if diff := cmp.Diff(strings.Join(expected, "\n"), getTxsOutput(t, txs)); diff != "" {
+		t.Errorf("HandleGetTxs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_CLI_HandleGetTxsFiat(t *testing.T) {
+	txs := []eth_parser.Transaction{
+		{Subscriber: "0x123", Hash: "hash1", From: "0xabc", To: "0x123", Value: "0xad78ebc5ac6200000", Fiat: eth_parser.FiatValues{"USD": "500000.00", "EUR": "460000.00"}},
+	}
+	expected := []string{
+		"Transactions for 0x123:",
+		"=> Transaction for address [0x123]:",
+		"   Hash: hash1",
+		"   From: 0xabc",
+		"   To: 0x123",
+		"   Direction: incoming",
+		"   Amount: 200.00000000 ETH (460000.00 EUR, 500000.00 USD)",
+		"",
+		"",
+	}
+
+	if diff := cmp.Diff(strings.Join(expected, "\n"), getTxsOutput(t, txs)); diff != "" {
+		t.Errorf("HandleGetTxs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_CLI_HandleGetTxsStatus(t *testing.T) {
+	txs := []eth_parser.Transaction{
+		{Subscriber: "0x123", Hash: "hash1", From: "0x123", To: "0xdef", Value: "0x0", Status: eth_parser.StatusPending},
+		{Subscriber: "0x123", Hash: "hash2", From: "0x123", To: "0xdef", Value: "0x0", Status: eth_parser.StatusReplaced, ReplacedBy: "hash3"},
+	}
+	expected := []string{
+		"Transactions for 0x123:",
+		"=> Transaction for address [0x123]:",
+		"   Hash: hash1",
+		"   From: 0x123",
+		"   To: 0xdef",
+		"   Direction: outgoing",
+		"   Status: pending",
+		"   Amount: 0.00000000 ETH",
+		"",
+		"=> Transaction for address [0x123]:",
+		"   Hash: hash2",
+		"   From: 0x123",
+		"   To: 0xdef",
+		"   Direction: outgoing",
+		"   Status: replaced by hash3",
+		"   Amount: 0.00000000 ETH",
+		"",
+		"",
+	}
+
+	if diff := cmp.Diff(strings.Join(expected, "\n"), getTxsOutput(t, txs)); diff != "" {
+		t.Errorf("HandleGetTxs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_CLI_HandleGetTxsOptions(t *testing.T) {
+	t.Run("Options are passed to the query", func(t *testing.T) {
+		var outBuf bytes.Buffer
+		parserMock := &test.ParserMock{
+			ReturnGetTransactions: []eth_parser.Transaction{
+				{Subscriber: "0x123", Hash: "hash1", From: "0x123", To: "0xdef", Value: "0x56bc75e2d63100000"},
+			},
+			ReturnNextCursor: "next",
+		}
+
+		cli := NewCLI(context.Background(), parserMock)
+		cli.output = &outBuf
+
+		cli.HandleGetTxs([]string{"0x123", "--from-block=10", "--to-block=20", "--since=2024-01-01", "--direction=out", "--min-value=1.5", "--order=desc", "--limit=1"})
+
+		want := eth_parser.TxQuery{
+			Address:   "0x123",
+			FromBlock: 10,
+			ToBlock:   20,
+			FromTime:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Direction: eth_parser.DirectionOutgoing,
+			MinValue:  big.NewInt(1500000000000000000),
+			Order:     eth_parser.OrderDescending,
+			Limit:     1,
+		}
+		if diff := cmp.Diff(want, parserMock.LastQuery, cmp.Comparer(func(a, b *big.Int) bool { return a.Cmp(b) == 0 })); diff != "" {
+			t.Errorf("query mismatch (-want +got):\n%s", diff)
+		}
+		if !strings.HasSuffix(outBuf.String(), "More transactions available, continue with --cursor=next\n") {
+			t.Errorf("expected a continuation hint, got %q", outBuf.String())
+		}
+	})
+
+	t.Run("Invalid option", func(t *testing.T) {
+		var outBuf bytes.Buffer
+		cli := NewCLI(context.Background(), &test.ParserMock{})
+		cli.output = &outBuf
+
+		cli.HandleGetTxs([]string{"0x123", "--direction=sideways"})
+
+		want := "invalid --direction: invalid direction \"sideways\"\n" + getTxsUsage + "\n"
+		if outBuf.String() != want {
+			t.Errorf("expected output to be %q, got %q", want, outBuf.String())
+		}
+	})
+}
+
 func Test_CLI_HandleLive(t *testing.T) {
 	t.Skip("Skipping due to flaky behavior")
 	testCases := []struct {
@@ -133,12 +621,14 @@ func Test_CLI_HandleLive(t *testing.T) {
 				"   Hash: hash1",
 				"   From: 0x123",
 				"   To: 0xdef",
+				"   Direction: outgoing",
 				"   Amount: 100.00000000 ETH",
 				"",
 				"=> Transaction for address [0x456]:",
 				"   Hash: hash2",
 				"   From: 0xabc",
 				"   To: 0x456",
+				"   Direction: incoming",
 				"   Amount: 200.00000000 ETH",
 				"",
 				"Stopped live transaction monitoring.",
@@ -158,6 +648,7 @@ func Test_CLI_HandleLive(t *testing.T) {
 				"   Hash: hash1",
 				"   From: 0x123",
 				"   To: 0xdef",
+				"   Direction: outgoing",
 				"   Amount: 100.00000000 ETH",
 				"",
 				"Stopped live transaction monitoring.",
@@ -170,7 +661,7 @@ func Test_CLI_HandleLive(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			var outBuf bytes.Buffer
 			inputBuf := new(bytes.Buffer)
-			scanner := bufio.NewScanner(inputBuf)
+			input := bufio.NewReader(inputBuf)
 
 			listenChan := make(chan eth_parser.Transaction, len(tc.transactions))
 			parserMock := &test.ParserMock{
@@ -181,7 +672,7 @@ func Test_CLI_HandleLive(t *testing.T) {
 			defer cancel()
 
 			cli := NewCLI(ctx, parserMock)
-			cli.scanner = scanner
+			cli.input = input
 			cli.output = &outBuf
 
 			go cli.HandleLive([]string{tc.filter})
@@ -207,3 +698,229 @@ func Test_CLI_HandleLive(t *testing.T) {
 		})
 	}
 }
+
+func Test_CLI_Exec(t *testing.T) {
+	closedListen := func(txs ...eth_parser.Transaction) chan eth_parser.Transaction {
+		ch := make(chan eth_parser.Transaction, len(txs))
+		for _, tx := range txs {
+			ch <- tx
+		}
+		close(ch)
+		return ch
+	}
+
+	tt := []struct {
+		name        string
+		args        []string
+		parser      *test.ParserMock
+		interrupted bool
+		cause       error // Of the interruption, a plain cancellation when nil
+		wantCode    int
+		wantOut     string
+		wantErr     string
+	}{
+		{
+			name:     "Dashed command",
+			args:     []string{"get-txs", "0x123"},
+			parser:   &test.ParserMock{},
+			wantCode: ExitOK,
+			wantOut:  "There are still no transactions for 0x123 or you are not subscribed to it.\n",
+		},
+		{
+			name:     "Command failure",
+			args:     []string{"get_txs", "0x123"},
+			parser:   &test.ParserMock{ReturnQueryErr: errors.New("node down")},
+			wantCode: ExitFailure,
+			wantErr:  "Failed to get transactions: node down\n",
+		},
+		{
+			name:     "Malformed command",
+			args:     []string{"get-txs"},
+			parser:   &test.ParserMock{},
+			wantCode: ExitUsage,
+			wantErr:  getTxsUsage + "\n",
+		},
+		{
+			name:     "Unknown command",
+			args:     []string{"follow", "0x123"},
+			parser:   &test.ParserMock{},
+			wantCode: ExitUsage,
+			wantErr:  "Unknown command: follow\n",
+		},
+		{
+			name:     "Live",
+			args:     []string{"live", "*"},
+			parser:   &test.ParserMock{},
+			wantCode: ExitUsage,
+			wantErr:  "live needs the interactive prompt.\n",
+		},
+		{
+			name:     "Watch until a processed block",
+			args:     []string{"watch", "0x123", "--until-block=5"},
+			parser:   &test.ParserMock{ReturnGetCurrentBlock: 5, ReturnSubscribe: true},
+			wantCode: ExitOK,
+		},
+		{
+			name:     "Watch an invalid address",
+			args:     []string{"watch", "0x12", "--until-block=5"},
+			parser:   &test.ParserMock{},
+			wantCode: ExitFailure,
+			wantErr:  "Invalid address format: 0x12\n",
+		},
+		{
+			name: "Watch until the parser stops",
+			args: []string{"watch", "0x123", "--until-block=10", "--direction=in"},
+			parser: &test.ParserMock{
+				ReturnGetCurrentBlock:   5,
+				ReturnListSubscriptions: []eth_parser.Subscription{{Address: "0x123"}},
+				ReturnListen: closedListen(
+					eth_parser.Transaction{Subscriber: "0x123", Hash: "hash1", From: "0xabc", To: "0x123", Value: "0x0"},
+					eth_parser.Transaction{Subscriber: "0x123", Hash: "hash2", From: "0x123", To: "0xabc", Value: "0x0"}, // Outgoing
+					eth_parser.Transaction{Subscriber: "0x456", Hash: "hash3", From: "0xabc", To: "0x456", Value: "0x0"}, // Other address
+				),
+			},
+			wantCode: ExitFailure,
+			wantOut:  "=> Transaction for address [0x123]:\n   Hash: hash1\n   From: 0xabc\n   To: 0x123\n   Direction: incoming\n   Amount: 0.00000000 ETH\n\n",
+			wantErr:  "The parser stopped.\n",
+		},
+		{
+			name:        "Interrupted watch",
+			args:        []string{"watch", "0x123"},
+			parser:      &test.ParserMock{ReturnSubscribe: true},
+			interrupted: true,
+			wantCode:    ExitInterrupted,
+		},
+		{
+			name:        "Watch terminated",
+			args:        []string{"watch", "0x123"},
+			parser:      &test.ParserMock{ReturnSubscribe: true},
+			interrupted: true,
+			cause:       SignalError{Signal: syscall.SIGTERM},
+			wantCode:    143,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf, errBuf bytes.Buffer
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			if tc.interrupted {
+				cancel(tc.cause)
+			}
+			cli := NewCLI(ctx, tc.parser)
+			cli.output = &outBuf
+			cli.errOutput = &errBuf
+
+			if code := cli.Exec(tc.args); code != tc.wantCode {
+				t.Errorf("Exec() = %d, want %d", code, tc.wantCode)
+			}
+			if diff := cmp.Diff(tc.wantOut, outBuf.String()); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantErr, errBuf.String()); diff != "" {
+				t.Errorf("error output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_CLI_OutputFormats(t *testing.T) {
+	txs := []eth_parser.Transaction{
+		{Subscriber: "0x123", Hash: "hash1", BlockNumber: "0x10", From: "0x123", To: "0xdef", Value: "0xde0b6b3a7640000"},
+		{Subscriber: "0x123", Hash: "hash2", BlockNumber: "0x11", From: "0xabc", To: "0x123", Value: "0x0", Status: eth_parser.StatusPending},
+	}
+
+	tt := []struct {
+		name    string
+		session string // Format command run first
+		command string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "Table",
+			command: "get_txs 0x123 --format=table --columns=block_number,hash,direction,amount",
+			want: strings.Join([]string{
+				"block_number  hash                                                                direction  amount",
+				"16            hash1                                                               outgoing   1.00000000 ETH",
+				"17            hash2                                                               incoming   0.00000000 ETH",
+				"",
+			}, "\n"),
+		},
+		{
+			name:    "CSV with the default columns",
+			command: "get_txs 0x123 --format=csv",
+			want:    "block_number,hash,direction,from,to,amount,status\n16,hash1,outgoing,0x123,0xdef,1.00000000 ETH,\n17,hash2,incoming,0xabc,0x123,0.00000000 ETH,pending\n",
+		},
+		{
+			name:    "JSON with columns",
+			command: "get_txs 0x123 --format=json --columns=hash,status",
+			want:    "[\n  {\n    \"hash\": \"hash1\",\n    \"status\": \"\"\n  },\n  {\n    \"hash\": \"hash2\",\n    \"status\": \"pending\"\n  }\n]\n",
+		},
+		{
+			name:    "JSON Lines of the session",
+			session: "format jsonl --columns=hash,from",
+			command: "get_txs 0x123",
+			want:    "{\"hash\":\"hash1\",\"from\":\"0x123\"}\n{\"hash\":\"hash2\",\"from\":\"0xabc\"}\n",
+		},
+		{
+			name:    "Command over session",
+			session: "format json",
+			command: "get_txs 0x123 --format=csv --columns=hash",
+			want:    "hash\nhash1\nhash2\n",
+		},
+		{
+			name:    "Invalid format",
+			command: "get_txs 0x123 --format=xml",
+			wantErr: "invalid format \"xml\", expected one of text, table, json, jsonl or csv\n",
+		},
+		{
+			name:    "Unknown column",
+			command: "get_txs 0x123 --format=csv --columns=hash,gas",
+			wantErr: "unknown column \"gas\", expected some of " + columnNames() + "\n",
+		},
+		{
+			name:    "Columns of the text format",
+			command: "get_txs 0x123 --columns=hash",
+			wantErr: "--columns only applies to the table, json, jsonl and csv formats\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf, errBuf bytes.Buffer
+			cli := NewCLI(context.Background(), &test.ParserMock{ReturnGetTransactions: txs})
+			cli.output = &outBuf
+			cli.errOutput = &errBuf
+
+			if tc.session != "" {
+				cli.handleCommand(strings.Fields(tc.session))
+				outBuf.Reset()
+			}
+			cli.handleCommand(strings.Fields(tc.command))
+
+			if diff := cmp.Diff(tc.want, outBuf.String()); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantErr, errBuf.String()); diff != "" {
+				t.Errorf("error output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_CLI_OutputFormats_Cursor(t *testing.T) {
+	var outBuf, errBuf bytes.Buffer
+	cli := NewCLI(context.Background(), &test.ParserMock{ReturnNextCursor: "abc"})
+	cli.output = &outBuf
+	cli.errOutput = &errBuf
+
+	cli.handleCommand([]string{"get_txs", "0x123", "--format=json", "--limit=10"})
+	if outBuf.String() != "[]\n" {
+		t.Errorf("expected an empty JSON array, got %q", outBuf.String())
+	}
+	if want := "More transactions available, continue with --cursor=abc\n"; errBuf.String() != want {
+		t.Errorf("expected the cursor on the error output, got %q", errBuf.String())
+	}
+}
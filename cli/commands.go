@@ -0,0 +1,384 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Command of the CLI. The arguments and flags it declares are checked before
+// it runs and make up its usage, its handler still validating their values.
+type Command struct {
+	Name        string
+	Aliases     []string // Other names of the command, e.g. quit for exit
+	Args        []Arg    // Positional arguments, in order
+	Flags       []Flag   // --name=value options, in any order
+	Summary     string   // Listed by help, which leaves out commands without one
+	Interactive bool     // Only runs at the prompt, not through Exec
+
+	// Run handles the command with its arguments, flags included, which
+	// ParseFlags splits. Results go to cli.Output, and cli.Fail and cli.Usage
+	// report errors.
+	Run func(cli *CLI, args []string)
+}
+
+// Arg is a positional argument of a command.
+type Arg struct {
+	Name     string // Shown in the usage, e.g. eth_address
+	Optional bool   // Only the last arguments can be optional
+	Variadic bool   // Takes the remaining arguments, only the last one can
+}
+
+// Flag is a --name=value option of a command.
+type Flag struct {
+	Name     string // Without the dashes
+	Value    string // Shown in the usage, e.g. n or asc|desc. Empty for flags like --purge that take none
+	Required bool
+	Usage    string
+}
+
+// Flags shared by the commands printing transactions
+var (
+	directionFlag = Flag{Name: "direction", Value: "in|out|self", Usage: "only transactions in this direction from the address"}
+	formatFlag    = Flag{Name: "format", Value: "text|table|json|jsonl|csv", Usage: "format of the transactions, the session format otherwise"}
+	columnsFlag   = Flag{Name: "columns", Value: "a,b", Usage: "columns of the table, json, jsonl and csv formats: " + columnNames()}
+	labelFlag     = Flag{Name: "label", Value: "text", Usage: "only transactions from or to an address whose label contains the text"}
+)
+
+var builtinCommands = []Command{
+	{
+		Name:    "subscribe",
+		Args:    []Arg{{Name: "eth_address"}},
+		Flags:   []Flag{{Name: "label", Value: "name", Usage: "label shown next to the address"}, {Name: "tags", Value: "a,b", Usage: "tags of the subscription"}, {Name: "start-block", Value: "n", Usage: "first block to record transactions from"}},
+		Summary: "monitor transactions for a given Ethereum address.",
+		Run:     (*CLI).HandleSubscribe,
+	},
+	{
+		Name:    "subscribe_file",
+		Args:    []Arg{{Name: "path"}},
+		Summary: "monitor every address listed in a file, one per line.",
+		Run:     (*CLI).HandleSubscribeFile,
+	},
+	{
+		Name:    "import_subs",
+		Args:    []Arg{{Name: "path"}},
+		Flags:   []Flag{{Name: "format", Value: "csv|json", Usage: "format of the file, told by its extension otherwise"}},
+		Summary: "subscribe to the addresses of a subscription export, reporting rejected entries.",
+		Run:     (*CLI).HandleImportSubs,
+	},
+	{
+		Name:    "export_subs",
+		Args:    []Arg{{Name: "path"}},
+		Flags:   []Flag{{Name: "format", Value: "csv|json", Usage: "format of the file, told by its extension otherwise"}},
+		Summary: "save the subscriptions with their labels, tags and start blocks.",
+		Run:     (*CLI).HandleExportSubs,
+	},
+	{
+		Name:    "unsubscribe",
+		Args:    []Arg{{Name: "eth_address"}},
+		Flags:   []Flag{{Name: "purge", Usage: "also drop the stored transactions of the address"}},
+		Summary: "stop monitoring an address.",
+		Run:     (*CLI).HandleUnsubscribe,
+	},
+	{
+		Name:    "list",
+		Summary: "list the monitored addresses.",
+		Run:     (*CLI).HandleList,
+	},
+	{
+		Name: "get_txs",
+		Args: []Arg{{Name: "eth_address|label"}},
+		Flags: []Flag{
+			{Name: "from-block", Value: "n", Usage: "first block of the transactions"},
+			{Name: "to-block", Value: "n", Usage: "last block of the transactions"},
+			{Name: "since", Value: "time", Usage: "earliest time of the transactions, as an RFC3339 timestamp or a YYYY-MM-DD date"},
+			{Name: "until", Value: "time", Usage: "latest time of the transactions"},
+			directionFlag,
+			{Name: "min-value", Value: "eth", Usage: "smallest value of the transactions"},
+			{Name: "order", Value: "asc|desc", Usage: "order of the transactions by block"},
+			{Name: "limit", Value: "n", Usage: "transactions per page"},
+			{Name: "cursor", Value: "c", Usage: "continue from the previous page"},
+			labelFlag,
+			formatFlag,
+			columnsFlag,
+		},
+		Summary: "get the transactions stored for a given Ethereum address, or the address of a label.",
+		Run:     (*CLI).HandleGetTxs,
+	},
+	{
+		Name:    "import_txs",
+		Args:    []Arg{{Name: "path"}},
+		Flags:   []Flag{{Name: "format", Value: "csv|jsonl", Usage: "format of the file, told by its extension otherwise"}},
+		Summary: "load a transaction history export of subscribed addresses.",
+		Run:     (*CLI).HandleImportTxs,
+	},
+	{
+		Name:    "export_txs",
+		Args:    []Arg{{Name: "*|eth_address"}, {Name: "path"}},
+		Flags:   []Flag{{Name: "format", Value: "csv|jsonl", Usage: "format of the file, told by its extension otherwise"}},
+		Summary: "save the transactions stored for all or a specific subscribed address.",
+		Run:     (*CLI).HandleExportTxs,
+	},
+	{
+		Name:    "balance",
+		Args:    []Arg{{Name: "eth_address"}},
+		Summary: "compare the balance tracked from the stored transfers of a subscribed address with the one the node reports.",
+		Run:     (*CLI).HandleBalance,
+	},
+	{
+		Name:        "live",
+		Args:        []Arg{{Name: "*|eth_address|label"}},
+		Flags:       []Flag{directionFlag, labelFlag, formatFlag, columnsFlag},
+		Summary:     "show live transactions for all or a specific subscribed Ethereum address.",
+		Interactive: true,
+		Run:         (*CLI).HandleLive,
+	},
+	{
+		Name:    "label",
+		Args:    []Arg{{Name: "eth_address"}, {Name: "label", Variadic: true}},
+		Summary: "name an address in the address book, subscribed or not, shown next to it in transactions.",
+		Run:     (*CLI).HandleLabel,
+	},
+	{
+		Name:    "unlabel",
+		Args:    []Arg{{Name: "eth_address"}},
+		Summary: "remove an address from the address book.",
+		Run:     (*CLI).HandleUnlabel,
+	},
+	{
+		Name:    "labels",
+		Summary: "list the address book.",
+		Run:     (*CLI).HandleLabels,
+	},
+	{
+		Name: "add_rule",
+		Args: []Arg{{Name: "name"}},
+		Flags: []Flag{
+			{Name: "address", Value: "a,b", Usage: "subscribers the rule applies to, by address or label"},
+			directionFlag,
+			{Name: "min-value", Value: "eth", Usage: "smallest value of the native transfers"},
+			{Name: "max-value", Value: "eth", Usage: "largest value of the native transfers"},
+			{Name: "counterparty", Value: "a,b", Usage: "only these counterparties, by address or label"},
+			{Name: "exclude", Value: "a,b", Usage: "all counterparties but these, by address or label"},
+			{Name: "new-counterparty", Usage: "only counterparties the subscriber never transacted with before"},
+			{Name: "method", Value: "a,b", Usage: "only calls of these methods, by name or selector"},
+			{Name: "rate", Value: "n/window", Usage: "only alert once more than n transactions matched within the window, e.g. 5/10m"},
+			{Name: "cooldown", Value: "duration", Usage: "time during which repeated alerts of the rule are suppressed"},
+		},
+		Summary: "raise alerts on the recorded transactions meeting all the given conditions.",
+		Run:     (*CLI).HandleAddRule,
+	},
+	{
+		Name:    "remove_rule",
+		Args:    []Arg{{Name: "name"}},
+		Summary: "stop alerting on a rule.",
+		Run:     (*CLI).HandleRemoveRule,
+	},
+	{
+		Name:    "rules",
+		Summary: "list the alert rules.",
+		Run:     (*CLI).HandleRules,
+	},
+	{
+		Name:    "alerts",
+		Summary: "list the last alerts raised.",
+		Run:     (*CLI).HandleAlerts,
+	},
+	{
+		Name:    "chains",
+		Summary: "list the chains followed with their last processed block.",
+		Run:     (*CLI).HandleChains,
+	},
+	{
+		Name:    "watch",
+		Args:    []Arg{{Name: "eth_address"}},
+		Flags:   []Flag{{Name: "until-block", Value: "n", Usage: "stop once this block is processed, required at the prompt"}, directionFlag, formatFlag, columnsFlag},
+		Summary: "show the transactions of an address, subscribing to it if needed, until the given block is processed.",
+		Run:     (*CLI).HandleWatch,
+	},
+	{
+		Name:    "format",
+		Args:    []Arg{{Name: "text|table|json|jsonl|csv", Optional: true}},
+		Flags:   []Flag{columnsFlag},
+		Summary: "set how transactions are printed for the rest of the session, or show the current format.",
+		Run:     (*CLI).HandleFormat,
+	},
+	{
+		Name:    "help",
+		Args:    []Arg{{Name: "command", Optional: true}},
+		Summary: "list the commands, or show the usage of one.",
+		Run:     (*CLI).HandleHelp,
+	},
+	{
+		Name:    "exit",
+		Aliases: []string{"quit"},
+		Summary: "leave the CLI.",
+		Run:     (*CLI).HandleExit,
+	},
+}
+
+// register adds a command. Declarations are fixed in the code, so invalid
+// ones panic like duplicate http.ServeMux patterns do.
+func (cli *CLI) register(cmd Command) {
+	if cmd.Name == "" || cmd.Run == nil {
+		panic(fmt.Sprintf("cli: command %q needs a name and a handler", cmd.Name))
+	}
+	for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+		if _, ok := cli.lookup(name); ok {
+			panic(fmt.Sprintf("cli: command %q registered twice", name))
+		}
+	}
+	for i, arg := range cmd.Args {
+		if i > 0 && cmd.Args[i-1].Optional && !arg.Optional {
+			panic(fmt.Sprintf("cli: argument %s of %s follows an optional one", arg.Name, cmd.Name))
+		}
+		if arg.Variadic && i < len(cmd.Args)-1 {
+			panic(fmt.Sprintf("cli: variadic argument %s of %s isn't the last one", arg.Name, cmd.Name))
+		}
+	}
+	cli.commands = append(cli.commands, cmd)
+}
+
+func (cli *CLI) lookup(name string) (Command, bool) {
+	for _, cmd := range cli.commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+		for _, alias := range cmd.Aliases {
+			if alias == name {
+				return cmd, true
+			}
+		}
+	}
+	return Command{}, false
+}
+
+var errArgCount = errors.New("wrong number of arguments")
+
+// check validates the arguments of a command against its declaration.
+func (cmd Command) check(args []string) error {
+	positional, flags := ParseFlags(args)
+	required := 0
+	for _, arg := range cmd.Args {
+		if !arg.Optional {
+			required++
+		}
+	}
+	variadic := len(cmd.Args) > 0 && cmd.Args[len(cmd.Args)-1].Variadic
+	if len(positional) < required || (len(positional) > len(cmd.Args) && !variadic) {
+		return errArgCount
+	}
+
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if _, ok := cmd.flag(name); !ok {
+			return fmt.Errorf("unknown option --%s", name)
+		}
+	}
+	for _, flag := range cmd.Flags {
+		if _, ok := flags[flag.Name]; flag.Required && !ok {
+			return fmt.Errorf("missing --%s", flag.Name)
+		}
+	}
+	return nil
+}
+
+func (cmd Command) flag(name string) (Flag, bool) {
+	for _, flag := range cmd.Flags {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// synopsis is the usage of a command, e.g. "unsubscribe [eth_address] [--purge]".
+func (cmd Command) synopsis() string {
+	parts := []string{cmd.Name}
+	for _, arg := range cmd.Args {
+		parts = append(parts, arg.String())
+	}
+	for _, flag := range cmd.Flags {
+		parts = append(parts, flag.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// String is the argument as shown in the usage, e.g. "[label...]".
+func (a Arg) String() string {
+	if a.Variadic {
+		return "[" + a.Name + "...]"
+	}
+	return "[" + a.Name + "]"
+}
+
+// String is the flag as shown in the usage, e.g. "[--limit=n]".
+func (f Flag) String() string {
+	s := "--" + f.Name
+	if f.Value != "" {
+		s += "=" + f.Value
+	}
+	if !f.Required {
+		s = "[" + s + "]"
+	}
+	return s
+}
+
+func (cli *CLI) printHelp() {
+	fmt.Fprintln(cli.output, "\nCommands:")
+	for _, cmd := range cli.commands {
+		if cmd.Summary == "" {
+			continue
+		}
+		// The flags are left to the help of the command
+		usage := cmd.Name
+		for _, arg := range cmd.Args {
+			usage += " " + arg.String()
+		}
+		if len(cmd.Flags) > 0 {
+			usage += " [options]"
+		}
+		fmt.Fprintf(cli.output, "- %s: %s\n", usage, cmd.Summary)
+	}
+	fmt.Fprintln(cli.output, "\nWhen following several chains, any command can target a single one with --chain=name|id.")
+}
+
+func (cli *CLI) HandleHelp(args []string) {
+	if len(args) == 0 {
+		cli.printHelp()
+		return
+	}
+	if len(args) != 1 {
+		cli.Usage("Usage: help [command]")
+		return
+	}
+	cmd, ok := cli.lookup(strings.ReplaceAll(args[0], "-", "_"))
+	if !ok {
+		cli.Usage("Unknown command:", args[0])
+		return
+	}
+	fmt.Fprintf(cli.output, "Usage: %s\n", cmd.synopsis())
+	if cmd.Summary != "" {
+		fmt.Fprintf(cli.output, "%s%s\n", strings.ToUpper(cmd.Summary[:1]), cmd.Summary[1:])
+	}
+	if len(cmd.Aliases) > 0 {
+		fmt.Fprintf(cli.output, "Aliases: %s\n", strings.Join(cmd.Aliases, ", "))
+	}
+	if len(cmd.Flags) > 0 {
+		fmt.Fprintln(cli.output, "Options:")
+		for _, flag := range cmd.Flags {
+			fmt.Fprintf(cli.output, "  %s: %s\n", strings.Trim(flag.String(), "[]"), flag.Usage)
+		}
+	}
+}
+
+func (cli *CLI) HandleExit(args []string) {
+	if len(args) != 0 {
+		cli.Usage("Usage: exit")
+		return
+	}
+	cli.done = true
+}
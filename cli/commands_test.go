@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"eth-tx-parser/eth_parser/test"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var tagCommand = Command{
+	Name:    "tag",
+	Aliases: []string{"t"},
+	Args:    []Arg{{Name: "eth_address"}, {Name: "tag", Optional: true}},
+	Flags:   []Flag{{Name: "by", Value: "name", Required: true, Usage: "author of the tag"}, {Name: "remove", Usage: "remove the tag"}},
+	Summary: "tag an address.",
+	Run: func(cli *CLI, args []string) {
+		args, flags := ParseFlags(args)
+		_, remove := flags["remove"]
+		fmt.Fprintf(cli.Output(), "%v by %s, remove: %t, block %d\n", args, flags["by"], remove, cli.Parser().GetCurrentBlock())
+	},
+}
+
+func Test_CLI_RegisteredCommand(t *testing.T) {
+	tt := []struct {
+		name     string
+		command  string
+		want     string
+		wantErr  string
+		wantCode int
+	}{
+		{
+			name:    "Run",
+			command: "tag 0x123 cold --by=ops",
+			want:    "[0x123 cold] by ops, remove: false, block 7\n",
+		},
+		{
+			name:    "Alias without the optional argument",
+			command: "t 0x123 --remove --by=ops",
+			want:    "[0x123] by ops, remove: true, block 7\n",
+		},
+		{
+			name:     "Missing argument",
+			command:  "tag --by=ops",
+			wantErr:  "Usage: tag [eth_address] [tag] --by=name [--remove]\n",
+			wantCode: ExitUsage,
+		},
+		{
+			name:     "Too many arguments",
+			command:  "tag 0x123 cold hot --by=ops",
+			wantErr:  "Usage: tag [eth_address] [tag] --by=name [--remove]\n",
+			wantCode: ExitUsage,
+		},
+		{
+			name:     "Missing required flag",
+			command:  "tag 0x123",
+			wantErr:  "missing --by\nUsage: tag [eth_address] [tag] --by=name [--remove]\n",
+			wantCode: ExitUsage,
+		},
+		{
+			name:     "Unknown flag",
+			command:  "tag 0x123 --by=ops --color=red",
+			wantErr:  "unknown option --color\nUsage: tag [eth_address] [tag] --by=name [--remove]\n",
+			wantCode: ExitUsage,
+		},
+		{
+			name:    "Help",
+			command: "help tag",
+			want:    "Usage: tag [eth_address] [tag] --by=name [--remove]\nTag an address.\nAliases: t\nOptions:\n  --by=name: author of the tag\n  --remove: remove the tag\n",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf, errBuf bytes.Buffer
+			cli := NewCLI(context.Background(), &test.ParserMock{ReturnGetCurrentBlock: 7}, tagCommand)
+			cli.output = &outBuf
+			cli.errOutput = &errBuf
+
+			cli.handleCommand(strings.Fields(tc.command))
+
+			if diff := cmp.Diff(tc.want, outBuf.String()); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantErr, errBuf.String()); diff != "" {
+				t.Errorf("error output mismatch (-want +got):\n%s", diff)
+			}
+			if cli.exitCode != tc.wantCode {
+				t.Errorf("expected exit code %d, got %d", tc.wantCode, cli.exitCode)
+			}
+		})
+	}
+}
+
+func Test_CLI_RegisteredCommand_Listed(t *testing.T) {
+	var outBuf bytes.Buffer
+	cli := NewCLI(context.Background(), &test.ParserMock{}, tagCommand)
+	cli.output = &outBuf
+
+	cli.HandleHelp(nil)
+
+	if !strings.Contains(outBuf.String(), "- tag [eth_address] [tag] [options]: tag an address.\n") {
+		t.Errorf("expected help to list the tag command, got:\n%s", outBuf.String())
+	}
+}
+
+func Test_NewCLI_InvalidCommands(t *testing.T) {
+	run := func(*CLI, []string) {}
+	tt := []struct {
+		name    string
+		command Command
+	}{
+		{name: "No name", command: Command{Run: run}},
+		{name: "No handler", command: Command{Name: "report"}},
+		{name: "Name taken", command: Command{Name: "list", Run: run}},
+		{name: "Alias taken", command: Command{Name: "leave", Aliases: []string{"quit"}, Run: run}},
+		{name: "Required after optional", command: Command{Name: "report", Args: []Arg{{Name: "from", Optional: true}, {Name: "to"}}, Run: run}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected NewCLI to panic")
+				}
+			}()
+			NewCLI(context.Background(), &test.ParserMock{}, tc.command)
+		})
+	}
+}
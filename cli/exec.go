@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"eth-tx-parser/eth_parser"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Exit codes of the commands run by Exec
+const (
+	ExitOK      = 0
+	ExitFailure = 1 // The command failed, e.g. the parser returned an error
+	ExitUsage   = 2 // The command is unknown or malformed
+
+	ExitInterrupted = 130 // The context was cancelled before the command was done, as on SIGINT
+)
+
+// SignalError is the cause of a context cancelled on a signal. Exec then
+// returns the status shells report for the signal, 128 plus its number.
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e SignalError) Error() string {
+	return "received " + e.Signal.String()
+}
+
+// interruptedCode is the exit code of a command interrupted by the
+// cancellation of ctx.
+func interruptedCode(ctx context.Context) int {
+	var sigErr SignalError
+	if errors.As(context.Cause(ctx), &sigErr) {
+		if sig, ok := sigErr.Signal.(syscall.Signal); ok {
+			return 128 + int(sig)
+		}
+	}
+	return ExitInterrupted
+}
+
+// How often watch checks whether the parser reached the end block
+var watchCheckFreq = 500 * time.Millisecond
+
+// Exec runs a single command given on the command line instead of the
+// prompt, e.g. "get-txs 0x... --limit=10", and returns its exit code. The
+// dashes of the command name stand for underscores. Failures are written to
+// stderr, and the parser is stopped once the command is done or interrupted
+// by the cancellation of the context, whose cause may be a SignalError.
+func (cli *CLI) Exec(args []string) int {
+	defer cli.parser.Stop()
+	if cli.errOutput == nil {
+		cli.errOutput = os.Stderr
+	}
+	if len(args) == 0 {
+		cli.Usage("No command given, see the help command.")
+		return cli.exitCode
+	}
+
+	command := strings.ReplaceAll(args[0], "-", "_")
+	if cmd, ok := cli.lookup(command); ok && cmd.Interactive {
+		cli.Usagef("%s needs the interactive prompt.\n", cmd.Name)
+		return cli.exitCode
+	}
+	cli.handleCommand(append([]string{command}, args[1:]...))
+	if cli.ctx.Err() != nil {
+		return interruptedCode(cli.ctx)
+	}
+	return cli.exitCode
+}
+
+const watchUsage = "Usage: watch [eth_address] [--until-block=n] [--direction=in|out|self] [--format=text|table|json|jsonl|csv] [--columns=a,b]"
+
+// HandleWatch prints the transactions of an address as they are recorded,
+// subscribing to it first if needed, until the parser processed the block
+// given by --until-block. Without it, watching only ends with the context,
+// so the prompt requires it and leaves open-ended watching to live.
+func (cli *CLI) HandleWatch(args []string) {
+	args, flags := ParseFlags(args)
+	if len(args) != 1 {
+		cli.Usage(watchUsage)
+		return
+	}
+	address := args[0]
+	direction, err := eth_parser.ParseDirection(flags["direction"])
+	if err != nil {
+		cli.Usage(err)
+		return
+	}
+	tw, err := cli.newTxWriter(flags, true)
+	if err != nil {
+		cli.Usage(err)
+		return
+	}
+	var untilBlock uint64
+	if value, ok := flags["until-block"]; ok {
+		if untilBlock, err = strconv.ParseUint(value, 10, 64); err != nil {
+			cli.Usage("Invalid until block:", value)
+			return
+		}
+	} else if cli.interactive {
+		cli.Usage("watch needs --until-block in the prompt, use live to watch until ENTER.")
+		return
+	}
+
+	if !cli.subscribed(address) && !cli.parser.Subscribe(address) {
+		cli.Fail("Invalid address format:", address)
+		return
+	}
+
+	defer tw.Close()
+	feed, stopListening := cli.listen()
+	defer stopListening()
+
+	ticker := time.NewTicker(watchCheckFreq)
+	defer ticker.Stop()
+	for untilBlock == 0 || cli.parser.GetCurrentBlock() < untilBlock {
+		select {
+		case <-cli.ctx.Done():
+			return
+		case tx, ok := <-feed:
+			if !ok {
+				cli.Fail("The parser stopped.")
+				return
+			}
+			if strings.EqualFold(address, tx.Subscriber) && tx.MatchesDirection(direction) {
+				tw.Write(tx)
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cli *CLI) subscribed(address string) bool {
+	for _, sub := range cli.parser.ListSubscriptions() {
+		if strings.EqualFold(sub.Address, address) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,348 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"eth-tx-parser/eth_parser"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutputFormat of the transactions printed by get_txs, live and watch.
+type OutputFormat string
+
+const (
+	FormatText  OutputFormat = "text" // The human readable blocks of printTx
+	FormatTable OutputFormat = "table"
+	FormatJSON  OutputFormat = "json"
+	FormatJSONL OutputFormat = "jsonl"
+	FormatCSV   OutputFormat = "csv"
+)
+
+var outputFormats = []OutputFormat{FormatText, FormatTable, FormatJSON, FormatJSONL, FormatCSV}
+
+type txColumn struct {
+	name  string
+	width int // In the table format
+	value func(tx *eth_parser.Transaction) string
+}
+
+var txColumns = []txColumn{
+	{name: "chain", width: 10, value: func(tx *eth_parser.Transaction) string { return eth_parser.ChainOf(tx.Chain).Name }},
+	{name: "subscriber", width: 42, value: func(tx *eth_parser.Transaction) string { return tx.Subscriber }},
+	{name: "kind", width: 14, value: func(tx *eth_parser.Transaction) string { return string(tx.EventKind()) }},
+	{name: "status", width: 8, value: func(tx *eth_parser.Transaction) string { return string(tx.Status) }},
+	{name: "direction", width: 9, value: func(tx *eth_parser.Transaction) string { return string(tx.TxDirection()) }},
+	{name: "hash", width: 66, value: func(tx *eth_parser.Transaction) string { return tx.Hash }},
+	{name: "block_number", width: 12, value: func(tx *eth_parser.Transaction) string {
+		if tx.BlockNumber == "" {
+			return "" // Pending
+		}
+		return strconv.FormatUint(tx.BlockNum(), 10)
+	}},
+	{name: "timestamp", width: 20, value: func(tx *eth_parser.Transaction) string {
+		if tx.Timestamp == "" {
+			return ""
+		}
+		return tx.Time().Format(time.RFC3339)
+	}},
+	{name: "from", width: 42, value: func(tx *eth_parser.Transaction) string { return tx.From }},
+	{name: "to", width: 42, value: func(tx *eth_parser.Transaction) string { return tx.To }},
+	{name: "from_label", width: 20, value: func(tx *eth_parser.Transaction) string { return tx.FromLabel }},
+	{name: "to_label", width: 20, value: func(tx *eth_parser.Transaction) string { return tx.ToLabel }},
+	{name: "value", width: 24, value: func(tx *eth_parser.Transaction) string { return tx.ValueWei().String() }},
+	{name: "amount", width: 24, value: func(tx *eth_parser.Transaction) string {
+		if tx.EventKind() == eth_parser.KindTokenTransfer {
+			return tx.ValueWei().String() // Token base units
+		}
+		return tx.ETHAmount()
+	}},
+	{name: "token", width: 42, value: func(tx *eth_parser.Transaction) string {
+		if tx.EventKind() != eth_parser.KindTokenTransfer || tx.Log == nil {
+			return ""
+		}
+		return tx.Log.Address
+	}},
+	{name: "fiat", width: 20, value: func(tx *eth_parser.Transaction) string {
+		if len(tx.Fiat) == 0 {
+			return ""
+		}
+		return tx.Fiat.String()
+	}},
+	{name: "call", width: 30, value: func(tx *eth_parser.Transaction) string {
+		if tx.Call == nil {
+			return ""
+		}
+		return tx.Call.String()
+	}},
+	{name: "replaced_by", width: 66, value: func(tx *eth_parser.Transaction) string { return tx.ReplacedBy }},
+}
+
+// Columns of the table and CSV formats when none are selected, the JSON
+// formats printing whole records then
+var defaultColumns = []string{"block_number", "hash", "direction", "from", "to", "amount", "status"}
+
+func columnNames() string {
+	names := make([]string, len(txColumns))
+	for i, c := range txColumns {
+		names[i] = c.name
+	}
+	return strings.Join(names, ",")
+}
+
+func parseOutputFormat(value string) (OutputFormat, error) {
+	for _, format := range outputFormats {
+		if OutputFormat(value) == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid format %q, expected one of text, table, json, jsonl or csv", value)
+}
+
+func parseColumns(names []string) ([]txColumn, error) {
+	columns := make([]txColumn, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, c := range txColumns {
+			if c.name == name {
+				columns = append(columns, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q, expected some of %s", name, columnNames())
+		}
+	}
+	return columns, nil
+}
+
+// SetOutputFormat sets the format of the transactions printed for the rest
+// of the session, along with the columns of the structured formats, which
+// commands can still override with --format and --columns.
+func (cli *CLI) SetOutputFormat(format string, columns []string) error {
+	if err := ValidateOutputFormat(format, columns); err != nil {
+		return err
+	}
+	cli.format, cli.columns = OutputFormat(format), columns
+	return nil
+}
+
+// ValidateOutputFormat checks an output format and its columns, e.g. of the
+// configuration, before there is a CLI to set them on.
+func ValidateOutputFormat(format string, columns []string) error {
+	if _, err := parseOutputFormat(format); err != nil {
+		return err
+	}
+	_, err := parseColumns(columns)
+	return err
+}
+
+func (cli *CLI) HandleFormat(args []string) {
+	args, flags := ParseFlags(args)
+	if len(args) > 1 {
+		cli.Usage("Usage: format [text|table|json|jsonl|csv] [--columns=a,b]")
+		return
+	}
+	if len(args) == 0 && len(flags) == 0 {
+		columns := "default"
+		if len(cli.columns) > 0 {
+			columns = strings.Join(cli.columns, ",")
+		}
+		fmt.Fprintf(cli.output, "Output format: %s, columns: %s.\n", cli.outputFormat(), columns)
+		return
+	}
+
+	format := string(cli.outputFormat())
+	if len(args) == 1 {
+		format = args[0]
+	}
+	columns := cli.columns
+	if value, ok := flags["columns"]; ok {
+		columns = splitList(value)
+	}
+	if err := cli.SetOutputFormat(format, columns); err != nil {
+		cli.Usage(err)
+		return
+	}
+	fmt.Fprintf(cli.output, "Printing transactions as %s.\n", cli.format)
+}
+
+func (cli *CLI) outputFormat() OutputFormat {
+	if cli.format == "" {
+		return FormatText
+	}
+	return cli.format
+}
+
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// txWriter prints transactions in an output format. A stream prints each
+// transaction as it comes, so its JSON format is a JSON value per
+// transaction rather than an array.
+type txWriter struct {
+	cli     *CLI
+	format  OutputFormat
+	columns []txColumn // Nil prints whole records in the JSON formats
+	stream  bool
+	started bool              // The header is printed
+	rows    []json.RawMessage // Of the JSON array, printed on Close
+	csv     *csv.Writer
+}
+
+// newTxWriter returns a writer of the session format, or of the --format
+// and --columns options, which it removes from flags.
+func (cli *CLI) newTxWriter(flags map[string]string, stream bool) (*txWriter, error) {
+	tw := &txWriter{cli: cli, format: cli.outputFormat(), stream: stream}
+	if value, ok := flags["format"]; ok {
+		format, err := parseOutputFormat(value)
+		if err != nil {
+			return nil, err
+		}
+		tw.format = format
+	}
+	names := cli.columns
+	if value, ok := flags["columns"]; ok {
+		if tw.format == FormatText {
+			return nil, fmt.Errorf("--columns only applies to the table, json, jsonl and csv formats")
+		}
+		names = splitList(value)
+	}
+	delete(flags, "format")
+	delete(flags, "columns")
+
+	if len(names) == 0 && (tw.format == FormatTable || tw.format == FormatCSV) {
+		names = defaultColumns
+	}
+	if len(names) > 0 {
+		columns, err := parseColumns(names)
+		if err != nil {
+			return nil, err
+		}
+		tw.columns = columns
+	}
+	return tw, nil
+}
+
+// structured tells whether the output is meant for other programs, which
+// then only find transactions in it.
+func (tw *txWriter) structured() bool {
+	return tw.format != FormatText
+}
+
+func (tw *txWriter) Write(tx eth_parser.Transaction) error {
+	tw.header()
+	out := tw.cli.output
+	switch tw.format {
+	case FormatTable:
+		values := make([]string, len(tw.columns))
+		for i, c := range tw.columns {
+			values[i] = c.value(&tx)
+		}
+		tw.tableRow(values)
+	case FormatCSV:
+		record := make([]string, len(tw.columns))
+		for i, c := range tw.columns {
+			record[i] = c.value(&tx)
+		}
+		tw.csv.Write(record)
+		tw.csv.Flush()
+		return tw.csv.Error()
+	case FormatJSON, FormatJSONL:
+		row, err := tw.jsonRow(&tx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case tw.format == FormatJSONL:
+			fmt.Fprintf(out, "%s\n", row)
+		case tw.stream:
+			var indented bytes.Buffer
+			json.Indent(&indented, row, "", "  ")
+			fmt.Fprintf(out, "%s\n", indented.Bytes())
+		default:
+			tw.rows = append(tw.rows, row)
+		}
+	default:
+		tw.cli.printTx(tx)
+	}
+	return nil
+}
+
+// Close prints what the format prints once all transactions are written:
+// the header of an empty table or CSV, and the JSON array.
+func (tw *txWriter) Close() error {
+	tw.header()
+	if tw.format != FormatJSON || tw.stream {
+		return nil
+	}
+	if tw.rows == nil {
+		tw.rows = []json.RawMessage{}
+	}
+	array, err := json.MarshalIndent(tw.rows, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(tw.cli.output, "%s\n", array)
+	return err
+}
+
+func (tw *txWriter) header() {
+	if tw.started {
+		return
+	}
+	tw.started = true
+	names := make([]string, len(tw.columns))
+	for i, c := range tw.columns {
+		names[i] = c.name
+	}
+	switch tw.format {
+	case FormatTable:
+		tw.tableRow(names)
+	case FormatCSV:
+		tw.csv = csv.NewWriter(tw.cli.output)
+		tw.csv.Write(names)
+		tw.csv.Flush()
+	}
+}
+
+// tableRow pads the values to the width of their column, so that rows of a
+// stream line up without knowing the rows to come.
+func (tw *txWriter) tableRow(values []string) {
+	var row strings.Builder
+	for i, value := range values {
+		if i == len(values)-1 {
+			row.WriteString(value)
+			break
+		}
+		fmt.Fprintf(&row, "%-*s  ", tw.columns[i].width, value)
+	}
+	fmt.Fprintln(tw.cli.output, strings.TrimRight(row.String(), " "))
+}
+
+// jsonRow marshals the whole record, or an object of the selected columns
+// in their order.
+func (tw *txWriter) jsonRow(tx *eth_parser.Transaction) (json.RawMessage, error) {
+	if tw.columns == nil {
+		return json.Marshal(tx)
+	}
+	var object bytes.Buffer
+	object.WriteByte('{')
+	for i, c := range tw.columns {
+		if i > 0 {
+			object.WriteByte(',')
+		}
+		key, _ := json.Marshal(c.name)
+		value, _ := json.Marshal(c.value(tx))
+		object.Write(key)
+		object.WriteByte(':')
+		object.Write(value)
+	}
+	object.WriteByte('}')
+	return object.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (cli *CLI) HandleLabel(args []string) {
+	if len(args) < 2 {
+		cli.Usage("Usage: label [eth_address] [label...]")
+		return
+	}
+	address, label := args[0], strings.Join(args[1:], " ")
+	if !cli.parser.SetLabel(address, label) {
+		cli.Fail("Invalid address format:", address)
+		return
+	}
+	fmt.Fprintf(cli.output, "Labelled %s as %s.\n", address, label)
+}
+
+func (cli *CLI) HandleUnlabel(args []string) {
+	if len(args) != 1 {
+		cli.Usage("Usage: unlabel [eth_address]")
+		return
+	}
+	if !cli.parser.RemoveLabel(args[0]) {
+		cli.Failf("%s has no label in the address book.\n", args[0])
+		return
+	}
+	fmt.Fprintf(cli.output, "Removed the label of %s.\n", args[0])
+}
+
+func (cli *CLI) HandleLabels(args []string) {
+	if len(args) != 0 {
+		cli.Usage("Usage: labels")
+		return
+	}
+	labels := cli.parser.ListLabels()
+	if len(labels) == 0 {
+		fmt.Fprintln(cli.output, "The address book is empty.")
+		return
+	}
+	fmt.Fprintf(cli.output, "Labelled addresses (%d):\n", len(labels))
+	for _, l := range labels {
+		fmt.Fprintf(cli.output, "=> %s (%s)\n", l.Address, l.Label)
+	}
+}
+
+// resolveAddress returns the address an argument stands for, which is
+// either an address or "*", or the label of an address in the address book
+// or of a subscription.
+func (cli *CLI) resolveAddress(arg string) (string, error) {
+	if arg == "*" || strings.HasPrefix(arg, "0x") {
+		return arg, nil
+	}
+	addresses := cli.labelled(func(label string) bool { return strings.EqualFold(label, arg) })
+	switch len(addresses) {
+	case 0:
+		return "", fmt.Errorf("no address is labelled %q", arg)
+	case 1:
+		return addresses[0], nil
+	}
+	return "", fmt.Errorf("%q labels several addresses: %s", arg, strings.Join(addresses, ", "))
+}
+
+// labelledLike returns the addresses whose label contains text, ignoring
+// case, for the --label filters.
+func (cli *CLI) labelledLike(text string) ([]string, error) {
+	lower := strings.ToLower(text)
+	addresses := cli.labelled(func(label string) bool { return strings.Contains(strings.ToLower(label), lower) })
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no label contains %q", text)
+	}
+	return addresses, nil
+}
+
+// labelled returns the sorted addresses whose label matches, the address
+// book taking precedence over subscriptions as in transactions.
+func (cli *CLI) labelled(match func(label string) bool) []string {
+	labels := make(map[string]string)
+	for _, l := range cli.parser.ListLabels() {
+		labels[strings.ToLower(l.Address)] = l.Label
+	}
+	for _, sub := range cli.parser.ListSubscriptions() {
+		address := strings.ToLower(sub.Address)
+		if _, ok := labels[address]; !ok && sub.Label != "" {
+			labels[address] = sub.Label
+		}
+	}
+	var addresses []string
+	for address, label := range labels {
+		if match(label) {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// withLabel shows an address next to its label, if it has one.
+func withLabel(address, label string) string {
+	if label == "" {
+		return address
+	}
+	return address + " (" + label + ")"
+}
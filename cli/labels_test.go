@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"eth-tx-parser/eth_parser"
+	"eth-tx-parser/eth_parser/test"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_CLI_HandleLabel(t *testing.T) {
+	tt := []struct {
+		name      string
+		args      []string
+		returnSet bool
+		expected  string
+	}{
+		{
+			name:      "Label with several words",
+			args:      []string{"0x123", "Binance", "hot", "wallet"},
+			returnSet: true,
+			expected:  "Labelled 0x123 as Binance hot wallet.\n",
+		},
+		{
+			name:     "Invalid address",
+			args:     []string{"0x12", "Binance"},
+			expected: "Invalid address format: 0x12\n",
+		},
+		{
+			name:     "Missing label",
+			args:     []string{"0x123"},
+			expected: "Usage: label [eth_address] [label...]\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf bytes.Buffer
+			parserMock := &test.ParserMock{ReturnSetLabel: tc.returnSet}
+
+			cli := NewCLI(context.Background(), parserMock)
+			cli.output = &outBuf
+
+			cli.HandleLabel(tc.args)
+
+			if outBuf.String() != tc.expected {
+				t.Errorf("expected output to be %q, got %q", tc.expected, outBuf.String())
+			}
+		})
+	}
+}
+
+func Test_CLI_HandleUnlabel(t *testing.T) {
+	var outBuf bytes.Buffer
+	parserMock := &test.ParserMock{ReturnRemoveLabel: true}
+
+	cli := NewCLI(context.Background(), parserMock)
+	cli.output = &outBuf
+
+	cli.HandleUnlabel([]string{"0x123"})
+	parserMock.ReturnRemoveLabel = false
+	cli.HandleUnlabel([]string{"0x123"})
+
+	want := "Removed the label of 0x123.\n0x123 has no label in the address book.\n"
+	if outBuf.String() != want {
+		t.Errorf("expected output to be %q, got %q", want, outBuf.String())
+	}
+}
+
+func Test_CLI_HandleLabels(t *testing.T) {
+	var outBuf bytes.Buffer
+	parserMock := &test.ParserMock{}
+
+	cli := NewCLI(context.Background(), parserMock)
+	cli.output = &outBuf
+
+	cli.HandleLabels(nil)
+	parserMock.ReturnListLabels = []eth_parser.AddressLabel{{Address: "0x123", Label: "Treasury"}, {Address: "0x456", Label: "Exchange"}}
+	cli.HandleLabels(nil)
+
+	want := strings.Join([]string{
+		"The address book is empty.",
+		"Labelled addresses (2):",
+		"=> 0x123 (Treasury)",
+		"=> 0x456 (Exchange)",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, outBuf.String()); diff != "" {
+		t.Errorf("HandleLabels() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_CLI_LabelFilters(t *testing.T) {
+	parserMock := func() *test.ParserMock {
+		return &test.ParserMock{
+			ReturnListLabels: []eth_parser.AddressLabel{
+				{Address: "0xAAA", Label: "Binance hot wallet"},
+				{Address: "0xbbb", Label: "Binance cold wallet"},
+				{Address: "0xccc", Label: "Treasury"},
+			},
+			// The address book takes precedence over the subscription label
+			ReturnListSubscriptions: []eth_parser.Subscription{{Address: "0xccc", Label: "Ops"}, {Address: "0xddd", Label: "Payroll"}},
+		}
+	}
+
+	tt := []struct {
+		name               string
+		args               []string
+		wantAddress        string
+		wantCounterparties []string
+		wantErr            string
+	}{
+		{
+			name:        "Address",
+			args:        []string{"0x123"},
+			wantAddress: "0x123",
+		},
+		{
+			name:        "Address book label",
+			args:        []string{"treasury"},
+			wantAddress: "0xccc",
+		},
+		{
+			name:        "Subscription label",
+			args:        []string{"Payroll"},
+			wantAddress: "0xddd",
+		},
+		{
+			name:               "Counterparty labels",
+			args:               []string{"Payroll", "--label=binance"},
+			wantAddress:        "0xddd",
+			wantCounterparties: []string{"0xaaa", "0xbbb"},
+		},
+		{
+			name:    "Unknown label",
+			args:    []string{"Ops"},
+			wantErr: "no address is labelled \"Ops\"\n",
+		},
+		{
+			name:    "No counterparty label",
+			args:    []string{"0x123", "--label=Kraken"},
+			wantErr: "no label contains \"Kraken\"\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf, errBuf bytes.Buffer
+			parserMock := parserMock()
+
+			cli := NewCLI(context.Background(), parserMock)
+			cli.output = &outBuf
+			cli.errOutput = &errBuf
+
+			cli.HandleGetTxs(tc.args)
+
+			if tc.wantErr != "" {
+				if errBuf.String() != tc.wantErr {
+					t.Errorf("expected error output to be %q, got %q", tc.wantErr, errBuf.String())
+				}
+				return
+			}
+			if parserMock.LastQuery.Address != tc.wantAddress {
+				t.Errorf("expected a query for %s, got %s", tc.wantAddress, parserMock.LastQuery.Address)
+			}
+			if diff := cmp.Diff(tc.wantCounterparties, parserMock.LastQuery.Counterparties); diff != "" {
+				t.Errorf("counterparties mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_CLI_PrintTxLabels(t *testing.T) {
+	var outBuf bytes.Buffer
+	parserMock := &test.ParserMock{
+		ReturnGetTransactions: []eth_parser.Transaction{
+			{Subscriber: "0x123", Hash: "hash1", From: "0x123", To: "0xdef", Value: "0x0", FromLabel: "Treasury", ToLabel: "Exchange"},
+		},
+	}
+
+	cli := NewCLI(context.Background(), parserMock)
+	cli.output = &outBuf
+
+	cli.HandleGetTxs([]string{"0x123"})
+
+	want := strings.Join([]string{
+		"Transactions for 0x123:",
+		"=> Transaction for address [0x123 (Treasury)]:",
+		"   Hash: hash1",
+		"   From: 0x123 (Treasury)",
+		"   To: 0xdef (Exchange)",
+		"   Direction: outgoing",
+		"   Amount: 0.00000000 ETH",
+		"",
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, outBuf.String()); diff != "" {
+		t.Errorf("HandleGetTxs() mismatch (-want +got):\n%s", diff)
+	}
+}
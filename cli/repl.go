@@ -0,0 +1,309 @@
+package cli
+
+import (
+	"bufio"
+	"eth-tx-parser/eth_parser"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// Commands kept in the history file, older ones are dropped when it is loaded
+const maxHistory = 1000
+
+// lineReader reads the lines entered at the prompt.
+type lineReader interface {
+	ReadLine(prompt string) (string, error) // io.EOF once the input is over
+	Close() error
+}
+
+// newLineReader edits lines in the terminal when the CLI runs in one, and
+// reads them as they come otherwise, e.g. from a pipe.
+func (cli *CLI) newLineReader() lineReader {
+	fd := int(os.Stdin.Fd())
+	if cli.output != os.Stdout || !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return &plainReader{cli: cli}
+	}
+	history := loadHistory(cli.HistoryFile)
+	return &terminalReader{
+		fd:      fd,
+		history: history,
+		editor:  &lineEditor{in: cli.input, out: os.Stdout, history: history, complete: cli.complete},
+	}
+}
+
+type plainReader struct {
+	cli *CLI
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.cli.output, "\n"+prompt)
+	return r.cli.readLine()
+}
+
+func (r *plainReader) Close() error {
+	return nil
+}
+
+// readLine reads a line of the input without its line ending, the last one
+// may have none. It returns io.EOF once the input is over.
+func (cli *CLI) readLine() (string, error) {
+	line, err := cli.input.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// terminalReader switches the terminal to raw mode while a line is edited,
+// so commands like live read it as usual in between.
+type terminalReader struct {
+	fd      int
+	history *history
+	editor  *lineEditor
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprintln(r.editor.out)
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	line, err := r.editor.ReadLine(prompt)
+	term.Restore(r.fd, state)
+	if err == nil {
+		r.history.Add(line)
+	}
+	return line, err
+}
+
+func (r *terminalReader) Close() error {
+	return nil
+}
+
+// history of the entered commands, appended to its file as they are
+// entered so that crashed sessions keep theirs.
+type history struct {
+	file  string
+	lines []string
+}
+
+// loadHistory reads the history file, starting an empty history when there
+// is none yet. An empty file name keeps the history in memory.
+func loadHistory(file string) *history {
+	h := &history{file: file}
+	if file == "" {
+		return h
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[len(h.lines)-maxHistory:]
+		os.WriteFile(file, []byte(strings.Join(h.lines, "\n")+"\n"), 0o600)
+	}
+	return h
+}
+
+// Add records a command, skipping empty lines and repeats of the last one.
+// Failing to save it only costs the history, so errors are ignored.
+func (h *history) Add(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || (len(h.lines) > 0 && h.lines[len(h.lines)-1] == line) {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if h.file == "" {
+		return
+	}
+	file, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+}
+
+// lineEditor edits a line in a terminal in raw mode: arrows move in the line
+// and the history, Tab completes, Ctrl-C drops the line and Ctrl-D on an
+// empty line ends the input.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete func(line string) (start int, candidates []string) // Of the word ending the line, starting at start
+}
+
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	var line []rune
+	pos := 0
+	browsed := len(e.history.lines) // Index of the history line shown, the edited line past the end
+	var edited []rune
+
+	fmt.Fprint(e.out, prompt)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			line, pos, browsed = nil, 0, len(e.history.lines)
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 21: // Ctrl-U
+			line, pos = line[pos:], 0
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case '\t':
+			line, pos = e.completeLine(prompt, line, pos)
+		case 27: // Escape sequence of the arrows and editing keys
+			if next, _, _ := e.in.ReadRune(); next != '[' && next != 'O' {
+				continue
+			}
+			key, _, _ := e.in.ReadRune()
+			switch key {
+			case 'A', 'B': // Up and down
+				if browsed == len(e.history.lines) {
+					edited = line
+				}
+				if key == 'A' && browsed > 0 {
+					browsed--
+				} else if key == 'B' && browsed < len(e.history.lines) {
+					browsed++
+				}
+				if browsed == len(e.history.lines) {
+					line = edited
+				} else {
+					line = []rune(e.history.lines[browsed])
+				}
+				pos = len(line)
+			case 'C':
+				if pos < len(line) {
+					pos++
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+				}
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(line)
+			case '3': // Delete, followed by ~
+				e.in.ReadRune()
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if !unicode.IsPrint(r) {
+				continue
+			}
+			line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+			pos++
+		}
+		e.redraw(prompt, line, pos)
+	}
+}
+
+func (e *lineEditor) redraw(prompt string, line []rune, pos int) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+	if back := len(line) - pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// completeLine completes the word before the cursor: a single candidate
+// replaces it, several extend it to their common prefix or are listed.
+func (e *lineEditor) completeLine(prompt string, line []rune, pos int) ([]rune, int) {
+	start, candidates := e.complete(string(line[:pos]))
+	if len(candidates) == 0 {
+		return line, pos
+	}
+	word := string(line[:pos])[start:]
+	completion := candidates[0] + " "
+	if len(candidates) > 1 {
+		completion = commonPrefix(candidates)
+		if len(completion) <= len(word) {
+			fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+			return line, pos
+		}
+	}
+	head := []rune(string(line[:pos])[:start] + completion)
+	return append(head, line[pos:]...), len(head)
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// complete lists the candidates of the last word of a line: command names
+// for the first word, and chains and subscribed addresses for the others.
+func (cli *CLI) complete(line string) (int, []string) {
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+
+	var options []string
+	switch {
+	case strings.TrimSpace(line[:start]) == "":
+		for _, cmd := range cli.commands {
+			options = append(options, cmd.Name)
+			options = append(options, cmd.Aliases...)
+		}
+	case strings.HasPrefix(word, "--chain="):
+		if router, ok := cli.parser.(eth_parser.ChainRouter); ok {
+			for _, chain := range router.Chains() {
+				options = append(options, "--chain="+chain.Name)
+			}
+		}
+	default:
+		seen := make(map[string]bool)
+		for _, sub := range cli.parser.ListSubscriptions() {
+			if !seen[sub.Address] {
+				seen[sub.Address] = true
+				options = append(options, sub.Address)
+			}
+		}
+	}
+
+	var candidates []string
+	for _, option := range options {
+		if strings.HasPrefix(strings.ToLower(option), strings.ToLower(word)) {
+			candidates = append(candidates, option)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
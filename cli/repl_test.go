@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"eth-tx-parser/eth_parser"
+	"eth-tx-parser/eth_parser/test"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_CLI_Run(t *testing.T) {
+	var outBuf bytes.Buffer
+	cli := NewCLI(context.Background(), &test.ParserMock{})
+	cli.input = bufio.NewReader(strings.NewReader("\nhelp list\n\nlist\nexit\nlist\n"))
+	cli.output = &outBuf
+
+	cli.Run()
+
+	got := outBuf.String()
+	if !strings.Contains(got, "Usage: list\nList the monitored addresses.\n") {
+		t.Errorf("expected the usage of list, got:\n%s", got)
+	}
+	// Empty lines are skipped, and exit leaves before the last list
+	if n := strings.Count(got, "You are not subscribed to any address."); n != 1 {
+		t.Errorf("expected list to run once, ran %d times:\n%s", n, got)
+	}
+	if !cli.done {
+		t.Error("expected exit to end the session")
+	}
+}
+
+func Test_CLI_HandleHelp(t *testing.T) {
+	tt := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "Command",
+			args: []string{"unsubscribe"},
+			want: "Usage: unsubscribe [eth_address] [--purge]\nStop monitoring an address.\nOptions:\n  --purge: also drop the stored transactions of the address\n",
+		},
+		{
+			name: "Alias",
+			args: []string{"quit"},
+			want: "Usage: exit\nLeave the CLI.\nAliases: quit\n",
+		},
+		{
+			name:    "Unknown command",
+			args:    []string{"send"},
+			wantErr: "Unknown command: send\n",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf, errBuf bytes.Buffer
+			cli := NewCLI(context.Background(), &test.ParserMock{})
+			cli.output = &outBuf
+			cli.errOutput = &errBuf
+
+			cli.HandleHelp(tc.args)
+
+			if diff := cmp.Diff(tc.want, outBuf.String()); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantErr, errBuf.String()); diff != "" {
+				t.Errorf("error output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_CLI_Complete(t *testing.T) {
+	parserMock := &test.ParserMock{
+		ReturnListSubscriptions: []eth_parser.Subscription{
+			{Address: "0xabc1"},
+			{Address: "0xabc2"},
+			{Address: "0xdef"},
+		},
+	}
+	cli := NewCLI(context.Background(), parserMock)
+
+	tt := []struct {
+		line      string
+		wantStart int
+		want      []string
+	}{
+		{line: "sub", want: []string{"subscribe", "subscribe_file"}},
+		{line: "  ex", wantStart: 2, want: []string{"exit", "export_subs", "export_txs"}},
+		{line: "get_txs 0xA", wantStart: 8, want: []string{"0xabc1", "0xabc2"}},
+		{line: "balance 0xd", wantStart: 8, want: []string{"0xdef"}},
+		{line: "list 0x9", wantStart: 5},
+	}
+	for _, tc := range tt {
+		t.Run(tc.line, func(t *testing.T) {
+			start, got := cli.complete(tc.line)
+			if start != tc.wantStart {
+				t.Errorf("expected the word to start at %d, got %d", tc.wantStart, start)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("complete() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_LineEditor(t *testing.T) {
+	complete := NewCLI(context.Background(), &test.ParserMock{
+		ReturnListSubscriptions: []eth_parser.Subscription{{Address: "0xabc"}},
+	}).complete
+
+	tt := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "Typing", input: "list\r", want: "list"},
+		{name: "Backspace", input: "lisx\x7ft\r", want: "list"},
+		{name: "Cursor moves", input: "ist\x1b[D\x1b[D\x1b[Dl\x05 \r", want: "list "},
+		{name: "Delete", input: "lisst\x1b[D\x1b[D\x1b[3~\r", want: "list"},
+		{name: "Kill to the start", input: "help list\x1b[D\x1b[D\x1b[D\x1b[D\x15\r", want: "list"},
+		{name: "History", input: "\x1b[A\x1b[A\r", want: "get_txs 0xabc"},
+		{name: "History back to the edited line", input: "li\x1b[A\x1b[B\r", want: "li"},
+		{name: "Complete a command", input: "subscribe_f\t0xa\t\r", want: "subscribe_file 0xabc "},
+		{name: "Complete to the common prefix", input: "ex\tport_t\t\r", want: "export_txs "},
+		{name: "Ctrl-C drops the line", input: "unsubscribe\x03list\r", want: "list"},
+		{name: "Ctrl-D", input: "\x04", wantErr: io.EOF},
+		{name: "End of input", input: "lis", wantErr: io.EOF},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			editor := &lineEditor{
+				in:       bufio.NewReader(strings.NewReader(tc.input)),
+				out:      io.Discard,
+				history:  &history{lines: []string{"get_txs 0xabc", "list"}},
+				complete: complete,
+			}
+			got, err := editor.ReadLine("> ")
+			if err != tc.wantErr {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("expected line %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_History(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+
+	h := loadHistory(file)
+	for _, line := range []string{"list", "list", " ", "get_txs 0x123 "} {
+		h.Add(line)
+	}
+
+	want := []string{"list", "get_txs 0x123"}
+	if diff := cmp.Diff(want, loadHistory(file).lines); diff != "" {
+		t.Errorf("reloaded history mismatch (-want +got):\n%s", diff)
+	}
+
+	// Long histories are trimmed to the last commands
+	var lines []string
+	for i := 0; i < maxHistory+10; i++ {
+		lines = append(lines, "list")
+	}
+	lines[len(lines)-1] = "chains"
+	os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	h = loadHistory(file)
+	if len(h.lines) != maxHistory || h.lines[maxHistory-1] != "chains" {
+		t.Errorf("expected the last %d commands, got %d ending with %q", maxHistory, len(h.lines), h.lines[len(h.lines)-1])
+	}
+}
@@ -0,0 +1,605 @@
+package config
+
+import (
+	"bytes"
+	"eth-tx-parser/eth_parser"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variable of every flag, e.g.
+// ETH_PARSER_POLLING_INTERVAL for -polling-interval.
+const EnvPrefix = "ETH_PARSER_"
+
+// Storage backends that can be selected, memory is the only one so far
+var storageBackends = []string{"memory"}
+
+var logFormats = []string{"text", "json"}
+
+var validAddress = regexp.MustCompile(`^0x[a-fA-F0-9]{40}$`)
+
+// Config is the whole setup of the parser, see Load for where it comes from.
+type Config struct {
+	ConfigFile  string   `yaml:"-"`
+	PrintConfig bool     `yaml:"-"`
+	Command     []string `yaml:"-"` // Arguments following the flags, a command to run instead of the prompt
+
+	Remote      string `yaml:"remote,omitempty"` // gRPC address of a parser to run the CLI against, instead of a local parser
+	GRPCAddr    string `yaml:"grpc_addr,omitempty"`
+	MetricsAddr string `yaml:"metrics_addr,omitempty"` // Serves the metrics of the local parser on /metrics
+	Storage     string `yaml:"storage"`
+
+	Chains          []string           `yaml:"chains"`             // Names or IDs of the chains to follow
+	Networks        map[string]Network `yaml:"networks,omitempty"` // Custom chains, and settings of the built-in ones
+	RPCURLs         []string           `yaml:"rpc_urls,omitempty"` // Override the endpoints of the only chain followed
+	RPCMaxAttempts  int                `yaml:"rpc_max_attempts"`
+	RPCBackoffScale time.Duration      `yaml:"rpc_backoff_scale"`
+	RPCTimeout      time.Duration      `yaml:"rpc_timeout"`                // 0 waits for as long as the node takes
+	PollingInterval time.Duration      `yaml:"polling_interval,omitempty"` // Overrides the block time of every chain
+	Confirmations   *uint64            `yaml:"confirmations,omitempty"`    // Overrides the confirmations of every chain
+
+	TrackTokens    bool     `yaml:"track_tokens"`
+	TrackEvents    bool     `yaml:"track_events"`
+	LogsOnly       bool     `yaml:"logs_only"`
+	ABIDir         string   `yaml:"abi_dir,omitempty"`
+	VerifySenders  bool     `yaml:"verify_senders"`
+	VerifyBlocks   bool     `yaml:"verify_blocks"`
+	PriceFile      string   `yaml:"price_file,omitempty"`
+	PriceURL       string   `yaml:"price_url,omitempty"`
+	Fiat           []string `yaml:"fiat"`
+	TrackBalances  bool     `yaml:"track_balances"`
+	ReconcileEvery uint64   `yaml:"reconcile_every"`
+	WatchMempool   bool     `yaml:"watch_mempool"`
+
+	Subscriptions []Subscription `yaml:"subscriptions,omitempty"` // Subscribed at startup
+
+	AlertRules       []AlertRule   `yaml:"alert_rules,omitempty"`
+	AlertSinks       []string      `yaml:"alert_sinks,omitempty"` // Where alerts go: log, stdout or webhook URLs
+	AlertDedupWindow time.Duration `yaml:"alert_dedup_window"`    // Repeated alerts are suppressed for this long
+
+	OutputFormat  string   `yaml:"output_format"` // Of the transactions printed by the CLI
+	OutputColumns []string `yaml:"output_columns,omitempty"`
+	HistoryFile   string   `yaml:"history_file"` // Of the commands entered in the CLI, none kept across sessions when empty
+
+	LogFormat string `yaml:"log_format"` // text or json
+	LogLevel  string `yaml:"log_level"`  // debug, info, warn or error
+	Quiet     bool   `yaml:"quiet"`      // Drops the logs, which would otherwise interleave with the prompt
+}
+
+// AlertRule raises alerts on the records meeting all of its conditions, see
+// eth_parser.Rule.
+type AlertRule struct {
+	Name                  string        `yaml:"name"`
+	Addresses             []string      `yaml:"addresses,omitempty"` // Subscribers by address or label
+	Direction             string        `yaml:"direction,omitempty"` // in, out or self
+	MinValue              string        `yaml:"min_value,omitempty"` // In ETH
+	MaxValue              string        `yaml:"max_value,omitempty"` // In ETH
+	Counterparties        []string      `yaml:"counterparties,omitempty"`
+	ExcludeCounterparties []string      `yaml:"exclude_counterparties,omitempty"`
+	NewCounterparty       bool          `yaml:"new_counterparty,omitempty"`
+	Methods               []string      `yaml:"methods,omitempty"`
+	Rate                  string        `yaml:"rate,omitempty"` // e.g. 5/10m, more than 5 records within 10 minutes
+	Cooldown              time.Duration `yaml:"cooldown,omitempty"`
+}
+
+// Network settings of a chain. Unset fields keep the value of the built-in
+// chain of the same name, custom chains need at least a chain ID and an RPC
+// endpoint.
+type Network struct {
+	ChainID        uint64        `yaml:"chain_id,omitempty"`
+	RPCURLs        []string      `yaml:"rpc_urls,omitempty"`
+	NativeSymbol   string        `yaml:"native_symbol,omitempty"`
+	NativeDecimals int           `yaml:"native_decimals,omitempty"`
+	BlockTime      time.Duration `yaml:"block_time,omitempty"`
+	Confirmations  uint64        `yaml:"confirmations,omitempty"`
+}
+
+type Subscription struct {
+	Address    string   `yaml:"address"`
+	Label      string   `yaml:"label,omitempty"`
+	Tags       []string `yaml:"tags,omitempty"`
+	StartBlock uint64   `yaml:"start_block,omitempty"`
+	Chain      string   `yaml:"chain,omitempty"` // Name or ID, every chain followed when empty
+}
+
+func Default() *Config {
+	return &Config{
+		Storage:          "memory",
+		Chains:           []string{eth_parser.Mainnet.Name},
+		RPCMaxAttempts:   5,
+		RPCBackoffScale:  1 * time.Second,
+		Fiat:             []string{"USD"},
+		ReconcileEvery:   100,
+		OutputFormat:     "text",
+		HistoryFile:      defaultHistoryFile(),
+		LogFormat:        "text",
+		LogLevel:         "info",
+		AlertDedupWindow: eth_parser.DefaultDedupWindow,
+	}
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".eth-tx-parser_history")
+}
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the YAML file given by -config or ETH_PARSER_CONFIG, the
+// ETH_PARSER_* environment variables and the command-line flags, then
+// validates it. The arguments following the flags are kept as Command.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	// The flags are parsed first to find the file, and applied again last
+	parsed := Default()
+	if err := parsed.flagSet(os.Stderr).Parse(args); err != nil {
+		return nil, err
+	}
+
+	c := Default()
+	fs := c.flagSet(io.Discard)
+	c.ConfigFile = parsed.ConfigFile
+	if c.ConfigFile == "" {
+		c.ConfigFile = getenv(EnvPrefix + "CONFIG")
+	}
+	if c.ConfigFile != "" {
+		if err := c.loadFile(c.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := EnvName(f.Name)
+		if value := getenv(name); value != "" && err == nil {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid %s: %v", name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	c.Command = fs.Args()
+
+	return c, c.Validate()
+}
+
+// EnvName is the environment variable setting a flag.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true) // Catch misspelled settings
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return nil
+}
+
+// flagSet binds the flags to the fields of c.
+func (c *Config) flagSet(output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("eth-tx-parser", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	fs.StringVar(&c.ConfigFile, "config", c.ConfigFile, "read the configuration from this YAML file, overridden by the environment and the flags")
+	fs.BoolVar(&c.PrintConfig, "print-config", c.PrintConfig, "print the effective configuration and exit")
+	fs.StringVar(&c.GRPCAddr, "grpc-addr", c.GRPCAddr, "serve the parser over gRPC on this address (e.g. :50051)")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr, "serve metrics on /metrics at this address (e.g. :9090)")
+	fs.StringVar(&c.Remote, "remote", c.Remote, "run the CLI against a remote parser gRPC server instead of a local parser")
+	fs.StringVar(&c.Storage, "storage", c.Storage, "storage backend, one of "+strings.Join(storageBackends, ", "))
+
+	fs.Var(listValue{&c.Chains}, "chains", "comma separated chains to follow by name or chain ID, e.g. mainnet,polygon")
+	fs.Var(listValue{&c.RPCURLs}, "rpc-url", "comma separated RPC endpoints of the chain followed, tried in order")
+	fs.IntVar(&c.RPCMaxAttempts, "rpc-max-attempts", c.RPCMaxAttempts, "attempts of an RPC request before giving up")
+	fs.DurationVar(&c.RPCBackoffScale, "rpc-backoff-scale", c.RPCBackoffScale, "upper bound of the wait before the first retry, doubled for every retry")
+	fs.DurationVar(&c.RPCTimeout, "rpc-timeout", c.RPCTimeout, "timeout of an RPC request, 0 for none")
+	fs.DurationVar(&c.PollingInterval, "polling-interval", c.PollingInterval, "interval between polls for new blocks (default the block time of each chain)")
+	fs.Var(confirmationsValue{&c.Confirmations}, "confirmations", "blocks a block must be buried under before it is processed (default the setting of each chain)")
+
+	fs.BoolVar(&c.TrackTokens, "track-tokens", c.TrackTokens, "also record ERC-20 transfers from or to subscribed addresses")
+	fs.BoolVar(&c.TrackEvents, "track-events", c.TrackEvents, "also record events emitted by subscribed contracts")
+	fs.BoolVar(&c.LogsOnly, "logs-only", c.LogsOnly, "only track token transfers and contract events, fetched with eth_getLogs instead of full blocks")
+	fs.StringVar(&c.ABIDir, "abi-dir", c.ABIDir, "decode contract calls with the ABIs of this directory, named <contract address>.json")
+	fs.BoolVar(&c.VerifySenders, "verify-senders", c.VerifySenders, "recover the sender of recorded transactions from their signature and flag mismatches")
+	fs.BoolVar(&c.VerifyBlocks, "verify-blocks", c.VerifyBlocks, "check every block against its hash and transactionsRoot, re-fetching or rejecting blocks that don't verify")
+	fs.StringVar(&c.PriceFile, "price-file", c.PriceFile, "value transfers in fiat with the prices of this CSV file of timestamp,currency,price records")
+	fs.StringVar(&c.PriceURL, "price-url", c.PriceURL, "value transfers in fiat with the prices of this HTTP price service")
+	fs.Var(listValue{&c.Fiat}, "fiat", "comma separated fiat currencies transfers are valued in")
+	fs.BoolVar(&c.TrackBalances, "track-balances", c.TrackBalances, "fetch the receipts of recorded transfers and reconcile the balances of subscribed addresses with the node")
+	fs.Uint64Var(&c.ReconcileEvery, "reconcile-every", c.ReconcileEvery, "blocks between balance reconciliations when tracking balances")
+	fs.BoolVar(&c.WatchMempool, "watch-mempool", c.WatchMempool, "also record pending transactions of subscribed addresses before they are mined")
+	fs.StringVar(&c.OutputFormat, "output-format", c.OutputFormat, "format of the transactions printed by the CLI: text, table, json, jsonl or csv")
+	fs.Var(listValue{&c.OutputColumns}, "output-columns", "comma separated columns of the table, json, jsonl and csv output formats")
+	fs.StringVar(&c.HistoryFile, "history-file", c.HistoryFile, "file keeping the commands entered in the CLI across sessions, empty to keep none")
+	fs.Var(subscribeValue{&c.Subscriptions}, "subscribe", "comma separated addresses to subscribe to at startup, on every chain")
+	fs.Var(listValue{&c.AlertSinks}, "alert-sinks", "comma separated destinations of the alerts: log, stdout or webhook URLs")
+	fs.DurationVar(&c.AlertDedupWindow, "alert-dedup-window", c.AlertDedupWindow, "time during which repeated alerts are suppressed")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "format of the logs: "+strings.Join(logFormats, " or "))
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "minimum level of the logs: debug, info, warn or error")
+	fs.BoolVar(&c.Quiet, "quiet", c.Quiet, "drop the logs, e.g. to keep them from interleaving with the CLI prompt")
+
+	return fs
+}
+
+// Validate checks the whole configuration, so mistakes surface at startup
+// rather than once the parser runs.
+func (c *Config) Validate() error {
+	if !contains(storageBackends, c.Storage) {
+		return fmt.Errorf("unknown storage %q, expected one of %s", c.Storage, strings.Join(storageBackends, ", "))
+	}
+	switch {
+	case c.RPCMaxAttempts < 1:
+		return fmt.Errorf("rpc_max_attempts must be at least 1, got %d", c.RPCMaxAttempts)
+	case c.RPCBackoffScale < 0:
+		return fmt.Errorf("rpc_backoff_scale can't be negative")
+	case c.RPCTimeout < 0:
+		return fmt.Errorf("rpc_timeout can't be negative")
+	case c.PollingInterval < 0:
+		return fmt.Errorf("polling_interval can't be negative")
+	case c.PriceFile != "" && c.PriceURL != "":
+		return fmt.Errorf("price_file and price_url can't be combined")
+	case (c.PriceFile != "" || c.PriceURL != "") && len(c.Fiat) == 0:
+		return fmt.Errorf("no fiat currency to value transfers in")
+	case c.MetricsAddr != "" && c.Remote != "":
+		return fmt.Errorf("metrics_addr only applies to a local parser")
+	case !contains(logFormats, c.LogFormat):
+		return fmt.Errorf("unknown log_format %q, expected one of %s", c.LogFormat, strings.Join(logFormats, ", "))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return fmt.Errorf("invalid log_level %q, expected debug, info, warn or error", c.LogLevel)
+	}
+	for _, u := range c.RPCURLs {
+		if err := validURL(u); err != nil {
+			return err
+		}
+	}
+	if c.AlertDedupWindow < 0 {
+		return fmt.Errorf("alert_dedup_window can't be negative")
+	}
+	for _, sink := range c.AlertSinks {
+		if sink != "log" && sink != "stdout" && validURL(sink) != nil {
+			return fmt.Errorf("invalid alert sink %q, expected log, stdout or a webhook URL", MaskURL(sink))
+		}
+	}
+	if _, err := c.ParserRules(); err != nil {
+		return err
+	}
+
+	var chains []eth_parser.ChainConfig
+	if c.Remote == "" { // The remote parser follows its own chains
+		var err error
+		if chains, err = c.ChainConfigs(); err != nil {
+			return err
+		}
+	}
+	for _, sub := range c.Subscriptions {
+		if !validAddress.MatchString(sub.Address) {
+			return fmt.Errorf("invalid subscription address %q", sub.Address)
+		}
+		if sub.Chain != "" && chains != nil && chainOf(chains, sub.Chain) == nil {
+			return fmt.Errorf("subscription of %s is on chain %s, which isn't followed", sub.Address, sub.Chain)
+		}
+	}
+	return nil
+}
+
+// ChainConfigs resolves the chains to follow, from the networks of the
+// config merged over the built-in chains, and the overrides applying to
+// every chain.
+func (c *Config) ChainConfigs() ([]eth_parser.ChainConfig, error) {
+	if len(c.Chains) == 0 {
+		return nil, fmt.Errorf("no chain to follow")
+	}
+	if len(c.RPCURLs) > 0 && len(c.Chains) > 1 {
+		return nil, fmt.Errorf("rpc_urls only applies when following a single chain, set the rpc_urls of each network instead")
+	}
+
+	var chains []eth_parser.ChainConfig
+	for _, nameOrID := range c.Chains {
+		chain, err := c.chain(nameOrID)
+		if err != nil {
+			return nil, err
+		}
+		if len(c.RPCURLs) > 0 {
+			chain.RPCURLs = c.RPCURLs
+		}
+		if c.PollingInterval > 0 {
+			chain.BlockTime = c.PollingInterval
+		}
+		if c.Confirmations != nil {
+			chain.Confirmations = *c.Confirmations
+		}
+		if err := chain.Validate(); err != nil {
+			return nil, err
+		}
+		for _, u := range chain.RPCURLs {
+			if err := validURL(u); err != nil {
+				return nil, err
+			}
+		}
+		if chainOf(chains, strconv.FormatUint(chain.ChainID, 10)) != nil {
+			return nil, fmt.Errorf("chain %s is followed twice", chain)
+		}
+		chains = append(chains, chain)
+	}
+	return chains, nil
+}
+
+// chain finds a chain among the networks and the built-in chains.
+func (c *Config) chain(nameOrID string) (eth_parser.ChainConfig, error) {
+	chain, known := eth_parser.LookupChain(nameOrID)
+	id, _ := strconv.ParseUint(nameOrID, 10, 64)
+
+	for name, network := range c.Networks {
+		if !strings.EqualFold(name, nameOrID) && (id == 0 || network.ChainID != id) && !(known && strings.EqualFold(name, chain.Name)) {
+			continue
+		}
+		if !known {
+			chain = eth_parser.ChainConfig{Name: name, NativeDecimals: 18}
+		}
+		if network.ChainID != 0 {
+			chain.ChainID = network.ChainID
+		}
+		if len(network.RPCURLs) > 0 {
+			chain.RPCURLs = network.RPCURLs
+		}
+		if network.NativeSymbol != "" {
+			chain.NativeSymbol = network.NativeSymbol
+		}
+		if network.NativeDecimals != 0 {
+			chain.NativeDecimals = network.NativeDecimals
+		}
+		if network.BlockTime != 0 {
+			chain.BlockTime = network.BlockTime
+		}
+		if network.Confirmations != 0 {
+			chain.Confirmations = network.Confirmations
+		}
+		return chain, nil
+	}
+	if !known {
+		return eth_parser.ChainConfig{}, fmt.Errorf("unknown chain %q, define it under networks", nameOrID)
+	}
+	return chain, nil
+}
+
+// ParserSubscriptions converts the subscriptions for the parser, resolving
+// their chains.
+func (c *Config) ParserSubscriptions(chains []eth_parser.ChainConfig) []eth_parser.Subscription {
+	subs := make([]eth_parser.Subscription, 0, len(c.Subscriptions))
+	for _, sub := range c.Subscriptions {
+		parserSub := eth_parser.Subscription{Address: sub.Address, Label: sub.Label, Tags: sub.Tags, StartBlock: sub.StartBlock}
+		if sub.Chain != "" {
+			if chain := chainOf(chains, sub.Chain); chain != nil {
+				parserSub.Chain = chain.ChainID
+			} else if chain, ok := eth_parser.LookupChain(sub.Chain); ok {
+				parserSub.Chain = chain.ChainID
+			}
+		}
+		subs = append(subs, parserSub)
+	}
+	return subs
+}
+
+// ParserRules converts the alert rules for the parser.
+func (c *Config) ParserRules() ([]eth_parser.Rule, error) {
+	rules := make([]eth_parser.Rule, 0, len(c.AlertRules))
+	names := make(map[string]bool, len(c.AlertRules))
+	for _, r := range c.AlertRules {
+		rule := eth_parser.Rule{
+			Name:                  r.Name,
+			Addresses:             r.Addresses,
+			Counterparties:        r.Counterparties,
+			ExcludeCounterparties: r.ExcludeCounterparties,
+			NewCounterparty:       r.NewCounterparty,
+			Methods:               r.Methods,
+			Cooldown:              r.Cooldown,
+		}
+		var err error
+		if rule.Direction, err = eth_parser.ParseDirection(r.Direction); err != nil {
+			return nil, fmt.Errorf("alert rule %s: %w", r.Name, err)
+		}
+		if r.MinValue != "" {
+			if rule.MinValue, err = eth_parser.ParseETHAmount(r.MinValue); err != nil {
+				return nil, fmt.Errorf("alert rule %s: %w", r.Name, err)
+			}
+		}
+		if r.MaxValue != "" {
+			if rule.MaxValue, err = eth_parser.ParseETHAmount(r.MaxValue); err != nil {
+				return nil, fmt.Errorf("alert rule %s: %w", r.Name, err)
+			}
+		}
+		if r.Rate != "" {
+			if rule.RateCount, rule.RateWindow, err = eth_parser.ParseRate(r.Rate); err != nil {
+				return nil, fmt.Errorf("alert rule %s: %w", r.Name, err)
+			}
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid alert %w", err)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("alert rule %s is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Logger returns a logger writing to w in the configured format and from the
+// configured level, which discards everything in quiet mode.
+func (c *Config) Logger(w io.Writer) *slog.Logger {
+	if c.Quiet {
+		w = io.Discard
+	}
+	var level slog.Level
+	level.UnmarshalText([]byte(c.LogLevel)) // Validated with the config
+	opts := &slog.HandlerOptions{Level: level}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Write prints the effective configuration as YAML, with the credentials of
+// the RPC endpoints masked.
+func (c *Config) Write(w io.Writer) error {
+	masked := *c
+	masked.RPCURLs = maskURLs(c.RPCURLs)
+	masked.AlertSinks = maskURLs(c.AlertSinks)
+	if len(c.Networks) > 0 {
+		masked.Networks = make(map[string]Network, len(c.Networks))
+		for name, network := range c.Networks {
+			network.RPCURLs = maskURLs(network.RPCURLs)
+			masked.Networks[name] = network
+		}
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&masked); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func maskURLs(urls []string) []string {
+	if urls == nil {
+		return nil
+	}
+	masked := make([]string, len(urls))
+	for i, u := range urls {
+		masked[i] = MaskURL(u)
+	}
+	return masked
+}
+
+// MaskURL hides the parts of an endpoint URL that usually hold an API key:
+// the password, the query values and long path segments, e.g. the key of
+// https://mainnet.infura.io/v3/<key>.
+func MaskURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "***"
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "***")
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, "***")
+		}
+		u.RawQuery = query.Encode()
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		if len(segment) >= 16 {
+			segments[i] = "***"
+		}
+	}
+	u.Path, u.RawPath = strings.Join(segments, "/"), ""
+	return strings.NewReplacer("%2A", "*").Replace(u.String())
+}
+
+func validURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid RPC endpoint %q, expected an http(s) URL", MaskURL(rawURL))
+	}
+	return nil
+}
+
+// chainOf finds a chain by name or decimal chain ID, nil when it isn't among
+// chains.
+func chainOf(chains []eth_parser.ChainConfig, nameOrID string) *eth_parser.ChainConfig {
+	id, err := strconv.ParseUint(nameOrID, 10, 64)
+	for i := range chains {
+		if (err == nil && chains[i].ChainID == id) || strings.EqualFold(chains[i].Name, nameOrID) {
+			return &chains[i]
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// listValue is a flag of comma separated values, replacing the list.
+type listValue struct{ list *[]string }
+
+func (v listValue) String() string {
+	if v.list == nil {
+		return ""
+	}
+	return strings.Join(*v.list, ",")
+}
+
+func (v listValue) Set(s string) error {
+	*v.list = strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	return nil
+}
+
+// confirmationsValue is a flag that, once set, overrides the confirmations
+// of every chain.
+type confirmationsValue struct{ confirmations **uint64 }
+
+func (v confirmationsValue) String() string {
+	if v.confirmations == nil || *v.confirmations == nil {
+		return ""
+	}
+	return strconv.FormatUint(**v.confirmations, 10)
+}
+
+func (v confirmationsValue) Set(s string) error {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*v.confirmations = &n
+	return nil
+}
+
+// subscribeValue is a flag of comma separated addresses, added to the
+// subscriptions of the config.
+type subscribeValue struct{ subs *[]Subscription }
+
+func (v subscribeValue) String() string {
+	return ""
+}
+
+func (v subscribeValue) Set(s string) error {
+	for _, address := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		*v.subs = append(*v.subs, Subscription{Address: address})
+	}
+	return nil
+}
@@ -0,0 +1,608 @@
+package eth_parser
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Call is the contract call a transaction input decodes to.
+type Call struct {
+	Selector  string    `json:"selector"`         // First 4 bytes of the input
+	Method    string    `json:"method,omitempty"` // Empty when the selector is unknown
+	Signature string    `json:"signature,omitempty"`
+	Args      []CallArg `json:"args,omitempty"`
+}
+
+// CallArg is a decoded argument. Integers are rendered in decimal, addresses
+// and byte strings in hex, arrays as [a, b] and tuples as (a, b).
+type CallArg struct {
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func (c *Call) String() string {
+	if c.Method == "" {
+		return "unknown method " + c.Selector
+	}
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		if arg.Name != "" {
+			args[i] = arg.Name + "=" + arg.Value
+		} else {
+			args[i] = arg.Value
+		}
+	}
+	return c.Method + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Signatures of common token, NFT and DEX router methods, recognized on
+// every contract without loading its ABI.
+var builtinSignatures = []string{
+	"transfer(address to,uint256 value)",
+	"approve(address spender,uint256 value)",
+	"transferFrom(address from,address to,uint256 value)",
+	"increaseAllowance(address spender,uint256 addedValue)",
+	"decreaseAllowance(address spender,uint256 subtractedValue)",
+	"permit(address owner,address spender,uint256 value,uint256 deadline,uint8 v,bytes32 r,bytes32 s)",
+	"mint(address to,uint256 amount)",
+	"burn(uint256 amount)",
+	"deposit()",
+	"withdraw(uint256 wad)",
+	"safeTransferFrom(address from,address to,uint256 tokenId)",
+	"safeTransferFrom(address from,address to,uint256 tokenId,bytes data)",
+	"setApprovalForAll(address operator,bool approved)",
+	"safeTransferFrom(address from,address to,uint256 id,uint256 value,bytes data)",
+	"safeBatchTransferFrom(address from,address to,uint256[] ids,uint256[] values,bytes data)",
+	"multicall(bytes[] data)",
+	"multicall(uint256 deadline,bytes[] data)",
+	"swapExactTokensForTokens(uint256 amountIn,uint256 amountOutMin,address[] path,address to,uint256 deadline)",
+	"swapTokensForExactTokens(uint256 amountOut,uint256 amountInMax,address[] path,address to,uint256 deadline)",
+	"swapExactETHForTokens(uint256 amountOutMin,address[] path,address to,uint256 deadline)",
+	"swapETHForExactTokens(uint256 amountOut,address[] path,address to,uint256 deadline)",
+	"swapExactTokensForETH(uint256 amountIn,uint256 amountOutMin,address[] path,address to,uint256 deadline)",
+	"swapTokensForExactETH(uint256 amountOut,uint256 amountInMax,address[] path,address to,uint256 deadline)",
+	"exactInputSingle((address tokenIn,address tokenOut,uint24 fee,address recipient,uint256 deadline,uint256 amountIn,uint256 amountOutMinimum,uint160 sqrtPriceLimitX96) params)",
+	"exactInput((bytes path,address recipient,uint256 deadline,uint256 amountIn,uint256 amountOutMinimum) params)",
+	"execute(bytes commands,bytes[] inputs)",
+	"execute(bytes commands,bytes[] inputs,uint256 deadline)",
+}
+
+// ABIRegistry decodes transaction inputs with the ABIs loaded for their
+// target contracts, falling back to the built-in method signatures.
+type ABIRegistry struct {
+	mu        sync.RWMutex
+	contracts map[string]map[[4]byte]*abiMethod
+	selectors map[[4]byte]*abiMethod
+}
+
+func NewABIRegistry() *ABIRegistry {
+	r := &ABIRegistry{
+		contracts: make(map[string]map[[4]byte]*abiMethod),
+		selectors: make(map[[4]byte]*abiMethod),
+	}
+	for _, signature := range builtinSignatures {
+		if err := r.AddSignature(signature); err != nil {
+			panic(err) // The built-in signatures are known to be valid
+		}
+	}
+	return r
+}
+
+// AddSignature registers a method for every contract, from a signature
+// such as "transfer(address to,uint256 value)". Argument names are optional.
+func (r *ABIRegistry) AddSignature(signature string) error {
+	open := strings.IndexByte(signature, '(')
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return errors.Errorf("invalid method signature %q", signature)
+	}
+	params, err := parseSignatureParams(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return errors.Wrapf(err, "invalid method signature %q", signature)
+	}
+	m := newABIMethod(strings.TrimSpace(signature[:open]), params)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.selectors[m.selector] = m
+	return nil
+}
+
+type abiJSONParam struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Components []abiJSONParam `json:"components"`
+}
+
+// LoadABI registers the functions of a contract's JSON ABI, given either as
+// the bare ABI array or as a compiler artifact holding it under "abi".
+func (r *ABIRegistry) LoadABI(address string, reader io.Reader) error {
+	if !validAddress.MatchString(address) {
+		return errors.Errorf("invalid contract address %q", address)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to read ABI")
+	}
+	var entries []struct {
+		Type   string         `json:"type"`
+		Name   string         `json:"name"`
+		Inputs []abiJSONParam `json:"inputs"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		var artifact struct {
+			ABI json.RawMessage `json:"abi"`
+		}
+		if json.Unmarshal(data, &artifact) != nil || artifact.ABI == nil || json.Unmarshal(artifact.ABI, &entries) != nil {
+			return errors.Wrap(err, "failed on the deserialization of ABI")
+		}
+	}
+
+	methods := make(map[[4]byte]*abiMethod)
+	for _, entry := range entries {
+		if entry.Type != "function" && entry.Type != "" { // Functions may omit their type
+			continue
+		}
+		params, err := fromJSONParams(entry.Inputs)
+		if err != nil {
+			return errors.Wrapf(err, "invalid inputs of function %s", entry.Name)
+		}
+		m := newABIMethod(entry.Name, params)
+		methods[m.selector] = m
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.contracts[normalizeAddress(address)] = methods
+	return nil
+}
+
+// LoadABIDir loads every <contract address>.json file of dir, returning how
+// many ABIs were loaded.
+func (r *ABIRegistry) LoadABIDir(dir string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list ABI files")
+	}
+	loaded := 0
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return loaded, errors.Wrap(err, "failed to open ABI file")
+		}
+		err = r.LoadABI(strings.TrimSuffix(filepath.Base(path), ".json"), file)
+		file.Close()
+		if err != nil {
+			return loaded, errors.Wrapf(err, "failed to load %s", path)
+		}
+		loaded++
+	}
+	return loaded, nil
+}
+
+// Decode decodes the input of a call to the contract at address to. It
+// returns nil when the input holds no method selector, e.g. for plain
+// transfers, and a Call without Method when the selector is unknown or the
+// arguments don't match it.
+func (r *ABIRegistry) Decode(to, input string) *Call {
+	raw, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil || len(raw) < 4 {
+		return nil
+	}
+	selector := [4]byte(raw[:4])
+	call := &Call{Selector: "0x" + hex.EncodeToString(selector[:])}
+
+	r.mu.RLock()
+	candidates := []*abiMethod{r.contracts[normalizeAddress(to)][selector], r.selectors[selector]}
+	r.mu.RUnlock()
+
+	for _, m := range candidates {
+		if m == nil {
+			continue
+		}
+		values, err := decodeTuple(raw[4:], m.types)
+		if err != nil {
+			continue
+		}
+		call.Method, call.Signature = m.name, m.signature
+		for i, param := range m.params {
+			call.Args = append(call.Args, CallArg{Name: param.name, Type: param.typ.String(), Value: values[i]})
+		}
+		return call
+	}
+	return call
+}
+
+type abiMethod struct {
+	name      string
+	signature string // Canonical, without argument names
+	selector  [4]byte
+	params    []abiParam
+	types     []*abiType
+}
+
+func newABIMethod(name string, params []abiParam) *abiMethod {
+	m := &abiMethod{name: name, params: params}
+	types := make([]string, len(params))
+	for i, param := range params {
+		m.types = append(m.types, param.typ)
+		types[i] = param.typ.String()
+	}
+	m.signature = name + "(" + strings.Join(types, ",") + ")"
+	copy(m.selector[:], Keccak256([]byte(m.signature)))
+	return m
+}
+
+type abiParam struct {
+	name string
+	typ  *abiType
+}
+
+type abiKind int
+
+const (
+	abiUint abiKind = iota
+	abiInt
+	abiAddress
+	abiBool
+	abiFixedBytes
+	abiBytes
+	abiString
+	abiSlice // T[]
+	abiArray // T[k]
+	abiTuple
+)
+
+type abiType struct {
+	kind       abiKind
+	size       int // Bits of integers, length of fixed bytes and arrays
+	elem       *abiType
+	components []abiParam
+}
+
+// String returns the canonical type name used in method signatures.
+func (t *abiType) String() string {
+	switch t.kind {
+	case abiUint:
+		return "uint" + strconv.Itoa(t.size)
+	case abiInt:
+		return "int" + strconv.Itoa(t.size)
+	case abiAddress:
+		return "address"
+	case abiBool:
+		return "bool"
+	case abiFixedBytes:
+		return "bytes" + strconv.Itoa(t.size)
+	case abiBytes:
+		return "bytes"
+	case abiString:
+		return "string"
+	case abiSlice:
+		return t.elem.String() + "[]"
+	case abiArray:
+		return t.elem.String() + "[" + strconv.Itoa(t.size) + "]"
+	default:
+		types := make([]string, len(t.components))
+		for i, c := range t.components {
+			types[i] = c.typ.String()
+		}
+		return "(" + strings.Join(types, ",") + ")"
+	}
+}
+
+func (t *abiType) dynamic() bool {
+	switch t.kind {
+	case abiBytes, abiString, abiSlice:
+		return true
+	case abiArray:
+		return t.elem.dynamic()
+	case abiTuple:
+		for _, c := range t.components {
+			if c.typ.dynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize is the space the type takes in the head of its enclosing tuple.
+func (t *abiType) headSize() int {
+	if t.dynamic() {
+		return 32 // Offset of the tail
+	}
+	switch t.kind {
+	case abiArray:
+		return t.size * t.elem.headSize()
+	case abiTuple:
+		size := 0
+		for _, c := range t.components {
+			size += c.typ.headSize()
+		}
+		return size
+	}
+	return 32
+}
+
+func fromJSONParams(params []abiJSONParam) ([]abiParam, error) {
+	result := make([]abiParam, 0, len(params))
+	for _, p := range params {
+		var components []abiParam
+		if strings.HasPrefix(p.Type, "tuple") {
+			var err error
+			if components, err = fromJSONParams(p.Components); err != nil {
+				return nil, err
+			}
+		}
+		typ, err := parseABIType(p.Type, components)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, abiParam{name: p.Name, typ: typ})
+	}
+	return result, nil
+}
+
+// parseSignatureParams parses a comma separated list of "type [name]",
+// where tuples are written as parenthesized lists.
+func parseSignatureParams(s string) ([]abiParam, error) {
+	var params []abiParam
+	if strings.TrimSpace(s) == "" {
+		return params, nil
+	}
+	depth, start := 0, 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch s[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		param, err := parseSignatureParam(strings.TrimSpace(s[start:i]))
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+		start = i + 1
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced parentheses")
+	}
+	return params, nil
+}
+
+func parseSignatureParam(s string) (abiParam, error) {
+	var typ *abiType
+	var rest string
+	var err error
+	if strings.HasPrefix(s, "(") {
+		end := strings.LastIndexByte(s, ')')
+		components, err := parseSignatureParams(s[1:end])
+		if err != nil {
+			return abiParam{}, err
+		}
+		suffixEnd := end + 1
+		for suffixEnd < len(s) && strings.IndexByte("[]0123456789", s[suffixEnd]) >= 0 {
+			suffixEnd++
+		}
+		if typ, err = parseABIType("tuple"+s[end+1:suffixEnd], components); err != nil {
+			return abiParam{}, err
+		}
+		rest = s[suffixEnd:]
+	} else {
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			return abiParam{}, errors.New("missing parameter type")
+		}
+		if typ, err = parseABIType(fields[0], nil); err != nil {
+			return abiParam{}, err
+		}
+		rest = strings.Join(fields[1:], " ")
+	}
+	// Keep the name, skipping data locations and modifiers
+	param := abiParam{typ: typ}
+	if fields := strings.Fields(rest); len(fields) > 0 {
+		param.name = fields[len(fields)-1]
+	}
+	return param, nil
+}
+
+// parseABIType parses a type name such as "uint256", "address[]" or
+// "tuple[2]", the latter with the given components.
+func parseABIType(s string, components []abiParam) (*abiType, error) {
+	base, suffix := s, ""
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		base, suffix = s[:i], s[i:]
+	}
+	typ, err := parseBaseType(base, components)
+	if err != nil {
+		return nil, err
+	}
+	for suffix != "" {
+		end := strings.IndexByte(suffix, ']')
+		if suffix[0] != '[' || end < 0 {
+			return nil, errors.Errorf("invalid type %q", s)
+		}
+		if length := suffix[1:end]; length == "" {
+			typ = &abiType{kind: abiSlice, elem: typ}
+		} else {
+			n, err := strconv.Atoi(length)
+			if err != nil || n <= 0 {
+				return nil, errors.Errorf("invalid array length in type %q", s)
+			}
+			typ = &abiType{kind: abiArray, size: n, elem: typ}
+		}
+		suffix = suffix[end+1:]
+	}
+	return typ, nil
+}
+
+func parseBaseType(base string, components []abiParam) (*abiType, error) {
+	switch base {
+	case "address":
+		return &abiType{kind: abiAddress}, nil
+	case "bool":
+		return &abiType{kind: abiBool}, nil
+	case "string":
+		return &abiType{kind: abiString}, nil
+	case "bytes":
+		return &abiType{kind: abiBytes}, nil
+	case "function": // Address followed by a selector
+		return &abiType{kind: abiFixedBytes, size: 24}, nil
+	case "tuple":
+		return &abiType{kind: abiTuple, components: components}, nil
+	}
+	for prefix, kind := range map[string]abiKind{"uint": abiUint, "int": abiInt, "bytes": abiFixedBytes} {
+		size, ok := strings.CutPrefix(base, prefix)
+		if !ok {
+			continue
+		}
+		if size == "" && kind != abiFixedBytes {
+			return &abiType{kind: kind, size: 256}, nil
+		}
+		n, err := strconv.Atoi(size)
+		if kind == abiFixedBytes && err == nil && n >= 1 && n <= 32 {
+			return &abiType{kind: kind, size: n}, nil
+		}
+		if kind != abiFixedBytes && err == nil && n >= 8 && n <= 256 && n%8 == 0 {
+			return &abiType{kind: kind, size: n}, nil
+		}
+	}
+	return nil, errors.Errorf("unsupported type %q", base)
+}
+
+// decodeTuple decodes the values of types encoded in data, where offsets
+// of dynamic values are relative to the start of data.
+func decodeTuple(data []byte, types []*abiType) ([]string, error) {
+	values := make([]string, len(types))
+	pos := 0
+	for i, t := range types {
+		at := pos
+		if t.dynamic() {
+			offset, err := readLength(data, pos)
+			if err != nil {
+				return nil, err
+			}
+			at = offset
+		}
+		value, err := decodeValue(t, data, at)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+		pos += t.headSize()
+	}
+	return values, nil
+}
+
+func decodeValue(t *abiType, data []byte, at int) (string, error) {
+	switch t.kind {
+	case abiSlice, abiArray:
+		n := t.size
+		if t.kind == abiSlice {
+			length, err := readLength(data, at)
+			if err != nil {
+				return "", err
+			}
+			n, at = length, at+32
+		}
+		if n > (len(data)-at)/32 { // Every element takes at least a word
+			return "", errors.New("array exceeds the input")
+		}
+		types := make([]*abiType, n)
+		for i := range types {
+			types[i] = t.elem
+		}
+		values, err := decodeTuple(data[at:], types)
+		if err != nil {
+			return "", err
+		}
+		return "[" + strings.Join(values, ", ") + "]", nil
+	case abiTuple:
+		if at > len(data) {
+			return "", errors.New("tuple exceeds the input")
+		}
+		types := make([]*abiType, len(t.components))
+		for i, c := range t.components {
+			types[i] = c.typ
+		}
+		values, err := decodeTuple(data[at:], types)
+		if err != nil {
+			return "", err
+		}
+		return "(" + strings.Join(values, ", ") + ")", nil
+	case abiBytes, abiString:
+		length, err := readLength(data, at)
+		if err != nil {
+			return "", err
+		}
+		if length > len(data)-at-32 {
+			return "", errors.New("byte string exceeds the input")
+		}
+		value := data[at+32 : at+32+length]
+		if t.kind == abiString {
+			return strconv.Quote(string(value)), nil
+		}
+		return "0x" + hex.EncodeToString(value), nil
+	}
+
+	word, err := readWord(data, at)
+	if err != nil {
+		return "", err
+	}
+	switch t.kind {
+	case abiUint:
+		return new(big.Int).SetBytes(word).String(), nil
+	case abiInt:
+		value := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 { // Two's complement
+			value.Sub(value, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return value.String(), nil
+	case abiAddress:
+		return "0x" + hex.EncodeToString(word[12:]), nil
+	case abiBool:
+		return strconv.FormatBool(word[31] != 0), nil
+	default: // abiFixedBytes
+		return "0x" + hex.EncodeToString(word[:t.size]), nil
+	}
+}
+
+func readWord(data []byte, at int) ([]byte, error) {
+	if at < 0 || at > len(data)-32 {
+		return nil, errors.New("value exceeds the input")
+	}
+	return data[at : at+32], nil
+}
+
+// readLength reads a word holding an offset or a length.
+func readLength(data []byte, at int) (int, error) {
+	word, err := readWord(data, at)
+	if err != nil {
+		return 0, err
+	}
+	n := new(big.Int).SetBytes(word)
+	if !n.IsInt64() || n.Int64() > int64(len(data)) {
+		return 0, errors.New("offset or length exceeds the input")
+	}
+	return int(n.Int64()), nil
+}
@@ -0,0 +1,358 @@
+package eth_parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Alert is raised when a record meets a rule.
+type Alert struct {
+	Rule        string      `json:"rule"`
+	Message     string      `json:"message"`
+	Transaction Transaction `json:"transaction"`     // The record that raised the alert, the last one of rate rules
+	Count       int         `json:"count,omitempty"` // Records matched within the window of rate rules
+	At          time.Time   `json:"at"`
+}
+
+func (a Alert) String() string {
+	return fmt.Sprintf("[%s] %s", a.Rule, a.Message)
+}
+
+// AlertSink delivers alerts, e.g. to a chat or paging service.
+type AlertSink interface {
+	Send(alert Alert) error
+}
+
+// TxHistory is where rules look up past records, e.g. a Storage.
+type TxHistory interface {
+	QueryTransactions(q TxQuery) (TxPage, error)
+}
+
+// DefaultDedupWindow is the DedupWindow of new engines
+const DefaultDedupWindow = time.Hour
+
+const (
+	alertQueueSize  = 100
+	maxRecentAlerts = 100
+)
+
+// AlertEngine evaluates rules against records as they are emitted, and
+// delivers their alerts to its sinks. Alerts of the same rule and record, or
+// of the same rule and subscriber for rate rules, are only delivered once
+// per DedupWindow, so e.g. a pending transaction being mined doesn't alert
+// twice. Times are those of the blocks, or the current time for pending
+// records.
+type AlertEngine struct {
+	DedupWindow time.Duration
+	Logger      *slog.Logger // Of delivery failures, slog.Default() when created
+
+	mu     sync.Mutex
+	rules  []Rule
+	rates  map[rateKey][]rateHit
+	fired  map[string]time.Time // Until when alerts are suppressed, by dedup key
+	recent []Alert              // The last maxRecentAlerts, oldest first
+
+	sinks     []AlertSink
+	queue     chan Alert
+	startOnce sync.Once
+	stopped   bool
+	done      chan struct{}
+}
+
+type rateKey struct {
+	rule       string
+	subscriber string
+}
+
+type rateHit struct {
+	at   time.Time
+	hash string
+}
+
+func NewAlertEngine(sinks ...AlertSink) *AlertEngine {
+	return &AlertEngine{
+		DedupWindow: DefaultDedupWindow,
+		Logger:      slog.Default(),
+		rates:       make(map[rateKey][]rateHit),
+		fired:       make(map[string]time.Time),
+		sinks:       sinks,
+		queue:       make(chan Alert, alertQueueSize),
+		done:        make(chan struct{}),
+	}
+}
+
+// AddRule validates and adds a rule, whose name must be unique.
+func (e *AlertEngine) AddRule(rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if r.Name == rule.Name {
+			return fmt.Errorf("rule %s already exists", rule.Name)
+		}
+	}
+	e.rules = append(e.rules, rule)
+	return nil
+}
+
+func (e *AlertEngine) RemoveRule(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, r := range e.rules {
+		if r.Name == name {
+			e.rules = append(e.rules[:i], e.rules[i+1:]...)
+			for key := range e.rates {
+				if key.rule == name {
+					delete(e.rates, key)
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Rules returns the rules in the order they were added.
+func (e *AlertEngine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Rule(nil), e.rules...)
+}
+
+// Recent returns the last alerts raised, oldest first.
+func (e *AlertEngine) Recent() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Alert(nil), e.recent...)
+}
+
+// Evaluate checks tx against every rule and queues the alerts it raises.
+// It doesn't wait for the sinks, so it can run on the monitor goroutine.
+func (e *AlertEngine) Evaluate(tx Transaction, history TxHistory) {
+	at := time.Now().UTC()
+	if tx.Timestamp != "" {
+		at = tx.Time()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return
+	}
+	var alerts []Alert
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if !rule.matches(&tx) || (rule.NewCounterparty && !e.isNewCounterparty(&tx, history)) {
+			continue
+		}
+		alert := Alert{Rule: rule.Name, Transaction: tx, At: at, Message: describe(&tx)}
+		key := rule.Name + "/" + tx.Subscriber + "/" + tx.Hash
+		if rule.RateCount > 0 {
+			if alert.Count = e.hit(rule, &tx, at); alert.Count <= rule.RateCount {
+				continue
+			}
+			alert.Message = fmt.Sprintf("%d transactions of %s within %s, the last one %s",
+				alert.Count, withLabel(tx.Subscriber, tx.SubscriberLabel()), rule.RateWindow, alert.Message)
+			key = rule.Name + "/" + tx.Subscriber
+		}
+		if e.duplicate(rule, key, at) {
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	if len(alerts) == 0 {
+		return
+	}
+	e.startOnce.Do(func() { go e.deliver() })
+	for _, alert := range alerts {
+		e.recent = append(e.recent, alert)
+		if len(e.recent) > maxRecentAlerts {
+			e.recent = e.recent[1:]
+		}
+		select {
+		case e.queue <- alert:
+		default: // The sinks are too slow, Recent still has it
+			e.Logger.Warn("alert queue full, dropping alert", "rule", alert.Rule, "address", alert.Transaction.Subscriber, "hash", alert.Transaction.Hash)
+		}
+	}
+}
+
+// hit records a match of a rate rule and returns the distinct records
+// matched within its window.
+func (e *AlertEngine) hit(rule *Rule, tx *Transaction, at time.Time) int {
+	key := rateKey{rule.Name, tx.Subscriber}
+	hits := e.rates[key][:0]
+	seen := false
+	for _, h := range e.rates[key] {
+		if at.Sub(h.at) < rule.RateWindow {
+			hits = append(hits, h)
+			seen = seen || h.hash == tx.Hash
+		}
+	}
+	if !seen {
+		hits = append(hits, rateHit{at, tx.Hash})
+	}
+	e.rates[key] = hits
+	return len(hits)
+}
+
+// duplicate tells whether the alert of key was already delivered within the
+// cooldown of the rule, recording the delivery otherwise.
+func (e *AlertEngine) duplicate(rule *Rule, key string, at time.Time) bool {
+	if until, ok := e.fired[key]; ok && at.Before(until) {
+		return true
+	}
+	for k, until := range e.fired { // Alerts are rare, so pruning on each is cheap
+		if !at.Before(until) {
+			delete(e.fired, k)
+		}
+	}
+	window := rule.Cooldown
+	if window == 0 {
+		window = e.DedupWindow
+	}
+	e.fired[key] = at.Add(window)
+	return false
+}
+
+// isNewCounterparty tells whether the subscriber of tx has no other record
+// with its counterparty.
+func (e *AlertEngine) isNewCounterparty(tx *Transaction, history TxHistory) bool {
+	counterparty, _ := tx.Counterparty()
+	if history == nil || counterparty == "" || strings.EqualFold(counterparty, tx.Subscriber) {
+		return false
+	}
+	page, err := history.QueryTransactions(TxQuery{Address: tx.Subscriber, Counterparties: []string{counterparty}})
+	if err != nil {
+		e.Logger.Warn("failed to look up the history of a counterparty", "address", tx.Subscriber, "counterparty", counterparty, "error", err)
+		return false
+	}
+	for _, past := range page.Transactions {
+		if past.Hash != tx.Hash {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *AlertEngine) deliver() {
+	defer close(e.done)
+	for alert := range e.queue {
+		for _, sink := range e.sinks {
+			if err := sink.Send(alert); err != nil {
+				e.Logger.Warn("failed to deliver alert", "rule", alert.Rule, "sink", fmt.Sprintf("%T", sink), "error", err)
+			}
+		}
+	}
+}
+
+// Stop delivers the queued alerts and stops the engine, which raises no
+// alerts afterwards.
+func (e *AlertEngine) Stop() {
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return
+	}
+	e.stopped = true
+	e.startOnce.Do(func() { go e.deliver() })
+	close(e.queue)
+	e.mu.Unlock()
+	<-e.done
+}
+
+// describe summarizes a record for alert messages, e.g. "outgoing transfer
+// of 1.00000000 ETH from 0x... (Treasury) to 0x..., tx 0x...".
+func describe(tx *Transaction) string {
+	var what []string
+	if direction := tx.TxDirection(); direction != DirectionAny {
+		what = append(what, string(direction))
+	}
+	if tx.EventKind() == KindNativeTransfer {
+		what = append(what, "transfer of "+tx.ETHAmount())
+	} else {
+		what = append(what, strings.ReplaceAll(string(tx.EventKind()), "_", " "))
+	}
+	if tx.Call != nil && tx.Call.Method != "" {
+		what = append(what, "calling "+tx.Call.Method)
+	}
+	return fmt.Sprintf("%s from %s to %s, tx %s", strings.Join(what, " "),
+		withLabel(tx.From, tx.FromLabel), withLabel(tx.To, tx.ToLabel), tx.Hash)
+}
+
+func withLabel(address, label string) string {
+	if label == "" {
+		return address
+	}
+	return address + " (" + label + ")"
+}
+
+// LogSink logs alerts as warnings.
+type LogSink struct {
+	Logger *slog.Logger // slog.Default() when nil
+}
+
+func (s LogSink) Send(alert Alert) error {
+	logger := s.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Warn("alert", "rule", alert.Rule, "message", alert.Message, "address", alert.Transaction.Subscriber, "hash", alert.Transaction.Hash)
+	return nil
+}
+
+// WriterSink prints alerts as lines, e.g. to stdout.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Send(alert Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintf(s.w, "ALERT %s %s\n", alert.At.Format(time.RFC3339), alert)
+	return err
+}
+
+// WebhookSink posts alerts as JSON to a URL, such as a chat webhook or an
+// incident service.
+type WebhookSink struct {
+	HTTPClient *http.Client
+	URL        string
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{HTTPClient: &http.Client{Timeout: 10 * time.Second}, URL: url}
+}
+
+func (s *WebhookSink) Send(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode alert")
+	}
+	resp, err := s.HTTPClient.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to post alert")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package eth_parser
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Receipt holds the outcome of a mined transaction, as returned by
+// eth_getTransactionReceipt, that tells what it cost its sender.
+type Receipt struct {
+	Status            string `json:"status"` // 0x1 on success, 0x0 when reverted
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+	BlobGasUsed       string `json:"blobGasUsed,omitempty"`
+	BlobGasPrice      string `json:"blobGasPrice,omitempty"`
+}
+
+type TransactionReceipt struct {
+	JsonRPC string   `json:"jsonrpc"`
+	ID      int      `json:"id"`
+	Result  *Receipt `json:"result"` // Null for unknown or pending transactions
+}
+
+// Failed tells whether the transaction reverted, so its value wasn't
+// transferred although its sender paid the fee.
+func (t *Transaction) Failed() bool {
+	return t.Receipt != nil && t.Receipt.Status == "0x0"
+}
+
+// Fee returns the fee paid by the sender, including blob gas. It returns
+// false when the transaction has no receipt to tell it.
+func (t *Transaction) Fee() (*big.Int, bool) {
+	if t.Receipt == nil {
+		return nil, false
+	}
+	fee := new(big.Int).Mul(hexToBig(t.Receipt.GasUsed), hexToBig(t.Receipt.EffectiveGasPrice))
+	blobFee := new(big.Int).Mul(hexToBig(t.Receipt.BlobGasUsed), hexToBig(t.Receipt.BlobGasPrice))
+	return fee.Add(fee, blobFee), true
+}
+
+// BalanceDelta returns how much the ETH balance of address changed with tx:
+// the value it received or sent, minus the fee when it sent tx. It returns
+// false when address sent tx but its fee is unknown, leaving it out.
+func BalanceDelta(address string, tx *Transaction) (*big.Int, bool) {
+	delta := new(big.Int)
+	if !tx.Failed() {
+		if tx.To == address {
+			delta.Add(delta, tx.ValueWei())
+		}
+		if tx.From == address {
+			delta.Sub(delta, tx.ValueWei())
+		}
+	}
+	if tx.From != address {
+		return delta, true
+	}
+	fee, ok := tx.Fee()
+	if !ok {
+		return delta, false
+	}
+	return delta.Sub(delta, fee), true
+}
+
+// BalanceReport compares the balance of an address tracked from its stored
+// transfers with the one the node reports.
+type BalanceReport struct {
+	Address     string
+	Block       uint64   // Block both balances are taken at
+	AnchorBlock uint64   // Block tracking started from, with the balance the node reported then
+	Tracked     *big.Int // Balance at AnchorBlock plus the stored transfers since
+	Reported    *big.Int // eth_getBalance at Block
+	MissingFees int      // Transfers sent since AnchorBlock without a receipt, whose fee Tracked lacks
+	Chain       uint64   // Balances are in the smallest unit of its native currency
+}
+
+// Discrepancy is the part of the reported balance the stored transfers
+// don't explain. Internal transfers from contracts, withdrawals and block
+// rewards are never part of the block transactions, so they show up here.
+func (r BalanceReport) Discrepancy() *big.Int {
+	return new(big.Int).Sub(r.Reported, r.Tracked)
+}
+
+type balanceAnchor struct {
+	block uint64
+	wei   *big.Int
+}
+
+// balanceBook keeps the balance tracking started from per address.
+type balanceBook struct {
+	mu      sync.Mutex
+	anchors map[string]balanceAnchor
+}
+
+func (b *balanceBook) get(address string) (balanceAnchor, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	anchor, ok := b.anchors[address]
+	return anchor, ok
+}
+
+func (b *balanceBook) set(address string, anchor balanceAnchor) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.anchors == nil {
+		b.anchors = make(map[string]balanceAnchor)
+	}
+	b.anchors[address] = anchor
+}
+
+func (b *balanceBook) drop(address string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.anchors, address)
+}
+
+// Errors of Balance about the request rather than the node, which can be
+// told apart with errors.Is.
+var (
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrNotSubscribed  = errors.New("not subscribed")
+	ErrChainRequired  = errors.New("balances are tracked per chain")
+)
+
+// Balance reconciles the tracked balance of a subscribed address with the
+// one the node reports at the last processed block. Tracking starts at the
+// first reconciliation, so the first report never shows a discrepancy.
+func (ep *EthereumParser) Balance(address string) (BalanceReport, error) {
+	address = normalizeAddress(address)
+	if !validAddress.MatchString(address) {
+		return BalanceReport{}, fmt.Errorf("%w %q", ErrInvalidAddress, address)
+	}
+	if !ep.isSubscribed(address) {
+		return BalanceReport{}, fmt.Errorf("%w to %s", ErrNotSubscribed, address)
+	}
+	return ep.balanceAt(address, ep.storage.GetLastProcessedBlockNum())
+}
+
+func (ep *EthereumParser) balanceAt(address string, blockNum uint64) (BalanceReport, error) {
+	reported, err := ep.Client.FetchBalance(address, blockNum)
+	if err != nil {
+		return BalanceReport{}, errors.Wrapf(err, "failed to fetch the balance of %s", address)
+	}
+	anchor, ok := ep.balances.get(address)
+	if !ok || anchor.block > blockNum {
+		anchor = balanceAnchor{block: blockNum, wei: reported}
+		ep.balances.set(address, anchor)
+	}
+
+	report := BalanceReport{
+		Address:     address,
+		Block:       blockNum,
+		AnchorBlock: anchor.block,
+		Tracked:     new(big.Int).Set(anchor.wei),
+		Reported:    reported,
+		Chain:       ep.Chain.ChainID,
+	}
+	for _, tx := range ep.storage.GetTransactions(address) {
+		if tx.EventKind() != KindNativeTransfer || tx.BlockNum() <= anchor.block || tx.BlockNum() > blockNum {
+			continue
+		}
+		delta, ok := BalanceDelta(address, &tx)
+		if !ok {
+			report.MissingFees++
+		}
+		report.Tracked.Add(report.Tracked, delta)
+	}
+	return report, nil
+}
+
+// reconcileBalances reconciles every subscribed address at blockNum, logging
+// discrepancies, and tracks their balance from there on so each discrepancy
+// is only reported once.
+func (ep *EthereumParser) reconcileBalances(blockNum uint64) {
+	for _, sub := range ep.storage.ListSubscriptions() {
+		report, err := ep.balanceAt(sub.Address, blockNum)
+		if err != nil {
+			ep.Logger.Warn("failed to reconcile balance", "address", sub.Address, "block", blockNum, "error", err)
+			continue
+		}
+		if discrepancy := report.Discrepancy(); discrepancy.Sign() != 0 {
+			ep.Logger.Warn("balance discrepancy", "address", sub.Address, "wei", discrepancy.String(), "from_block", report.AnchorBlock, "to_block", blockNum)
+		}
+		ep.balances.set(sub.Address, balanceAnchor{block: blockNum, wei: report.Reported})
+	}
+}
+
+// fetchReceipt attaches the receipt of tx, which tells its fee and whether
+// it reverted. Failures are logged and leave tx without it.
+func (ep *EthereumParser) fetchReceipt(tx *Transaction) {
+	receipt, err := ep.Client.FetchTransactionReceipt(tx.Hash)
+	if err != nil {
+		ep.Logger.Warn("failed to fetch transaction receipt", "hash", tx.Hash, "error", err)
+		return
+	}
+	tx.Receipt = receipt
+}
+
+func hexToBig(hex string) *big.Int {
+	value := new(big.Int)
+	if len(hex) > 2 {
+		value.SetString(hex[2:], 16)
+	}
+	return value
+}
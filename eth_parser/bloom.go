@@ -0,0 +1,56 @@
+package eth_parser
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Bloom is the 2048 bit logsBloom of a block. Every log sets three bits for
+// its contract address and three for each of its topics.
+type Bloom [256]byte
+
+// BloomBits are the positions an item sets in a Bloom. Computing them costs a
+// keccak hash, so they are worth caching for items checked on every block.
+type BloomBits [3]uint16
+
+func ParseBloom(hexBloom string) (Bloom, error) {
+	var b Bloom
+	raw, err := hex.DecodeString(strings.TrimPrefix(hexBloom, "0x"))
+	if err != nil || len(raw) != len(b) {
+		return b, fmt.Errorf("invalid logs bloom %q", hexBloom)
+	}
+	copy(b[:], raw)
+	return b, nil
+}
+
+func BloomBitsOf(item []byte) BloomBits {
+	h := Keccak256(item)
+	var bits BloomBits
+	for i := range bits {
+		bits[i] = (uint16(h[2*i])<<8 | uint16(h[2*i+1])) & 2047
+	}
+	return bits
+}
+
+// Test reports whether the item may be in the bloom. False positives are
+// possible, false negatives are not.
+func (b *Bloom) Test(bits BloomBits) bool {
+	for _, bit := range bits {
+		if b[len(b)-1-int(bit/8)]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Add sets the bits of an item, mostly useful to build blooms in tests.
+func (b *Bloom) Add(bits BloomBits) {
+	for _, bit := range bits {
+		b[len(b)-1-int(bit/8)] |= 1 << (bit % 8)
+	}
+}
+
+func (b Bloom) Hex() string {
+	return "0x" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,630 @@
+package eth_parser
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ReadAddressList reads one address per line, ignoring blank lines and
+// lines starting with #. Validation is left to the parser.
+func ReadAddressList(r io.Reader) ([]Subscription, error) {
+	var subs []Subscription
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		subs = append(subs, Subscription{Address: line})
+	}
+	return subs, scanner.Err()
+}
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSON  Format = "json"  // Subscriptions only, a single array
+	FormatJSONL Format = "jsonl" // Transactions only, one object per line
+)
+
+// FormatOf guesses the format of a file from its extension, defaulting to CSV.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	default:
+		return FormatCSV
+	}
+}
+
+// RejectedEntry is an input entry left out of an import. Line is the line
+// of CSV and JSON Lines input, or the position within a JSON array.
+type RejectedEntry struct {
+	Line   int
+	Reason string
+}
+
+type ImportReport struct {
+	Read     int // Entries found in the input
+	Imported int
+	Rejected []RejectedEntry
+}
+
+func (r *ImportReport) reject(line int, format string, args ...interface{}) {
+	r.Rejected = append(r.Rejected, RejectedEntry{Line: line, Reason: fmt.Sprintf(format, args...)})
+}
+
+// sortRejected orders the rejections by line, as entries that can't be
+// parsed are rejected before the others are validated.
+func (r *ImportReport) sortRejected() {
+	sort.SliceStable(r.Rejected, func(i, j int) bool { return r.Rejected[i].Line < r.Rejected[j].Line })
+}
+
+var subscriptionColumns = []string{"address", "label", "start_block", "created_at", "tags", "chain"}
+
+// ImportSubscriptions subscribes to every valid entry of a CSV or JSON
+// subscription list. Malformed entries, duplicates and addresses that are
+// already subscribed are reported instead of failing the whole import; the
+// error is only set when the input can't be read at all. On a multichain
+// parser an address is subscribed on each chain separately, so entries of
+// the same address on different chains aren't duplicates.
+//
+// CSV input may start with a header naming the columns in any order, out of
+// address, label, start_block, created_at, tags (separated by ;) and chain.
+// Without it the columns are expected in that order.
+func ImportSubscriptions(p Parser, r io.Reader, format Format) (ImportReport, error) {
+	var report ImportReport
+	var entries []lineEntry[Subscription]
+	var err error
+	switch format {
+	case FormatCSV:
+		entries, err = readSubscriptionsCSV(r, &report)
+	case FormatJSON:
+		entries, err = readSubscriptionsJSON(r, &report)
+	default:
+		return report, errors.Errorf("unsupported subscription format %q", format)
+	}
+	if err != nil {
+		return report, err
+	}
+	report.Read += len(entries)
+
+	keys := newSubscriptionKeys(p)
+	subscribed := make(map[subscriptionKey]bool)
+	for _, sub := range p.ListSubscriptions() {
+		for _, key := range keys.of(sub.Address, sub.Chain) {
+			subscribed[key] = true
+		}
+	}
+	seen := make(map[subscriptionKey]int)
+	var subs []Subscription
+	for _, entry := range entries {
+		address := normalizeAddress(entry.value.Address)
+		entryKeys := keys.of(address, entry.value.Chain)
+		switch {
+		case !validAddress.MatchString(address):
+			report.reject(entry.line, "invalid address %q", entry.value.Address)
+		case firstLine(seen, entryKeys) != 0:
+			report.reject(entry.line, "duplicate of line %d", firstLine(seen, entryKeys))
+		case allSet(subscribed, entryKeys):
+			report.reject(entry.line, "already subscribed to %s", address)
+		default:
+			for _, key := range entryKeys {
+				seen[key] = entry.line
+			}
+			subs = append(subs, entry.value)
+		}
+	}
+	if len(subs) > 0 {
+		report.Imported = p.SubscribeMany(subs)
+	}
+	report.sortRejected()
+	return report, nil
+}
+
+// subscriptionKey identifies a subscription, as an address is subscribed
+// on each chain of a multichain parser separately.
+type subscriptionKey struct {
+	address string
+	chain   uint64
+}
+
+// subscriptionKeys maps subscriptions to the keys of the chains they apply
+// to. The chain is left out for parsers following a single chain.
+type subscriptionKeys struct {
+	chains []uint64 // Followed by a multichain parser, nil otherwise
+}
+
+func newSubscriptionKeys(p Parser) subscriptionKeys {
+	var keys subscriptionKeys
+	if router, ok := p.(ChainRouter); ok {
+		for _, chain := range router.Chains() {
+			keys.chains = append(keys.chains, chain.ChainID)
+		}
+	}
+	return keys
+}
+
+// of returns the keys of a subscription, one per chain for a subscription
+// without a chain on a multichain parser.
+func (k subscriptionKeys) of(address string, chain uint64) []subscriptionKey {
+	switch {
+	case k.chains == nil:
+		return []subscriptionKey{{address: address}}
+	case chain != 0:
+		return []subscriptionKey{{address, chain}}
+	}
+	keys := make([]subscriptionKey, len(k.chains))
+	for i, chain := range k.chains {
+		keys[i] = subscriptionKey{address, chain}
+	}
+	return keys
+}
+
+// firstLine returns the line of the first of keys already seen, 0 if none.
+func firstLine(seen map[subscriptionKey]int, keys []subscriptionKey) int {
+	for _, key := range keys {
+		if line := seen[key]; line != 0 {
+			return line
+		}
+	}
+	return 0
+}
+
+func allSet(set map[subscriptionKey]bool, keys []subscriptionKey) bool {
+	for _, key := range keys {
+		if !set[key] {
+			return false
+		}
+	}
+	return true
+}
+
+type lineEntry[T any] struct {
+	line  int
+	value T
+}
+
+func readSubscriptionsCSV(r io.Reader, report *ImportReport) ([]lineEntry[Subscription], error) {
+	records, err := readCSV(r, subscriptionColumns, report)
+	if err != nil {
+		return nil, err
+	}
+	var entries []lineEntry[Subscription]
+	for _, record := range records {
+		sub, err := subscriptionFromRecord(record.value)
+		if err != nil {
+			report.Read++
+			report.reject(record.line, "%v", err)
+			continue
+		}
+		entries = append(entries, lineEntry[Subscription]{record.line, sub})
+	}
+	return entries, nil
+}
+
+func subscriptionFromRecord(record map[string]string) (Subscription, error) {
+	sub := Subscription{Address: record["address"], Label: record["label"]}
+	if v := record["start_block"]; v != "" {
+		startBlock, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return sub, errors.Errorf("invalid start_block %q", v)
+		}
+		sub.StartBlock = startBlock
+	}
+	if v := record["created_at"]; v != "" {
+		createdAt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return sub, errors.Errorf("invalid created_at %q, expected RFC 3339", v)
+		}
+		sub.CreatedAt = createdAt
+	}
+	if v := record["chain"]; v != "" {
+		chain, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return sub, errors.Errorf("invalid chain %q", v)
+		}
+		sub.Chain = chain
+	}
+	if v := record["tags"]; v != "" {
+		for _, tag := range strings.Split(v, ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				sub.Tags = append(sub.Tags, tag)
+			}
+		}
+	}
+	return sub, nil
+}
+
+func readSubscriptionsJSON(r io.Reader, report *ImportReport) ([]lineEntry[Subscription], error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, errors.Wrap(err, "expected a JSON array of subscriptions")
+	}
+	var entries []lineEntry[Subscription]
+	for i, msg := range raw {
+		var sub Subscription
+		if err := json.Unmarshal(msg, &sub); err != nil {
+			report.Read++
+			report.reject(i+1, "%v", err)
+			continue
+		}
+		entries = append(entries, lineEntry[Subscription]{i + 1, sub})
+	}
+	return entries, nil
+}
+
+// ExportSubscriptions writes every subscription in a format
+// ImportSubscriptions reads back.
+func ExportSubscriptions(p Parser, w io.Writer, format Format) error {
+	subs := p.ListSubscriptions()
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(subscriptionColumns)
+		for _, sub := range subs {
+			var startBlock, createdAt string
+			if sub.StartBlock > 0 {
+				startBlock = strconv.FormatUint(sub.StartBlock, 10)
+			}
+			if !sub.CreatedAt.IsZero() {
+				createdAt = sub.CreatedAt.Format(time.RFC3339)
+			}
+			cw.Write([]string{sub.Address, sub.Label, startBlock, createdAt, strings.Join(sub.Tags, ";"), chainRecord(sub.Chain)})
+		}
+		cw.Flush()
+		return errors.Wrap(cw.Error(), "failed to write subscriptions")
+	case FormatJSON:
+		if subs == nil {
+			subs = []Subscription{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(subs), "failed to write subscriptions")
+	default:
+		return errors.Errorf("unsupported subscription format %q", format)
+	}
+}
+
+var transactionColumns = []string{
+	"subscriber", "kind", "status", "direction", "hash", "block_number", "block_hash", "transaction_index",
+	"timestamp", "from", "to", "value", "gas", "gas_price", "nonce", "input", "v", "r", "s",
+	"log_address", "log_index", "log_topics", "log_data", "fiat", "chain",
+}
+
+var validHash = regexp.MustCompile(`^0x[a-fA-F0-9]{64}$`)
+
+// ImportTransactions stores the transaction history of a CSV or JSON Lines
+// export. Records of addresses that aren't subscribed or lacking the fields
+// that identify them are reported as rejected. Records already in the
+// storage are replaced but not counted as imported, so importing the same
+// history twice is harmless.
+func ImportTransactions(p Parser, r io.Reader, format Format) (ImportReport, error) {
+	var report ImportReport
+	var entries []lineEntry[Transaction]
+	var err error
+	switch format {
+	case FormatCSV:
+		entries, err = readTransactionsCSV(r, &report)
+	case FormatJSONL:
+		entries, err = readTransactionsJSONL(r, &report)
+	default:
+		return report, errors.Errorf("unsupported transaction format %q", format)
+	}
+	if err != nil {
+		return report, err
+	}
+	report.Read += len(entries)
+
+	subscribed := make(map[string]bool)
+	for _, sub := range p.ListSubscriptions() {
+		subscribed[sub.Address] = true
+	}
+	var txs []Transaction
+	for _, entry := range entries {
+		tx := entry.value
+		tx.Subscriber = normalizeAddress(tx.Subscriber)
+		if reason := invalidTransaction(&tx); reason != "" {
+			report.reject(entry.line, "%s", reason)
+			continue
+		}
+		if !subscribed[tx.Subscriber] {
+			report.reject(entry.line, "not subscribed to %s", tx.Subscriber)
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	if len(txs) > 0 {
+		report.Imported = p.ImportTransactions(txs)
+	}
+	report.sortRejected()
+	return report, nil
+}
+
+func invalidTransaction(tx *Transaction) string {
+	switch {
+	case !validAddress.MatchString(tx.Subscriber):
+		return fmt.Sprintf("invalid subscriber %q", tx.Subscriber)
+	case !validHash.MatchString(tx.Hash):
+		return fmt.Sprintf("invalid hash %q", tx.Hash)
+	case !isHexQuantity(tx.BlockNumber):
+		return fmt.Sprintf("invalid block number %q", tx.BlockNumber)
+	case tx.TransactionIndex != "" && !isHexQuantity(tx.TransactionIndex):
+		return fmt.Sprintf("invalid transaction index %q", tx.TransactionIndex)
+	}
+	switch tx.EventKind() {
+	case KindNativeTransfer:
+		if tx.Log != nil {
+			return "unexpected log on a native transfer"
+		}
+	case KindTokenTransfer, KindContractEvent:
+		if tx.Log == nil || !isHexQuantity(tx.Log.LogIndex) {
+			return fmt.Sprintf("%s without a log index", tx.Kind)
+		}
+	default:
+		return fmt.Sprintf("unknown kind %q", tx.Kind)
+	}
+	for currency, value := range tx.Fiat {
+		if _, ok := new(big.Rat).SetString(value); !ok || currency == "" {
+			return fmt.Sprintf("invalid fiat value %q for %q", value, currency)
+		}
+	}
+	return ""
+}
+
+func isHexQuantity(s string) bool {
+	if !strings.HasPrefix(s, "0x") || len(s) == 2 {
+		return false
+	}
+	_, err := strconv.ParseUint(s[2:], 16, 64)
+	return err == nil
+}
+
+func readTransactionsCSV(r io.Reader, report *ImportReport) ([]lineEntry[Transaction], error) {
+	records, err := readCSV(r, transactionColumns, report)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]lineEntry[Transaction], 0, len(records))
+	for _, record := range records {
+		tx, err := transactionFromRecord(record.value)
+		if err != nil {
+			report.Read++
+			report.reject(record.line, "%v", err)
+			continue
+		}
+		entries = append(entries, lineEntry[Transaction]{record.line, tx})
+	}
+	return entries, nil
+}
+
+func transactionFromRecord(record map[string]string) (Transaction, error) {
+	tx := Transaction{
+		Subscriber:       record["subscriber"],
+		Kind:             EventKind(record["kind"]),
+		Status:           TxStatus(record["status"]),
+		Direction:        Direction(record["direction"]),
+		Hash:             record["hash"],
+		BlockNumber:      record["block_number"],
+		BlockHash:        record["block_hash"],
+		TransactionIndex: record["transaction_index"],
+		Timestamp:        record["timestamp"],
+		From:             record["from"],
+		To:               record["to"],
+		Value:            record["value"],
+		Gas:              record["gas"],
+		GasPrice:         record["gas_price"],
+		Nonce:            record["nonce"],
+		Input:            record["input"],
+		V:                record["v"],
+		R:                record["r"],
+		S:                record["s"],
+	}
+	if record["log_address"] != "" || record["log_index"] != "" {
+		tx.Log = &Log{
+			Address:          record["log_address"],
+			Data:             record["log_data"],
+			BlockNumber:      tx.BlockNumber,
+			BlockHash:        tx.BlockHash,
+			TransactionHash:  tx.Hash,
+			TransactionIndex: tx.TransactionIndex,
+			LogIndex:         record["log_index"],
+		}
+		if topics := record["log_topics"]; topics != "" {
+			tx.Log.Topics = strings.Split(topics, ";")
+		}
+	}
+	if fiat := record["fiat"]; fiat != "" {
+		tx.Fiat = make(FiatValues)
+		for _, pair := range strings.Split(fiat, ";") {
+			currency, value, _ := strings.Cut(pair, "=")
+			tx.Fiat[strings.ToUpper(currency)] = value
+		}
+	}
+	if v := record["chain"]; v != "" {
+		chain, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return tx, errors.Errorf("invalid chain %q", v)
+		}
+		tx.Chain = chain
+	}
+	return tx, nil
+}
+
+// chainRecord formats a chain ID, leaving untagged records empty.
+func chainRecord(chain uint64) string {
+	if chain == 0 {
+		return ""
+	}
+	return strconv.FormatUint(chain, 10)
+}
+
+// fiatRecord joins fiat values as CURRENCY=value pairs separated by ";".
+func fiatRecord(fiat FiatValues) string {
+	pairs := make([]string, 0, len(fiat))
+	for currency, value := range fiat {
+		pairs = append(pairs, currency+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+func transactionRecord(tx *Transaction) []string {
+	record := []string{
+		tx.Subscriber, string(tx.Kind), string(tx.Status), string(tx.Direction), tx.Hash, tx.BlockNumber, tx.BlockHash, tx.TransactionIndex,
+		tx.Timestamp, tx.From, tx.To, tx.Value, tx.Gas, tx.GasPrice, tx.Nonce, tx.Input, tx.V, tx.R, tx.S,
+		"", "", "", "", fiatRecord(tx.Fiat), chainRecord(tx.Chain),
+	}
+	if tx.Log != nil {
+		copy(record[len(record)-6:], []string{tx.Log.Address, tx.Log.LogIndex, strings.Join(tx.Log.Topics, ";"), tx.Log.Data})
+	}
+	return record
+}
+
+func readTransactionsJSONL(r io.Reader, report *ImportReport) ([]lineEntry[Transaction], error) {
+	var entries []lineEntry[Transaction]
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024) // Inputs of contract calls can be large
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var tx Transaction
+		if err := json.Unmarshal([]byte(text), &tx); err != nil {
+			report.Read++
+			report.reject(line, "%v", err)
+			continue
+		}
+		entries = append(entries, lineEntry[Transaction]{line, tx})
+	}
+	return entries, errors.Wrap(scanner.Err(), "failed to read transactions")
+}
+
+// ExportTransactions writes the stored history of an address, or of every
+// subscribed address when it is empty, in block order. It returns how many
+// records were written.
+func ExportTransactions(p Parser, w io.Writer, address string, format Format) (int, error) {
+	var write func(tx *Transaction) error
+	var flush func() error
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(transactionColumns)
+		write = func(tx *Transaction) error { return cw.Write(transactionRecord(tx)) }
+		flush = func() error { cw.Flush(); return cw.Error() }
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		write = func(tx *Transaction) error { return enc.Encode(tx) }
+		flush = func() error { return nil }
+	default:
+		return 0, errors.Errorf("unsupported transaction format %q", format)
+	}
+
+	addresses := []string{address}
+	if address == "" {
+		// Queries cover every chain, while a multichain parser lists the
+		// subscriptions of each chain
+		addresses = addresses[:0]
+		listed := make(map[string]bool)
+		for _, sub := range p.ListSubscriptions() {
+			if !listed[sub.Address] {
+				listed[sub.Address] = true
+				addresses = append(addresses, sub.Address)
+			}
+		}
+	}
+
+	written := 0
+	for _, address := range addresses {
+		q := TxQuery{Address: address, Limit: 1000}
+		for {
+			page, err := p.QueryTransactions(q)
+			if err != nil {
+				return written, errors.Wrapf(err, "failed to query the transactions of %s", address)
+			}
+			for i := range page.Transactions {
+				if err := write(&page.Transactions[i]); err != nil {
+					return written, errors.Wrap(err, "failed to write transactions")
+				}
+				written++
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+	}
+	return written, errors.Wrap(flush(), "failed to write transactions")
+}
+
+// readCSV reads records keyed by column name. A header row is recognized by
+// its first field naming one of the columns, otherwise the columns are
+// taken in their default order.
+func readCSV(r io.Reader, columns []string, report *ImportReport) ([]lineEntry[map[string]string], error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	known := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		known[c] = true
+	}
+
+	header := columns
+	var records []lineEntry[map[string]string]
+	for first := true; ; first = false {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Read++
+			report.reject(parseErr.Line, "%v", parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CSV")
+		}
+		line, _ := cr.FieldPos(0)
+
+		if first && known[strings.ToLower(strings.TrimSpace(fields[0]))] {
+			header = make([]string, len(fields))
+			for i, name := range fields {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if !known[name] {
+					return nil, errors.Errorf("unknown column %q in the header", name)
+				}
+				header[i] = name
+			}
+			continue
+		}
+		if len(fields) > len(header) {
+			report.Read++
+			report.reject(line, "expected at most %d fields, got %d", len(header), len(fields))
+			continue
+		}
+		record := make(map[string]string, len(fields))
+		for i, field := range fields {
+			record[header[i]] = strings.TrimSpace(field)
+		}
+		records = append(records, lineEntry[map[string]string]{line, record})
+	}
+}
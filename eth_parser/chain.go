@@ -0,0 +1,133 @@
+package eth_parser
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChainConfig describes an EVM network a parser follows.
+type ChainConfig struct {
+	ChainID        uint64
+	Name           string
+	RPCURLs        []string // Tried in order, moving on to the next one when a node is unreachable
+	NativeSymbol   string
+	NativeDecimals int
+	BlockTime      time.Duration // How often the parser polls for new blocks
+	Confirmations  uint64        // Blocks a block must be buried under before it is processed, 0 follows the head
+}
+
+// Presets of the networks known out of the box. They point at public
+// endpoints, which rate limit heavy use.
+var (
+	Mainnet  = ChainConfig{ChainID: 1, Name: "mainnet", RPCURLs: []string{"https://cloudflare-eth.com"}, NativeSymbol: "ETH", NativeDecimals: 18, BlockTime: 12 * time.Second}
+	Sepolia  = ChainConfig{ChainID: 11155111, Name: "sepolia", RPCURLs: []string{"https://rpc.sepolia.org"}, NativeSymbol: "ETH", NativeDecimals: 18, BlockTime: 12 * time.Second}
+	Polygon  = ChainConfig{ChainID: 137, Name: "polygon", RPCURLs: []string{"https://polygon-rpc.com"}, NativeSymbol: "POL", NativeDecimals: 18, BlockTime: 2 * time.Second}
+	Arbitrum = ChainConfig{ChainID: 42161, Name: "arbitrum", RPCURLs: []string{"https://arb1.arbitrum.io/rpc"}, NativeSymbol: "ETH", NativeDecimals: 18, BlockTime: 1 * time.Second}
+	Base     = ChainConfig{ChainID: 8453, Name: "base", RPCURLs: []string{"https://mainnet.base.org"}, NativeSymbol: "ETH", NativeDecimals: 18, BlockTime: 2 * time.Second}
+)
+
+var chainRegistry = struct {
+	sync.RWMutex
+	byID map[uint64]ChainConfig
+}{byID: make(map[uint64]ChainConfig)}
+
+func init() {
+	for _, c := range []ChainConfig{Mainnet, Sepolia, Polygon, Arbitrum, Base} {
+		RegisterChain(c)
+	}
+}
+
+// RegisterChain makes a chain known to ChainByID and LookupChain, replacing
+// any chain registered with the same ID. NewChainParser registers the chains
+// it is given, so records of custom chains format their amounts right.
+func RegisterChain(c ChainConfig) {
+	chainRegistry.Lock()
+	defer chainRegistry.Unlock()
+	chainRegistry.byID[c.ChainID] = c
+}
+
+func ChainByID(id uint64) (ChainConfig, bool) {
+	chainRegistry.RLock()
+	defer chainRegistry.RUnlock()
+	c, ok := chainRegistry.byID[id]
+	return c, ok
+}
+
+// LookupChain finds a registered chain by name, case insensitively, or by
+// decimal chain ID.
+func LookupChain(nameOrID string) (ChainConfig, bool) {
+	if id, err := strconv.ParseUint(nameOrID, 10, 64); err == nil {
+		return ChainByID(id)
+	}
+	chainRegistry.RLock()
+	defer chainRegistry.RUnlock()
+	for _, c := range chainRegistry.byID {
+		if strings.EqualFold(c.Name, nameOrID) {
+			return c, true
+		}
+	}
+	return ChainConfig{}, false
+}
+
+// RegisteredChains lists the registered chains by chain ID.
+func RegisteredChains() []ChainConfig {
+	chainRegistry.RLock()
+	defer chainRegistry.RUnlock()
+	chains := make([]ChainConfig, 0, len(chainRegistry.byID))
+	for _, c := range chainRegistry.byID {
+		chains = append(chains, c)
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i].ChainID < chains[j].ChainID })
+	return chains
+}
+
+// ChainOf returns the chain a record was tagged with, defaulting to mainnet
+// for untagged records and unknown chains.
+func ChainOf(id uint64) ChainConfig {
+	if c, ok := ChainByID(id); ok {
+		return c
+	}
+	return Mainnet
+}
+
+func (c ChainConfig) Validate() error {
+	switch {
+	case c.ChainID == 0:
+		return fmt.Errorf("chain %q has no chain ID", c.Name)
+	case c.Name == "":
+		return fmt.Errorf("chain %d has no name", c.ChainID)
+	case len(c.RPCURLs) == 0:
+		return fmt.Errorf("chain %s has no RPC endpoint", c.Name)
+	case c.NativeSymbol == "":
+		return fmt.Errorf("chain %s has no native currency symbol", c.Name)
+	case c.NativeDecimals < 0 || c.NativeDecimals > 36:
+		return fmt.Errorf("chain %s has invalid native currency decimals %d", c.Name, c.NativeDecimals)
+	case c.BlockTime <= 0:
+		return fmt.Errorf("chain %s has no block time", c.Name)
+	}
+	return nil
+}
+
+func (c ChainConfig) String() string {
+	return fmt.Sprintf("%s (chain %d)", c.Name, c.ChainID)
+}
+
+// FormatAmount formats an amount in the smallest unit of the native currency,
+// e.g. "1.50000000 POL".
+func (c ChainConfig) FormatAmount(amount *big.Int) string {
+	return FormatUnits(amount, c.NativeDecimals) + " " + c.NativeSymbol
+}
+
+// FormatUnits formats an amount of the smallest unit of a currency with the
+// given decimals, with 8 decimals.
+func FormatUnits(amount *big.Int, decimals int) string {
+	unit := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	value := new(big.Float).Quo(new(big.Float).SetInt(amount), unit)
+
+	return fmt.Sprintf("%.8f", value)
+}
@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/big"
 	"math/rand"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,20 +19,42 @@ import (
 type EthereumClient interface {
 	FetchLatestBlockNumber() (*BlockNumber, error)
 	FetchBlockByNumber(blockNumber uint64) (*Block, error)
+	FetchBlockHeaderByNumber(blockNumber uint64) (*Block, error) // Block without its transactions
+	FetchLogs(filter LogFilter) (*Logs, error)
+	FetchLogRange(filter LogFilter) ([]Log, error) // Splits the block range into chunks the node accepts
+	FetchBalance(address string, blockNumber uint64) (*big.Int, error)
+	FetchTransactionReceipt(hash string) (*Receipt, error)
+	FetchTransactionByHash(hash string) (*Transaction, error) // Nil when the node doesn't know of it
+	NewPendingTransactionFilter() (string, error)
+	FetchFilterChanges(filterID string) ([]string, error)
 }
 
 type EthereumRPCClient struct {
 	HTTPClient          *http.Client
 	EthereumRPCURL      string
+	FallbackURLs        []string // Tried in order when EthereumRPCURL is unreachable
 	RPCVersion          string
 	ReqEncoding         string
 	EthBlockNumber      string
 	EthGetBlockByNumber string
-	seq                 uint64
+	EthGetLogs          string
+	EthGetBalance       string
+	EthGetReceipt       string
+	EthGetTxByHash      string
+	EthNewPendingFilter string
+	EthGetFilterChanges string
+	seq                 atomic.Uint64 // The last JSON-RPC id, the client being shared by goroutines
 
 	// Exponential backoff settings
-	maxAttempts  int // Maximum retriable attempts
-	backoffScale int // Starting backoff scale in seconds
+	MaxAttempts  int           // Maximum retriable attempts
+	BackoffScale time.Duration // Starting backoff scale, 0 retries right away
+
+	// Blocks per eth_getLogs request, halved whenever the node rejects a range
+	LogsChunkSize uint64
+
+	Metrics *Metrics     // Records the latency, outcome and retries of requests, nil disables metrics
+	Chain   string       // Name of the chain in metrics
+	Logger  *slog.Logger // slog.Default() when created, with the name of the chain for chain clients
 }
 
 func NewEthereumClient() EthereumClient {
@@ -39,18 +65,44 @@ func NewEthereumClient() EthereumClient {
 		ReqEncoding:         "application/json",
 		EthBlockNumber:      "eth_blockNumber",
 		EthGetBlockByNumber: "eth_getBlockByNumber",
-		maxAttempts:         5,
-		backoffScale:        1,
-		seq:                 0,
+		EthGetLogs:          "eth_getLogs",
+		EthGetBalance:       "eth_getBalance",
+		EthGetReceipt:       "eth_getTransactionReceipt",
+		EthGetTxByHash:      "eth_getTransactionByHash",
+		EthNewPendingFilter: "eth_newPendingTransactionFilter",
+		EthGetFilterChanges: "eth_getFilterChanges",
+		MaxAttempts:         5,
+		BackoffScale:        1 * time.Second,
+		LogsChunkSize:       2000,
+		Chain:               Mainnet.Name,
+		Logger:              slog.Default(),
 	}
 }
 
-func (ec *EthereumRPCClient) request(method string, params []interface{}) ([]byte, error) {
+// NewChainClient returns a client of the RPC endpoints of chain, the first one
+// being used while it is reachable.
+func NewChainClient(chain ChainConfig) EthereumClient {
+	ec := NewEthereumClient().(*EthereumRPCClient)
+	ec.Chain = chain.Name
+	ec.Logger = ec.Logger.With("chain", chain.Name)
+	if len(chain.RPCURLs) > 0 {
+		ec.EthereumRPCURL = chain.RPCURLs[0]
+		ec.FallbackURLs = chain.RPCURLs[1:]
+	}
+	return ec
+}
+
+func (ec *EthereumRPCClient) request(method string, params []interface{}) (body []byte, err error) {
+	defer func(start time.Time) {
+		ec.Metrics.ObserveSince("eth_rpc_request_duration_seconds", start, ec.Chain, method)
+		ec.Metrics.Add("eth_rpc_requests_total", 1, ec.Chain, method, requestStatus(err))
+	}(time.Now())
+
 	reqBody := map[string]interface{}{
 		"jsonrpc": ec.RPCVersion,
 		"method":  method,
 		"params":  params,
-		"id":      ec.seq,
+		"id":      ec.seq.Add(1),
 	}
 
 	bodyBytes, err := json.Marshal(reqBody)
@@ -58,44 +110,128 @@ func (ec *EthereumRPCClient) request(method string, params []interface{}) ([]byt
 		return nil, errors.Wrap(err, "failed to serialize body")
 	}
 
-	req, err := http.NewRequest("POST", ec.EthereumRPCURL, bytes.NewBuffer(bodyBytes))
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create new request")
+	urls := append([]string{ec.EthereumRPCURL}, ec.FallbackURLs...)
+	for i, url := range urls {
+		var req *http.Request
+		if req, err = http.NewRequest("POST", url, bytes.NewBuffer(bodyBytes)); err != nil {
+			return nil, errors.Wrap(err, "failed to create new request")
+		}
+		req.Header.Set("Content-Type", ec.ReqEncoding)
+
+		if body, err = ec.expBackoff(req, method); err == nil || !isUnreachable(err) {
+			return body, err
+		}
+		if i+1 < len(urls) {
+			ec.Logger.Warn("rpc endpoint unreachable, trying the next one", "method", method, "host", req.URL.Host, "error", err)
+		}
 	}
-	req.Header.Set("Content-Type", ec.ReqEncoding)
+	return nil, err // The error of the last endpoint
+}
 
-	return ec.expBackoff(req)
+// isUnreachable tells whether the node failed to answer at all, as opposed to
+// answering with an error, which any other node would answer too.
+func isUnreachable(err error) bool {
+	var rpcErr *RPCError
+	var statusErr *HTTPStatusError
+	if errors.As(err, &rpcErr) {
+		return false
+	}
+	return !errors.As(err, &statusErr) || shouldRetry(statusErr.StatusCode)
 }
 
-func (ec *EthereumRPCClient) expBackoff(req *http.Request) ([]byte, error) {
+// requestStatus is the outcome of a request in metrics.
+func requestStatus(err error) string {
+	var rpcErr *RPCError
+	var statusErr *HTTPStatusError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &rpcErr):
+		return "rpc_error"
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("http_%d", statusErr.StatusCode)
+	}
+	return "error"
+}
+
+func (ec *EthereumRPCClient) expBackoff(req *http.Request, method string) ([]byte, error) {
 	var resp *http.Response
 	var err error
 	var body []byte
 
-	scale := ec.backoffScale
+	scale := ec.BackoffScale
 
-	for attempt := 0; attempt < ec.maxAttempts; attempt++ {
-		ec.seq++
+	for attempt := 0; attempt < ec.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			ec.Metrics.Add("eth_rpc_retries_total", 1, ec.Chain, method)
+		}
+		if req.GetBody != nil { // Rewind the body consumed by the previous attempt
+			req.Body, _ = req.GetBody()
+		}
 		resp, err = ec.HTTPClient.Do(req)
 		if err == nil {
 			defer resp.Body.Close()
 			if resp.StatusCode == http.StatusOK {
 				body, err = io.ReadAll(resp.Body)
 				if err == nil {
-					return body, errors.Wrap(err, "failed to read from body")
+					if err = checkRPCError(body); !isRateLimited(err) {
+						return body, err
+					}
 				}
 			} else if !shouldRetry(resp.StatusCode) {
-				return nil, errors.Wrapf(err, "received non-retryable status code %d", resp.StatusCode)
+				// Some nodes send their JSON-RPC errors with an HTTP 4xx
+				if errBody, readErr := io.ReadAll(resp.Body); readErr == nil {
+					if rpcErr := checkRPCError(errBody); rpcErr != nil {
+						return nil, rpcErr
+					}
+				}
+				return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
+			} else {
+				err = &HTTPStatusError{StatusCode: resp.StatusCode}
 			}
 		}
 
 		// Calculate and wait for the next attempt's backoff duration
-		wait := time.Duration(rand.Intn(int(scale))) * time.Second
+		var wait time.Duration
+		if scale > 0 {
+			wait = time.Duration(rand.Int63n(int64(scale)))
+		}
+		if attempt+1 < ec.MaxAttempts {
+			ec.Logger.Debug("rpc request failed, retrying", "method", method, "attempt", attempt+1, "backoff", wait, "error", err)
+		}
 		time.Sleep(wait)
 		scale *= 2 // Double the scale for the next attempt
 	}
 
-	return nil, errors.Wrapf(err, "after %d attempts, last error", ec.maxAttempts)
+	return nil, errors.Wrapf(err, "after %d attempts, last error", ec.MaxAttempts)
+}
+
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("received status code %d", e.StatusCode)
+}
+
+// RPCError is a JSON-RPC error returned by the node along with an HTTP 200.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+func checkRPCError(body []byte) error {
+	var resp struct {
+		Error *RPCError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Error != nil {
+		return resp.Error
+	}
+	return nil
 }
 
 func shouldRetry(statusCode int) bool {
@@ -141,3 +277,189 @@ func (ec *EthereumRPCClient) FetchBlockByNumber(blockNumber uint64) (*Block, err
 
 	return block, err
 }
+
+func (ec *EthereumRPCClient) FetchBlockHeaderByNumber(blockNumber uint64) (*Block, error) {
+	blockNumberHex := fmt.Sprintf("0x%x", blockNumber)
+	body, err := ec.request(ec.EthGetBlockByNumber, []interface{}{blockNumberHex, false})
+	if err != nil {
+		return nil, errors.Wrap(err, "request to fetch block header by number failed")
+	}
+
+	// Without full transactions the node returns their hashes, which the
+	// shallower field takes over from the embedded one
+	header := &struct {
+		JsonRPC string `json:"jsonrpc"`
+		ID      int    `json:"id"`
+		Result  struct {
+			BlockResult
+			Transactions []string `json:"transactions"`
+		} `json:"result"`
+	}{}
+	if err := json.Unmarshal(body, header); err != nil {
+		return nil, errors.Wrap(err, "failed on the deserialization of block header")
+	}
+
+	return &Block{JsonRPC: header.JsonRPC, BlockID: header.ID, Result: header.Result.BlockResult}, nil
+}
+
+func (ec *EthereumRPCClient) FetchLogs(filter LogFilter) (*Logs, error) {
+	body, err := ec.request(ec.EthGetLogs, []interface{}{filter.params()})
+	if err != nil {
+		return nil, errors.Wrap(err, "request to fetch logs failed")
+	}
+
+	logs := &Logs{}
+	if err := json.Unmarshal(body, logs); err != nil {
+		return nil, errors.Wrap(err, "failed on the deserialization of logs")
+	}
+
+	return logs, nil
+}
+
+func (ec *EthereumRPCClient) FetchBalance(address string, blockNumber uint64) (*big.Int, error) {
+	blockNumberHex := fmt.Sprintf("0x%x", blockNumber)
+	body, err := ec.request(ec.EthGetBalance, []interface{}{address, blockNumberHex})
+	if err != nil {
+		return nil, errors.Wrap(err, "request to fetch balance failed")
+	}
+
+	balance := &BlockNumber{} // Same shape, a hex quantity result
+	if err := json.Unmarshal(body, balance); err != nil {
+		return nil, errors.Wrap(err, "failed on the deserialization of balance")
+	}
+	wei, ok := new(big.Int).SetString(strings.TrimPrefix(balance.Result, "0x"), 16)
+	if !ok {
+		return nil, errors.Errorf("invalid balance %q", balance.Result)
+	}
+
+	return wei, nil
+}
+
+func (ec *EthereumRPCClient) FetchTransactionReceipt(hash string) (*Receipt, error) {
+	body, err := ec.request(ec.EthGetReceipt, []interface{}{hash})
+	if err != nil {
+		return nil, errors.Wrap(err, "request to fetch transaction receipt failed")
+	}
+
+	receipt := &TransactionReceipt{}
+	if err := json.Unmarshal(body, receipt); err != nil {
+		return nil, errors.Wrap(err, "failed on the deserialization of transaction receipt")
+	}
+	if receipt.Result == nil {
+		return nil, errors.Errorf("no receipt for transaction %s", hash)
+	}
+
+	return receipt.Result, nil
+}
+
+func (ec *EthereumRPCClient) FetchTransactionByHash(hash string) (*Transaction, error) {
+	body, err := ec.request(ec.EthGetTxByHash, []interface{}{hash})
+	if err != nil {
+		return nil, errors.Wrap(err, "request to fetch transaction by hash failed")
+	}
+
+	tx := &struct {
+		Result *Transaction `json:"result"`
+	}{}
+	if err := json.Unmarshal(body, tx); err != nil {
+		return nil, errors.Wrap(err, "failed on the deserialization of transaction")
+	}
+
+	return tx.Result, nil
+}
+
+func (ec *EthereumRPCClient) NewPendingTransactionFilter() (string, error) {
+	body, err := ec.request(ec.EthNewPendingFilter, []interface{}{})
+	if err != nil {
+		return "", errors.Wrap(err, "request to create pending transaction filter failed")
+	}
+
+	filter := &BlockNumber{} // Same shape, a hex quantity result
+	if err := json.Unmarshal(body, filter); err != nil {
+		return "", errors.Wrap(err, "failed on the deserialization of filter id")
+	}
+
+	return filter.Result, nil
+}
+
+func (ec *EthereumRPCClient) FetchFilterChanges(filterID string) ([]string, error) {
+	body, err := ec.request(ec.EthGetFilterChanges, []interface{}{filterID})
+	if err != nil {
+		return nil, errors.Wrap(err, "request to fetch filter changes failed")
+	}
+
+	changes := &struct {
+		Result []string `json:"result"`
+	}{}
+	if err := json.Unmarshal(body, changes); err != nil {
+		return nil, errors.Wrap(err, "failed on the deserialization of filter changes")
+	}
+
+	return changes.Result, nil
+}
+
+func (ec *EthereumRPCClient) FetchLogRange(filter LogFilter) ([]Log, error) {
+	var logs []Log
+	chunkSize := max(ec.LogsChunkSize, 1)
+	for from := filter.FromBlock; from <= filter.ToBlock; {
+		chunk := filter
+		chunk.FromBlock = from
+		chunk.ToBlock = min(from+chunkSize-1, filter.ToBlock)
+
+		result, err := ec.FetchLogs(chunk)
+		if err != nil {
+			if isRangeRejected(err) && chunk.ToBlock > chunk.FromBlock {
+				chunkSize = (chunk.ToBlock - chunk.FromBlock + 1) / 2
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to fetch logs of blocks %d to %d", chunk.FromBlock, chunk.ToBlock)
+		}
+		logs = append(logs, result.Result...)
+		from = chunk.ToBlock + 1
+	}
+	return logs, nil
+}
+
+// isRangeRejected tells whether the node refused a log query for covering
+// too many blocks or results. Providers word it differently, but all of
+// them settle for a smaller range. Rate limits share its error code,
+// -32005, and some of its wording, so only the message tells them apart.
+// A bare HTTP 400 may be about anything in the request, so only an HTTP 413
+// counts without a message.
+func isRangeRejected(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusRequestEntityTooLarge
+	}
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || isRateLimited(err) {
+		return false
+	}
+	msg := strings.ToLower(rpcErr.Message)
+	for _, hint := range []string{"range", "more than", "too large", "response size", "results", "too many blocks"} {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRateLimited tells whether the node turned a request down for exceeding
+// the request rate or quota of the plan, which passes with time, so the
+// request is retried with backoff like an HTTP 429.
+func isRateLimited(err error) bool {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if rpcErr.Code == http.StatusTooManyRequests {
+		return true
+	}
+	msg := strings.ToLower(rpcErr.Message)
+	for _, hint := range []string{"rate limit", "too many requests", "request count", "per second", "capacity"} {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,9 +3,28 @@ package eth_parser
 import (
 	"fmt"
 	"math/big"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// Subscription holds a monitored address and its metadata
+type Subscription struct {
+	Address    string    `json:"address"`
+	Label      string    `json:"label,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	StartBlock uint64    `json:"start_block,omitempty"` // Transactions in blocks before this one are not recorded
+	Tags       []string  `json:"tags,omitempty"`
+	Chain      uint64    `json:"chain,omitempty"` // ID of the chain the address is monitored on
+}
+
+// AddressLabel names an address in the address book, subscribed or not
+type AddressLabel struct {
+	Address string `json:"address"`
+	Label   string `json:"label"`
+}
+
 type Request struct {
 	JsonRPC string        `json:"jsonrpc"`
 	Method  string        `json:"method"`
@@ -50,32 +69,307 @@ type BlockResult struct {
 	Transactions     []Transaction `json:"transactions"`
 	TransactionsRoot string        `json:"transactionsRoot"`
 	Uncles           []interface{} `json:"uncles"`
+
+	// Header fields added by forks, empty in older blocks
+	BaseFeePerGas         string `json:"baseFeePerGas,omitempty"`         // London
+	WithdrawalsRoot       string `json:"withdrawalsRoot,omitempty"`       // Shanghai
+	BlobGasUsed           string `json:"blobGasUsed,omitempty"`           // Cancun
+	ExcessBlobGas         string `json:"excessBlobGas,omitempty"`         // Cancun
+	ParentBeaconBlockRoot string `json:"parentBeaconBlockRoot,omitempty"` // Cancun
+	RequestsHash          string `json:"requestsHash,omitempty"`          // Prague
+}
+
+// EventKind tells which kind of event a stored record describes
+type EventKind string
+
+const (
+	KindNativeTransfer EventKind = "transfer"
+	KindTokenTransfer  EventKind = "token_transfer" // ERC-20 Transfer event
+	KindContractEvent  EventKind = "contract_event" // Any event emitted by a subscribed contract
+)
+
+// TransferEventTopic is keccak256("Transfer(address,address,uint256)")
+const TransferEventTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// Direction of a transfer from the point of view of the subscriber
+type Direction string
+
+const (
+	DirectionAny      Direction = ""
+	DirectionIncoming Direction = "incoming"
+	DirectionOutgoing Direction = "outgoing"
+	DirectionSelf     Direction = "self" // The subscriber sent to itself
+)
+
+// ParseDirection reads a direction filter, either in full or as in or out.
+func ParseDirection(value string) (Direction, error) {
+	switch value {
+	case "":
+		return DirectionAny, nil
+	case "in", string(DirectionIncoming):
+		return DirectionIncoming, nil
+	case "out", string(DirectionOutgoing):
+		return DirectionOutgoing, nil
+	case string(DirectionSelf):
+		return DirectionSelf, nil
+	}
+	return DirectionAny, fmt.Errorf("invalid direction %q", value)
 }
 
+// TxStatus tracks the lifecycle of a stored record
+type TxStatus string
+
+const (
+	StatusMined    TxStatus = "mined"
+	StatusPending  TxStatus = "pending"  // Seen in the mempool, not mined yet
+	StatusReplaced TxStatus = "replaced" // Another transaction of the same sender and nonce was mined instead
+	StatusDropped  TxStatus = "dropped"  // The node no longer knows of it
+)
+
 type Transaction struct {
-	Subscriber       string // Additional field added to identify the subscriber party of the tx
-	BlockHash        string `json:"blockHash"`
-	BlockNumber      string `json:"blockNumber"`
-	From             string `json:"from"`
-	Gas              string `json:"gas"`
-	GasPrice         string `json:"gasPrice"`
-	Hash             string `json:"hash"`
-	Input            string `json:"input"`
-	Nonce            string `json:"nonce"`
-	To               string `json:"to"`
-	TransactionIndex string `json:"transactionIndex"`
-	Value            string `json:"value"`
-	V                string `json:"v"`
-	R                string `json:"r"`
-	S                string `json:"s"`
+	Subscriber       string      `json:"subscriber,omitempty"`   // Additional field added to identify the subscriber party of the tx
+	Timestamp        string      `json:"timestamp,omitempty"`    // Additional field carrying the timestamp of the block holding the tx
+	Kind             EventKind   `json:"kind,omitempty"`         // Additional field, empty is treated as a native transfer
+	Status           TxStatus    `json:"status,omitempty"`       // Additional field updated through Storage.UpsertTransaction
+	Direction        Direction   `json:"direction,omitempty"`    // Additional field, relative to the subscriber
+	Log              *Log        `json:"log,omitempty"`          // Additional field, the event behind log based records
+	Call             *Call       `json:"call,omitempty"`         // Additional field, the decoded Input of contract calls
+	SenderCheck      SenderCheck `json:"sender_check,omitempty"` // Additional field set when senders are verified
+	Fiat             FiatValues  `json:"fiat,omitempty"`         // Additional field, the value at block time per fiat currency
+	Receipt          *Receipt    `json:"receipt,omitempty"`      // Additional field set when balances are tracked
+	ReplacedBy       string      `json:"replaced_by,omitempty"`  // Additional field, the hash of the transaction mined instead
+	Chain            uint64      `json:"chain,omitempty"`        // Additional field, the ID of the chain the record was made on
+	FromLabel        string      `json:"from_label,omitempty"`   // Additional field, the label of From when the record is returned
+	ToLabel          string      `json:"to_label,omitempty"`     // Additional field, the label of To when the record is returned
+	BlockHash        string      `json:"blockHash"`
+	BlockNumber      string      `json:"blockNumber"`
+	From             string      `json:"from"`
+	Gas              string      `json:"gas"`
+	GasPrice         string      `json:"gasPrice"`
+	Hash             string      `json:"hash"`
+	Input            string      `json:"input"`
+	Nonce            string      `json:"nonce"`
+	To               string      `json:"to"`
+	TransactionIndex string      `json:"transactionIndex"`
+	Value            string      `json:"value"`
+	V                string      `json:"v"`
+	R                string      `json:"r"`
+	S                string      `json:"s"`
+
+	// Fields of typed transactions (EIP-2718), which the signature covers
+	Type                 string          `json:"type,omitempty"`
+	ChainID              string          `json:"chainId,omitempty"`
+	YParity              string          `json:"yParity,omitempty"`
+	MaxFeePerGas         string          `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string          `json:"maxPriorityFeePerGas,omitempty"`
+	AccessList           []AccessTuple   `json:"accessList,omitempty"`
+	MaxFeePerBlobGas     string          `json:"maxFeePerBlobGas,omitempty"`
+	BlobVersionedHashes  []string        `json:"blobVersionedHashes,omitempty"`
+	AuthorizationList    []Authorization `json:"authorizationList,omitempty"`
+}
+
+// FiatValues maps fiat currencies to a value with 2 decimals, e.g. "USD": "2500.00"
+type FiatValues map[string]string
+
+// String lists the values sorted by currency, e.g. "2300.00 EUR, 2500.00 USD".
+func (f FiatValues) String() string {
+	currencies := make([]string, 0, len(f))
+	for currency := range f {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	values := make([]string, len(currencies))
+	for i, currency := range currencies {
+		values[i] = f[currency] + " " + currency
+	}
+	return strings.Join(values, ", ")
+}
+
+type AccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
+}
+
+// Authorization lets an account delegate to contract code (EIP-7702).
+type Authorization struct {
+	ChainID string `json:"chainId"`
+	Address string `json:"address"`
+	Nonce   string `json:"nonce"`
+	YParity string `json:"yParity"`
+	R       string `json:"r"`
+	S       string `json:"s"`
+}
+
+type Log struct {
+	Address          string   `json:"address"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash"`
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	LogIndex         string   `json:"logIndex"`
+	Removed          bool     `json:"removed"`
+}
+
+type Logs struct {
+	JsonRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Result  []Log  `json:"result"`
 }
 
+// LogFilter mirrors the eth_getLogs filter object. Either a block range or a
+// block hash is used. Topics are positional, each position matching any of
+// its values, and an empty position matching anything.
+type LogFilter struct {
+	FromBlock uint64
+	ToBlock   uint64
+	BlockHash string
+	Addresses []string
+	Topics    [][]string
+}
+
+func (f LogFilter) params() map[string]interface{} {
+	params := map[string]interface{}{}
+	if f.BlockHash != "" {
+		params["blockHash"] = f.BlockHash
+	} else {
+		params["fromBlock"] = fmt.Sprintf("0x%x", f.FromBlock)
+		params["toBlock"] = fmt.Sprintf("0x%x", f.ToBlock)
+	}
+	if len(f.Addresses) > 0 {
+		params["address"] = f.Addresses
+	}
+	if len(f.Topics) > 0 {
+		topics := make([]interface{}, len(f.Topics))
+		for i, position := range f.Topics {
+			if len(position) > 0 {
+				topics[i] = position
+			}
+		}
+		params["topics"] = topics
+	}
+	return params
+}
+
+// TopicAddress extracts an address from an indexed event parameter.
+func TopicAddress(topic string) string {
+	if len(topic) < 40 {
+		return ""
+	}
+	return "0x" + strings.ToLower(topic[len(topic)-40:])
+}
+
+// AddressTopic pads an address to the 32 bytes of an indexed event parameter.
+func AddressTopic(address string) string {
+	return "0x" + strings.Repeat("0", 24) + strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+// LogIdx returns the position of the log in its block.
+func (l *Log) LogIdx() uint64 {
+	return hexToUint64(l.LogIndex)
+}
+
+// ETHAmount formats the value in the native currency of the chain of the
+// record, e.g. "1.50000000 ETH" or "1.50000000 POL" on Polygon.
 func (t *Transaction) ETHAmount() string {
+	return ChainOf(t.Chain).FormatAmount(t.ValueWei())
+}
+
+// FormatETH formats an amount of wei as ETH with 8 decimals.
+func FormatETH(wei *big.Int) string {
+	return FormatUnits(wei, 18)
+}
+
+// EventKind returns the kind of the record, defaulting to a native transfer.
+func (t *Transaction) EventKind() EventKind {
+	if t.Kind == "" {
+		return KindNativeTransfer
+	}
+	return t.Kind
+}
+
+// TxDirection returns the direction of the record, deriving it from the
+// subscriber when it wasn't set.
+func (t *Transaction) TxDirection() Direction {
+	if t.Direction != DirectionAny {
+		return t.Direction
+	}
+	return DirectionFor(t.Subscriber, t.From, t.To)
+}
+
+// MatchesDirection reports whether the record fits a direction filter. Self
+// transfers are both incoming and outgoing.
+func (t *Transaction) MatchesDirection(direction Direction) bool {
+	got := t.TxDirection()
+	switch direction {
+	case DirectionAny:
+		return true
+	case DirectionIncoming, DirectionOutgoing:
+		return got == direction || got == DirectionSelf
+	default:
+		return got == direction
+	}
+}
+
+// DirectionFor returns the direction of a from -> to transfer for subscriber.
+func DirectionFor(subscriber, from, to string) Direction {
+	switch {
+	case from == subscriber && to == subscriber:
+		return DirectionSelf
+	case from == subscriber:
+		return DirectionOutgoing
+	case to == subscriber:
+		return DirectionIncoming
+	default:
+		return DirectionAny
+	}
+}
+
+// BlockNum returns the block number of the transaction, 0 if unknown.
+func (t *Transaction) BlockNum() uint64 {
+	return hexToUint64(t.BlockNumber)
+}
+
+// Index returns the position of the transaction in its block.
+func (t *Transaction) Index() uint64 {
+	return hexToUint64(t.TransactionIndex)
+}
+
+// Time returns the timestamp of the block holding the transaction.
+func (t *Transaction) Time() time.Time {
+	return time.Unix(int64(hexToUint64(t.Timestamp)), 0).UTC()
+}
+
+// ValueWei returns the transferred value in wei.
+func (t *Transaction) ValueWei() *big.Int {
 	valueInWei := new(big.Int)
-	valueInWei.SetString(t.Value[2:], 16)
+	if len(t.Value) > 2 {
+		valueInWei.SetString(t.Value[2:], 16)
+	}
+	return valueInWei
+}
 
-	weiInETH := new(big.Float).SetInt(new(big.Int).SetInt64(1e18)) // 1 ETH = 1e18 wei
-	valueInETH := new(big.Float).Quo(new(big.Float).SetInt(valueInWei), weiInETH)
+// ParseETHAmount converts a decimal ETH amount such as "1.5" to wei.
+func ParseETHAmount(amount string) (*big.Int, error) {
+	eth, ok := new(big.Rat).SetString(amount)
+	if !ok || eth.Sign() < 0 {
+		return nil, fmt.Errorf("invalid ETH amount %q", amount)
+	}
+	wei := eth.Mul(eth, new(big.Rat).SetInt64(1e18))
+	if !wei.IsInt() {
+		return nil, fmt.Errorf("ETH amount %q has more than 18 decimals", amount)
+	}
+	return wei.Num(), nil
+}
 
-	return fmt.Sprintf("%.8f", valueInETH)
+func hexToUint64(hex string) uint64 {
+	if len(hex) < 3 {
+		return 0
+	}
+	num, err := strconv.ParseUint(hex[2:], 16, 64)
+	if err != nil {
+		return 0
+	}
+	return num
 }
@@ -0,0 +1,95 @@
+package eth_parser
+
+import "sync"
+
+// Broadcaster is implemented by parsers whose live feed can be read by
+// several readers at once, e.g. the gRPC server and the local CLI, each of
+// them getting every record.
+type Broadcaster interface {
+	// NewListener returns a feed of its own, closed when the parser stops.
+	// cancel closes it early and must be called once it is no longer read.
+	NewListener() (feed <-chan Transaction, cancel func())
+}
+
+// Records buffered for each listener, those of a listener that falls further
+// behind are skipped
+const listenBufferSize = 64
+
+// feed broadcasts the emitted records to every listener without waiting for
+// any of them.
+type feed struct {
+	mu        sync.Mutex
+	listeners map[chan Transaction]struct{}
+	shared    chan Transaction // Returned by Listen, registered on first use
+	closed    bool
+}
+
+func newFeed() *feed {
+	return &feed{listeners: make(map[chan Transaction]struct{})}
+}
+
+// listen returns the feed shared by the callers of Listen.
+func (f *feed) listen() <-chan Transaction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.shared == nil {
+		f.shared = f.addLocked()
+	}
+	return f.shared
+}
+
+func (f *feed) add() (<-chan Transaction, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := f.addLocked()
+	return ch, func() { f.remove(ch) }
+}
+
+func (f *feed) addLocked() chan Transaction {
+	ch := make(chan Transaction, listenBufferSize)
+	if f.closed {
+		close(ch)
+		return ch
+	}
+	f.listeners[ch] = struct{}{}
+	return ch
+}
+
+func (f *feed) remove(ch chan Transaction) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.listeners[ch]; exists {
+		delete(f.listeners, ch)
+		close(ch)
+	}
+}
+
+// send hands tx to every listener with room for it, returning false when
+// none received it.
+func (f *feed) send(tx Transaction) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	received := false
+	for ch := range f.listeners {
+		select {
+		case ch <- tx:
+			received = true
+		default: // Skip slow listeners
+		}
+	}
+	return received
+}
+
+// close closes every listener, and those added later right away.
+func (f *feed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.closed = true
+	for ch := range f.listeners {
+		delete(f.listeners, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,75 @@
+package eth_parser
+
+import "strings"
+
+// SetLabel names an address in the address book, replacing its previous
+// label. Labels of the book take precedence over those of subscriptions.
+func (ep *EthereumParser) SetLabel(address, label string) bool {
+	label = strings.TrimSpace(label)
+	if !validAddress.MatchString(address) || label == "" {
+		return false
+	}
+	return ep.storage.SetLabel(normalizeAddress(address), label)
+}
+
+func (ep *EthereumParser) RemoveLabel(address string) bool {
+	return ep.storage.RemoveLabel(normalizeAddress(address))
+}
+
+func (ep *EthereumParser) ListLabels() []AddressLabel {
+	return ep.storage.ListLabels()
+}
+
+// labelOf returns the address book label of an address, or the label of its
+// subscription.
+func (ep *EthereumParser) labelOf(address string) string {
+	if address == "" {
+		return ""
+	}
+	address = normalizeAddress(address)
+	if label, ok := ep.storage.GetLabel(address); ok {
+		return label
+	}
+	if sub, ok := ep.currentMatcher().Lookup(address); ok {
+		return sub.Label
+	}
+	return ""
+}
+
+// label sets the current labels of the parties of tx. Records are stored
+// without them, so relabelling an address applies to its history too.
+func (ep *EthereumParser) label(tx *Transaction) {
+	tx.FromLabel = ep.labelOf(tx.From)
+	tx.ToLabel = ep.labelOf(tx.To)
+}
+
+// labelled returns a labelled copy of stored records, leaving the storage
+// untouched.
+func (ep *EthereumParser) labelled(txs []Transaction) []Transaction {
+	if len(txs) == 0 {
+		return txs
+	}
+	out := make([]Transaction, len(txs))
+	for i, tx := range txs {
+		ep.label(&tx)
+		out[i] = tx
+	}
+	return out
+}
+
+// SubscriberLabel is the label of the subscribed party of tx.
+func (tx *Transaction) SubscriberLabel() string {
+	switch {
+	case strings.EqualFold(tx.Subscriber, tx.From):
+		return tx.FromLabel
+	case strings.EqualFold(tx.Subscriber, tx.To):
+		return tx.ToLabel
+	}
+	return ""
+}
+
+// HasCounterparty tells whether tx is from or to one of the addresses, which
+// any transaction is when there are none.
+func (tx *Transaction) HasCounterparty(addresses []string) bool {
+	return len(addresses) == 0 || containsAddress(addresses, tx.From) || containsAddress(addresses, tx.To)
+}
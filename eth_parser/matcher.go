@@ -0,0 +1,113 @@
+package eth_parser
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+)
+
+// Matcher resolves which subscriptions the addresses of a block concern. The
+// monitor queries it for every transaction, so lookups must not contend with
+// subscription changes.
+type Matcher interface {
+	Lookup(address string) (Subscription, bool)
+	Subscriptions() []Subscription
+	Refresh(subs []Subscription) // Replaces the watched subscriptions
+}
+
+type matcherSnapshot struct {
+	subs      []Subscription
+	byAddress map[string]Subscription
+	prefilter *addressFilter
+}
+
+// HashSetMatcher keeps an immutable hash set snapshot of the subscriptions
+// which is swapped atomically on refresh, so lookups never lock.
+type HashSetMatcher struct {
+	snapshot  atomic.Pointer[matcherSnapshot]
+	prefilter bool
+}
+
+// NewHashSetMatcher creates an empty matcher. With prefilter set, lookups are
+// first checked against a bloom filter of the watched addresses, which mostly
+// pays off with very large watchlists where the hash set no longer fits in
+// the CPU caches.
+func NewHashSetMatcher(prefilter bool) *HashSetMatcher {
+	m := &HashSetMatcher{prefilter: prefilter}
+	m.Refresh(nil)
+	return m
+}
+
+func (m *HashSetMatcher) Lookup(address string) (Subscription, bool) {
+	snapshot := m.snapshot.Load()
+	if snapshot.prefilter != nil && !snapshot.prefilter.mayContain(address) {
+		return Subscription{}, false
+	}
+	sub, ok := snapshot.byAddress[address]
+	return sub, ok
+}
+
+func (m *HashSetMatcher) Subscriptions() []Subscription {
+	return m.snapshot.Load().subs
+}
+
+func (m *HashSetMatcher) Refresh(subs []Subscription) {
+	snapshot := &matcherSnapshot{
+		subs:      subs,
+		byAddress: make(map[string]Subscription, len(subs)),
+	}
+	for _, sub := range subs {
+		snapshot.byAddress[sub.Address] = sub
+	}
+	if m.prefilter {
+		snapshot.prefilter = newAddressFilter(subs)
+	}
+	m.snapshot.Store(snapshot)
+}
+
+// addressFilter is a bloom filter sized for ~2% false positives, deriving
+// its probes from a single runtime hash by double hashing.
+type addressFilter struct {
+	bits []uint64
+	mask uint64 // Size in bits minus one, the size being a power of two
+	seed maphash.Seed
+}
+
+const (
+	filterBitsPerAddress = 8
+	filterHashes         = 4
+)
+
+func newAddressFilter(subs []Subscription) *addressFilter {
+	size := uint64(64)
+	for size < uint64(len(subs)*filterBitsPerAddress) {
+		size <<= 1
+	}
+	f := &addressFilter{
+		bits: make([]uint64, size/64),
+		mask: size - 1,
+		seed: maphash.MakeSeed(),
+	}
+	for _, sub := range subs {
+		h := maphash.String(f.seed, sub.Address)
+		step := h>>32 | 1 // Odd step so every bit can be reached
+		for i := 0; i < filterHashes; i++ {
+			bit := h & f.mask
+			f.bits[bit/64] |= 1 << (bit % 64)
+			h += step
+		}
+	}
+	return f
+}
+
+func (f *addressFilter) mayContain(address string) bool {
+	h := maphash.String(f.seed, address)
+	step := h>>32 | 1
+	for i := 0; i < filterHashes; i++ {
+		bit := h & f.mask
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+		h += step
+	}
+	return true
+}
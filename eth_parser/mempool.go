@@ -0,0 +1,182 @@
+package eth_parser
+
+import (
+	"time"
+)
+
+// pendingTx is a pending transaction recorded for its subscribed parties.
+type pendingTx struct {
+	tx      Transaction
+	parties []string
+	checked time.Time // When it was last known to be pending
+}
+
+// WatchMempool starts watching the pending transactions the node knows of.
+// Those touching subscribed addresses are recorded and emitted with the
+// pending status, then updated once mined, replaced by another transaction
+// with the same sender and nonce, or dropped by the node. Calls after the
+// first one, or once the parser is stopped, do nothing.
+func (ep *EthereumParser) WatchMempool() {
+	ep.runMu.Lock()
+	defer ep.runMu.Unlock()
+	if ep.watching || ep.stopped {
+		return
+	}
+	ep.watching = true
+	go ep.watchMempool()
+}
+
+// watchMempool polls a pending transaction filter, handing the transactions
+// touching subscribers over to the monitor, which records them.
+func (ep *EthereumParser) watchMempool() {
+	ticker := time.NewTicker(ep.MempoolPollingFreq)
+	defer ticker.Stop()
+
+	var filterID string
+	for {
+		select {
+		case <-ep.ctx.Done():
+			return
+		case <-ep.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		if filterID == "" {
+			id, err := ep.Client.NewPendingTransactionFilter()
+			if err != nil {
+				ep.Logger.Warn("failed to create the pending transaction filter", "error", err)
+				continue
+			}
+			filterID = id
+		}
+		hashes, err := ep.Client.FetchFilterChanges(filterID)
+		if err != nil {
+			// Nodes drop filters that aren't polled for a while
+			ep.Logger.Warn("failed to fetch pending transactions, recreating the filter", "filter", filterID, "error", err)
+			filterID = ""
+			continue
+		}
+
+		for _, hash := range hashes {
+			tx, err := ep.Client.FetchTransactionByHash(hash)
+			if err != nil {
+				ep.Logger.Warn("failed to fetch pending transaction", "hash", hash, "error", err)
+				continue
+			}
+			if tx == nil || tx.BlockNumber != "" { // Already gone or mined
+				continue
+			}
+			if !ep.isSubscribed(tx.From) && !ep.isSubscribed(tx.To) {
+				continue
+			}
+			select {
+			case ep.pendingChan <- *tx:
+			case <-ep.stopChan:
+				return
+			}
+		}
+	}
+}
+
+// recordPending records and emits a pending transaction for its subscribed
+// parties.
+func (ep *EthereumParser) recordPending(tx Transaction) {
+	if _, known := ep.pending[tx.Hash]; known {
+		return
+	}
+	// It may have been mined and recorded since the watcher fetched it
+	current, err := ep.Client.FetchTransactionByHash(tx.Hash)
+	if err != nil || current == nil || current.BlockNumber != "" {
+		return
+	}
+	tx.Status = StatusPending
+	tx.Kind = KindNativeTransfer
+	tx.Chain = ep.Chain.ChainID
+	if ep.ABIs != nil {
+		tx.Call = ep.ABIs.Decode(tx.To, tx.Input)
+	}
+
+	// Subscriptions starting after the next block to process aren't
+	// monitoring yet, as for mined transactions
+	nextBlock := ep.storage.GetLastProcessedBlockNum() + 1
+	matcher := ep.currentMatcher()
+	p := &pendingTx{tx: tx, checked: time.Now()}
+	parties := []string{tx.From}
+	if tx.To != tx.From {
+		parties = append(parties, tx.To)
+	}
+	for _, addr := range parties {
+		if sub, ok := matcher.Lookup(addr); !ok || nextBlock < sub.StartBlock {
+			continue
+		}
+		p.parties = append(p.parties, addr)
+		tx.Subscriber = addr
+		tx.Direction = DirectionFor(addr, tx.From, tx.To)
+		ep.updatePending(tx)
+	}
+	if len(p.parties) > 0 {
+		ep.pending[tx.Hash] = p
+	}
+}
+
+// resolvePending settles the pending transactions a mined one concerns:
+// tx itself, whose records were just replaced by the mined ones, and those
+// of the same sender and nonce, which tx replaced.
+func (ep *EthereumParser) resolvePending(tx *Transaction) {
+	if len(ep.pending) == 0 {
+		return
+	}
+	delete(ep.pending, tx.Hash)
+	for hash, p := range ep.pending {
+		if p.tx.From != tx.From || p.tx.Nonce != tx.Nonce {
+			continue
+		}
+		delete(ep.pending, hash)
+		ep.settlePending(p, StatusReplaced, tx.Hash)
+	}
+}
+
+// expirePending asks the node about the transactions that have been pending
+// for longer than PendingTimeout, marking those it no longer knows of as
+// dropped.
+func (ep *EthereumParser) expirePending() {
+	for hash, p := range ep.pending {
+		if time.Since(p.checked) < ep.PendingTimeout {
+			continue
+		}
+		tx, err := ep.Client.FetchTransactionByHash(hash)
+		if err != nil {
+			ep.Logger.Warn("failed to check pending transaction", "hash", hash, "error", err)
+			continue
+		}
+		if tx != nil { // Still pending, or mined in a block not processed yet
+			p.checked = time.Now()
+			continue
+		}
+		delete(ep.pending, hash)
+		ep.settlePending(p, StatusDropped, "")
+	}
+}
+
+// settlePending updates and emits the records of a transaction that won't
+// be mined.
+func (ep *EthereumParser) settlePending(p *pendingTx, status TxStatus, replacedBy string) {
+	tx := p.tx
+	tx.Status = status
+	tx.ReplacedBy = replacedBy
+	for _, addr := range p.parties {
+		tx.Subscriber = addr
+		tx.Direction = DirectionFor(addr, tx.From, tx.To)
+		ep.updatePending(tx)
+	}
+}
+
+// updatePending stores and emits a pending transaction record. Addresses
+// unsubscribed in the meantime are skipped.
+func (ep *EthereumParser) updatePending(tx Transaction) {
+	if _, ok := ep.storage.UpsertTransaction(tx.Subscriber, tx); !ok {
+		return
+	}
+	ep.emit(tx)
+}
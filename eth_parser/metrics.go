@@ -0,0 +1,314 @@
+package eth_parser
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type metricKind string
+
+const (
+	counter   metricKind = "counter"
+	gauge     metricKind = "gauge"
+	histogram metricKind = "histogram"
+)
+
+type metricDef struct {
+	kind   metricKind
+	help   string
+	labels []string
+}
+
+// Latency buckets in seconds, those of the Prometheus client libraries
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// The metrics collected, by name
+var metricDefs = map[string]metricDef{
+	"eth_parser_head_block":                 {gauge, "Latest block reported by the node.", []string{"chain"}},
+	"eth_parser_processed_block":            {gauge, "Last block processed by the parser.", []string{"chain"}},
+	"eth_parser_block_lag":                  {gauge, "Blocks between the head of the chain and the last processed block, confirmations included.", []string{"chain"}},
+	"eth_parser_blocks_processed_total":     {counter, "Blocks processed by the parser.", []string{"chain"}},
+	"eth_parser_transactions_matched_total": {counter, "Records stored for subscribed addresses.", []string{"chain"}},
+	"eth_parser_live_events_dropped_total":  {counter, "Records left out of the live feed because no one was listening.", []string{"chain"}},
+	"eth_parser_storage_duration_seconds":   {histogram, "Latency of the storage operations.", []string{"chain", "operation"}},
+	"eth_rpc_request_duration_seconds":      {histogram, "Latency of the RPC requests, retries and fallback endpoints included.", []string{"chain", "method"}},
+	"eth_rpc_requests_total":                {counter, "RPC requests by outcome: ok, rpc_error, http_<status code> or error.", []string{"chain", "method", "status"}},
+	"eth_rpc_retries_total":                 {counter, "Attempts of RPC requests beyond the first one.", []string{"chain", "method"}},
+}
+
+type metricSeries struct {
+	labels  []string
+	value   float64  // Of counters and gauges
+	buckets []uint64 // Of histograms, per upper bound of latencyBuckets
+	sum     float64
+	count   uint64
+}
+
+// Metrics collects the metrics of parsers, their RPC clients and storages,
+// and serves them over HTTP in the Prometheus text exposition format. The
+// methods of a nil *Metrics do nothing, so metrics are optional.
+type Metrics struct {
+	mu     sync.Mutex
+	series map[string]map[string]*metricSeries // By metric name, then by label values
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{series: make(map[string]map[string]*metricSeries)}
+}
+
+// Add increases a counter.
+func (m *Metrics) Add(name string, delta float64, labels ...string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(name, counter, labels).value += delta
+}
+
+// Set sets a gauge.
+func (m *Metrics) Set(name string, value float64, labels ...string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(name, gauge, labels).value = value
+}
+
+// ObserveSince records the time elapsed since start in a histogram.
+func (m *Metrics) ObserveSince(name string, start time.Time, labels ...string) {
+	if m == nil {
+		return
+	}
+	seconds := time.Since(start).Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.get(name, histogram, labels)
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.sum += seconds
+	s.count++
+}
+
+// Value returns the value of a counter or gauge, or the number of
+// observations of a histogram.
+func (m *Metrics) Value(name string, labels ...string) float64 {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[name][strings.Join(labels, "\xff")]
+	switch {
+	case !ok:
+		return 0
+	case metricDefs[name].kind == histogram:
+		return float64(s.count)
+	}
+	return s.value
+}
+
+// get returns a series, creating it. Metrics are declared in metricDefs, so
+// using an unknown one or the wrong labels is a bug.
+func (m *Metrics) get(name string, kind metricKind, labels []string) *metricSeries {
+	def, ok := metricDefs[name]
+	if !ok || def.kind != kind || len(def.labels) != len(labels) {
+		panic(fmt.Sprintf("eth_parser: invalid use of metric %s", name))
+	}
+	byLabels, ok := m.series[name]
+	if !ok {
+		byLabels = make(map[string]*metricSeries)
+		m.series[name] = byLabels
+	}
+	key := strings.Join(labels, "\xff")
+	s, ok := byLabels[key]
+	if !ok {
+		s = &metricSeries{labels: append([]string(nil), labels...)}
+		if kind == histogram {
+			s.buckets = make([]uint64, len(latencyBuckets))
+		}
+		byLabels[key] = s
+	}
+	return s
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format,
+// sorted by name and labels.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	if m == nil {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.series))
+	for name := range m.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var cw strings.Builder
+	for _, name := range names {
+		def := metricDefs[name]
+		fmt.Fprintf(&cw, "# HELP %s %s\n# TYPE %s %s\n", name, def.help, name, def.kind)
+		keys := make([]string, 0, len(m.series[name]))
+		for key := range m.series[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := m.series[name][key]
+			if def.kind != histogram {
+				fmt.Fprintf(&cw, "%s%s %s\n", name, formatLabels(def.labels, s.labels, ""), formatFloat(s.value))
+				continue
+			}
+			for i, bound := range latencyBuckets {
+				fmt.Fprintf(&cw, "%s_bucket%s %d\n", name, formatLabels(def.labels, s.labels, formatFloat(bound)), s.buckets[i])
+			}
+			fmt.Fprintf(&cw, "%s_bucket%s %d\n", name, formatLabels(def.labels, s.labels, "+Inf"), s.count)
+			fmt.Fprintf(&cw, "%s_sum%s %s\n", name, formatLabels(def.labels, s.labels, ""), formatFloat(s.sum))
+			fmt.Fprintf(&cw, "%s_count%s %d\n", name, formatLabels(def.labels, s.labels, ""), s.count)
+		}
+	}
+	n, err := io.WriteString(w, cw.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics, e.g. on /metrics for Prometheus to scrape.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders {name="value",...}, with the le label of histogram
+// buckets when le isn't empty.
+func formatLabels(names, values []string, le string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// MeteredStorage records the latency of the operations of a storage.
+type MeteredStorage struct {
+	Storage
+	metrics *Metrics
+	chain   string
+}
+
+// NewMeteredStorage wraps storage, recording the latency of its operations
+// under the name of chain.
+func NewMeteredStorage(storage Storage, metrics *Metrics, chain string) Storage {
+	return &MeteredStorage{Storage: storage, metrics: metrics, chain: chain}
+}
+
+func (s *MeteredStorage) observe(operation string, start time.Time) {
+	s.metrics.ObserveSince("eth_parser_storage_duration_seconds", start, s.chain, operation)
+}
+
+func (s *MeteredStorage) Subscribe(address string) bool {
+	defer s.observe("subscribe", time.Now())
+	return s.Storage.Subscribe(address)
+}
+
+func (s *MeteredStorage) AddSubscription(sub Subscription) bool {
+	defer s.observe("add_subscription", time.Now())
+	return s.Storage.AddSubscription(sub)
+}
+
+func (s *MeteredStorage) Unsubscribe(address string, purge bool) bool {
+	defer s.observe("unsubscribe", time.Now())
+	return s.Storage.Unsubscribe(address, purge)
+}
+
+func (s *MeteredStorage) IsSubscribed(address string) bool {
+	defer s.observe("is_subscribed", time.Now())
+	return s.Storage.IsSubscribed(address)
+}
+
+func (s *MeteredStorage) GetSubscription(address string) (Subscription, bool) {
+	defer s.observe("get_subscription", time.Now())
+	return s.Storage.GetSubscription(address)
+}
+
+func (s *MeteredStorage) ListSubscriptions() []Subscription {
+	defer s.observe("list_subscriptions", time.Now())
+	return s.Storage.ListSubscriptions()
+}
+
+func (s *MeteredStorage) AddTransaction(address string, tx Transaction) bool {
+	defer s.observe("add_transaction", time.Now())
+	return s.Storage.AddTransaction(address, tx)
+}
+
+func (s *MeteredStorage) UpsertTransaction(address string, tx Transaction) (bool, bool) {
+	defer s.observe("upsert_transaction", time.Now())
+	return s.Storage.UpsertTransaction(address, tx)
+}
+
+func (s *MeteredStorage) GetTransactions(address string) []Transaction {
+	defer s.observe("get_transactions", time.Now())
+	return s.Storage.GetTransactions(address)
+}
+
+func (s *MeteredStorage) QueryTransactions(q TxQuery) (TxPage, error) {
+	defer s.observe("query_transactions", time.Now())
+	return s.Storage.QueryTransactions(q)
+}
+
+func (s *MeteredStorage) SetLastProcessedBlockNum(num uint64) bool {
+	defer s.observe("set_last_processed_block", time.Now())
+	return s.Storage.SetLastProcessedBlockNum(num)
+}
+
+func (s *MeteredStorage) GetLastProcessedBlockNum() uint64 {
+	defer s.observe("get_last_processed_block", time.Now())
+	return s.Storage.GetLastProcessedBlockNum()
+}
+
+func (s *MeteredStorage) SetLabel(address, label string) bool {
+	defer s.observe("set_label", time.Now())
+	return s.Storage.SetLabel(address, label)
+}
+
+func (s *MeteredStorage) RemoveLabel(address string) bool {
+	defer s.observe("remove_label", time.Now())
+	return s.Storage.RemoveLabel(address)
+}
+
+func (s *MeteredStorage) GetLabel(address string) (string, bool) {
+	defer s.observe("get_label", time.Now())
+	return s.Storage.GetLabel(address)
+}
+
+func (s *MeteredStorage) ListLabels() []AddressLabel {
+	defer s.observe("list_labels", time.Now())
+	return s.Storage.ListLabels()
+}
@@ -0,0 +1,313 @@
+package eth_parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ChainRouter is implemented by parsers following several chains, letting
+// callers target the parser of a single chain.
+type ChainRouter interface {
+	Chains() []ChainConfig
+	Chain(nameOrID string) (Parser, bool)
+}
+
+// MultiChainParser runs a parser per chain behind the Parser interface.
+// Subscriptions without a chain apply to every chain, and the transactions
+// of all chains are returned and emitted together, each tagged with its
+// chain.
+type MultiChainParser struct {
+	parsers  []*EthereumParser // In the order given, the first one is the primary chain
+	feed     *feed             // Every chain's live feed
+	stopOnce sync.Once
+}
+
+func NewMultiChainParser(parsers ...*EthereumParser) (*MultiChainParser, error) {
+	if len(parsers) == 0 {
+		return nil, fmt.Errorf("no chain to follow")
+	}
+	seen := make(map[uint64]bool, len(parsers))
+	for _, ep := range parsers {
+		if seen[ep.Chain.ChainID] {
+			return nil, fmt.Errorf("chain %s is followed twice", ep.Chain)
+		}
+		seen[ep.Chain.ChainID] = true
+	}
+
+	mp := &MultiChainParser{parsers: parsers, feed: newFeed()}
+	var wg sync.WaitGroup
+	for _, ep := range parsers {
+		wg.Add(1)
+		listener, _ := ep.NewListener() // Closed when the parser stops
+		go func(ep *EthereumParser) {
+			defer wg.Done()
+			for tx := range listener {
+				if !mp.feed.send(tx) {
+					ep.Metrics.Add("eth_parser_live_events_dropped_total", 1, ep.Chain.Name)
+				}
+			}
+		}(ep)
+	}
+	go func() {
+		wg.Wait() // Every parser stopped
+		mp.feed.close()
+	}()
+
+	return mp, nil
+}
+
+func (mp *MultiChainParser) Chains() []ChainConfig {
+	chains := make([]ChainConfig, len(mp.parsers))
+	for i, ep := range mp.parsers {
+		chains[i] = ep.Chain
+	}
+	return chains
+}
+
+func (mp *MultiChainParser) Chain(nameOrID string) (Parser, bool) {
+	i := mp.indexOf(nameOrID)
+	if i < 0 {
+		return nil, false
+	}
+	return mp.parsers[i], true
+}
+
+// indexOf finds the parser of a chain by name or decimal chain ID, -1 when
+// the chain isn't followed.
+func (mp *MultiChainParser) indexOf(nameOrID string) int {
+	id, err := strconv.ParseUint(nameOrID, 10, 64)
+	for i, ep := range mp.parsers {
+		if (err == nil && ep.Chain.ChainID == id) || strings.EqualFold(ep.Chain.Name, nameOrID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (mp *MultiChainParser) parserByID(id uint64) *EthereumParser {
+	if i := mp.indexOf(strconv.FormatUint(id, 10)); i >= 0 {
+		return mp.parsers[i]
+	}
+	return nil
+}
+
+// GetCurrentBlock returns the last block processed on the primary chain.
+func (mp *MultiChainParser) GetCurrentBlock() uint64 {
+	return mp.parsers[0].GetCurrentBlock()
+}
+
+func (mp *MultiChainParser) Subscribe(address string) bool {
+	return mp.AddSubscription(Subscription{Address: address})
+}
+
+// AddSubscription subscribes on the chain of sub, or on every chain when it
+// has none. It fails only when no chain took the subscription.
+func (mp *MultiChainParser) AddSubscription(sub Subscription) bool {
+	if sub.Chain != 0 {
+		ep := mp.parserByID(sub.Chain)
+		return ep != nil && ep.AddSubscription(sub)
+	}
+	added := false
+	for _, ep := range mp.parsers {
+		if ep.AddSubscription(sub) {
+			added = true
+		}
+	}
+	return added
+}
+
+// SubscribeMany adds the subscriptions as AddSubscription does, refreshing
+// the matcher of each chain once for the whole batch.
+func (mp *MultiChainParser) SubscribeMany(subs []Subscription) int {
+	taken := make([]bool, len(subs))
+	for _, ep := range mp.parsers {
+		refresh := false
+		for i, sub := range subs {
+			if ep.addSubscription(sub) {
+				taken[i], refresh = true, true
+			}
+		}
+		if refresh {
+			ep.RefreshSubscriptions()
+		}
+	}
+	added := 0
+	for _, ok := range taken {
+		if ok {
+			added++
+		}
+	}
+	return added
+}
+
+// Unsubscribe stops monitoring the address on every chain.
+func (mp *MultiChainParser) Unsubscribe(address string, purge bool) bool {
+	removed := false
+	for _, ep := range mp.parsers {
+		if ep.Unsubscribe(address, purge) {
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (mp *MultiChainParser) ListSubscriptions() []Subscription {
+	var subs []Subscription
+	for _, ep := range mp.parsers {
+		subs = append(subs, ep.ListSubscriptions()...)
+	}
+	return subs
+}
+
+func (mp *MultiChainParser) GetTransactions(address string) []Transaction {
+	var txs []Transaction
+	for _, ep := range mp.parsers {
+		txs = append(txs, ep.GetTransactions(address)...)
+	}
+	return txs
+}
+
+// QueryTransactions pages through the matching transactions one chain after
+// the other, the cursor telling the chain to continue with. Transactions are
+// only ordered within each chain, block numbers of different chains being
+// unrelated, so a descending order needs the chain to be targeted with Chain
+// when several are followed.
+func (mp *MultiChainParser) QueryTransactions(q TxQuery) (TxPage, error) {
+	if err := q.Validate(); err != nil {
+		return TxPage{}, err
+	}
+	if q.Order == OrderDescending && len(mp.parsers) > 1 {
+		return TxPage{}, fmt.Errorf("transactions are only ordered per chain, pick one of %s", mp.chainNames())
+	}
+	start, cursor := 0, ""
+	if q.Cursor != "" {
+		id, inner, found := strings.Cut(q.Cursor, ".")
+		if start = mp.indexOf(id); !found || start < 0 {
+			return TxPage{}, fmt.Errorf("invalid cursor %q", q.Cursor)
+		}
+		cursor = inner
+	}
+
+	var page TxPage
+	for i := start; i < len(mp.parsers); i++ {
+		chainQuery := q
+		chainQuery.Cursor = cursor
+		if q.Limit > 0 {
+			chainQuery.Limit = q.Limit - len(page.Transactions)
+		}
+		chainPage, err := mp.parsers[i].QueryTransactions(chainQuery)
+		if err != nil {
+			return TxPage{}, err
+		}
+		page.Transactions = append(page.Transactions, chainPage.Transactions...)
+		cursor = ""
+
+		if chainPage.NextCursor != "" {
+			page.NextCursor = fmt.Sprintf("%d.%s", mp.parsers[i].Chain.ChainID, chainPage.NextCursor)
+			break
+		}
+		if q.Limit > 0 && len(page.Transactions) == q.Limit {
+			if i+1 < len(mp.parsers) { // Continue with the next chain from its start
+				page.NextCursor = fmt.Sprintf("%d.", mp.parsers[i+1].Chain.ChainID)
+			}
+			break
+		}
+	}
+	return page, nil
+}
+
+// ImportTransactions stores every record on the parser of its chain,
+// untagged records going to the primary chain.
+func (mp *MultiChainParser) ImportTransactions(txs []Transaction) int {
+	byChain := make(map[*EthereumParser][]Transaction)
+	for _, tx := range txs {
+		ep := mp.parsers[0]
+		if tx.Chain != 0 {
+			if ep = mp.parserByID(tx.Chain); ep == nil {
+				continue
+			}
+		}
+		byChain[ep] = append(byChain[ep], tx)
+	}
+	imported := 0
+	for ep, chainTxs := range byChain {
+		imported += ep.ImportTransactions(chainTxs)
+	}
+	return imported
+}
+
+// Balance of the address on the only chain followed. Balances of several
+// chains can't be added up, so the chain has to be targeted with Chain then.
+func (mp *MultiChainParser) Balance(address string) (BalanceReport, error) {
+	if len(mp.parsers) > 1 {
+		return BalanceReport{}, fmt.Errorf("%w, pick one of %s", ErrChainRequired, mp.chainNames())
+	}
+	return mp.parsers[0].Balance(address)
+}
+
+func (mp *MultiChainParser) chainNames() string {
+	names := make([]string, len(mp.parsers))
+	for i, ep := range mp.parsers {
+		names[i] = ep.Chain.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// SetLabel names the address on every chain, addresses being the same
+// across EVM chains.
+func (mp *MultiChainParser) SetLabel(address, label string) bool {
+	set := true
+	for _, ep := range mp.parsers {
+		set = ep.SetLabel(address, label) && set
+	}
+	return set
+}
+
+func (mp *MultiChainParser) RemoveLabel(address string) bool {
+	removed := false
+	for _, ep := range mp.parsers {
+		if ep.RemoveLabel(address) {
+			removed = true
+		}
+	}
+	return removed
+}
+
+// ListLabels merges the address books of the chains, which only differ when
+// labels were set on a single chain. The first chain naming an address wins.
+func (mp *MultiChainParser) ListLabels() []AddressLabel {
+	seen := make(map[string]bool)
+	var labels []AddressLabel
+	for _, ep := range mp.parsers {
+		for _, l := range ep.ListLabels() {
+			if !seen[l.Address] {
+				seen[l.Address] = true
+				labels = append(labels, l)
+			}
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Address < labels[j].Address })
+	return labels
+}
+
+// Listen returns the live feed of every chain shared by its callers, which
+// buffers the records emitted since the first call.
+func (mp *MultiChainParser) Listen() <-chan Transaction {
+	return mp.feed.listen()
+}
+
+// NewListener returns a live feed of every chain of its own, see Broadcaster.
+func (mp *MultiChainParser) NewListener() (<-chan Transaction, func()) {
+	return mp.feed.add()
+}
+
+func (mp *MultiChainParser) Stop() {
+	mp.stopOnce.Do(func() {
+		for _, ep := range mp.parsers {
+			ep.Stop()
+		}
+	})
+}
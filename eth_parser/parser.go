@@ -2,46 +2,167 @@ package eth_parser
 
 import (
 	"context"
-	"log"
+	"encoding/hex"
+	"log/slog"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var validAddress = regexp.MustCompile(`^0x[a-fA-F0-9]{40}$`)
+
 type Parser interface {
 	GetCurrentBlock() uint64
 	Subscribe(address string) bool
+	AddSubscription(sub Subscription) bool       // Subscribe with metadata
+	Unsubscribe(address string, purge bool) bool // purge also drops the stored transactions
+	ListSubscriptions() []Subscription
+	SubscribeMany(subs []Subscription) int // Bulk AddSubscription, returns how many were added
 	GetTransactions(address string) []Transaction
-	Listen() <-chan Transaction // Provides event-driven architecture capability
-	Stop()                      // Stops the monitor
+	QueryTransactions(q TxQuery) (TxPage, error)   // Filtered and paginated transaction history
+	ImportTransactions(txs []Transaction) int      // Stores history records of subscribed addresses, returns how many were new
+	Balance(address string) (BalanceReport, error) // Reconciles the balance tracked from stored transfers with the node
+	SetLabel(address, label string) bool           // Names an address in the address book, subscribed or not
+	RemoveLabel(address string) bool               // Drops the address book entry
+	ListLabels() []AddressLabel                    // The address book
+	Listen() <-chan Transaction                    // Provides event-driven architecture capability
+	Stop()                                         // Stops the monitor
+}
+
+// LogTracking enables records built from event logs on top of the native
+// transfers found in the block transactions.
+type LogTracking struct {
+	TokenTransfers bool     // ERC-20 Transfer events from or to subscribed addresses
+	ContractEvents bool     // Events emitted by subscribed contracts
+	EventTopics    []string // Restricts ContractEvents to these event signatures (topic 0)
+}
+
+func (lt *LogTracking) enabled() bool {
+	return lt.TokenTransfers || lt.ContractEvents
+}
+
+type TrackingMode int
+
+const (
+	ModeFullBlocks TrackingMode = iota // Download every block with its transactions
+	ModeLogsOnly                       // Only track LogTracking events, fetched with eth_getLogs over block ranges
+)
+
+type BloomStats struct {
+	BlocksChecked  uint64 // Blocks whose logsBloom was tested
+	FetchesAvoided uint64 // Log fetches skipped because the bloom ruled the block out
+}
+
+type IntegrityStats struct {
+	BlocksRefetched uint64 // Fetches repeated because a block didn't verify
+	BlocksRejected  uint64 // Blocks that didn't verify in any attempt, retried at the next poll up to blockRejections times
 }
 
 type EthereumParser struct {
 	ctx              context.Context
+	Chain            ChainConfig // Records are tagged with its ID, blocks are processed after its Confirmations
 	Client           EthereumClient
 	storage          Storage // Easily attachable storage interface
-	tx_chan          chan Transaction
+	feed             *feed   // The live feed
 	BlockPollingFreq time.Duration
-	monitorStarted   bool
-	closeOnce        sync.Once
+	LogTracking      LogTracking
+	Mode             TrackingMode
+	ABIs             *ABIRegistry // Decodes the input of recorded transactions, nil disables decoding
+	VerifySenders    bool         // Recovers the sender of recorded transactions from their signature
+	VerifyBlocks     bool         // Checks every block against its hash and transactionsRoot before processing it
+	Prices           PriceSource  // Values recorded transfers in FiatCurrencies at block time, nil disables it
+	FiatCurrencies   []string
+	TrackBalances    bool         // Fetches the receipts of recorded transfers, which tell their fees
+	ReconcileEvery   uint64       // Blocks between reconciliations of the tracked balances, 0 disables them
+	Alerts           *AlertEngine // Evaluates alert rules against every emitted record, nil disables alerting
+	Metrics          *Metrics     // Records the progress of the monitor, nil disables metrics
+	Logger           *slog.Logger // slog.Default() when created, with the name of the chain for chain parsers
+	runMu            sync.Mutex   // Guards started, stopped and watching
+	started          bool
+	stopped          bool
 	stopChan         chan struct{}
+
+	// Mempool watching, see WatchMempool
+	watching           bool
+	MempoolPollingFreq time.Duration
+	PendingTimeout     time.Duration // Pending transactions older than this are checked for being dropped
+	pendingChan        chan Transaction
+	pending            map[string]*pendingTx // By hash, only used by the monitor goroutine
+
+	matcher   atomic.Pointer[matcherBox]
+	refreshMu sync.Mutex // Serializes matcher refreshes
+
+	bloomCache     map[string]BloomBits // Only used by the monitor goroutine
+	blocksChecked  atomic.Uint64
+	fetchesAvoided atomic.Uint64
+
+	sendersVerified     atomic.Uint64
+	senderMismatches    atomic.Uint64
+	sendersUnverifiable atomic.Uint64
+
+	blocksRefetched atomic.Uint64
+	blocksRejected  atomic.Uint64
+	rejectedBlock   uint64 // The last block rejected and how many polls did, only used by the monitor goroutine
+	rejections      int
+
+	balances balanceBook
 }
 
 func NewEthereumParser(ctx context.Context, storage Storage) Parser {
+	ep := NewIdleParser(ctx, storage)
+	ep.Start()
+
+	return ep
+}
+
+// NewChainParser returns a parser following chain through its RPC endpoints,
+// polling for new blocks every block time once started. Each chain needs its
+// own storage.
+func NewChainParser(ctx context.Context, chain ChainConfig, storage Storage) (*EthereumParser, error) {
+	if err := chain.Validate(); err != nil {
+		return nil, err
+	}
+	RegisterChain(chain)
+
+	ep := NewIdleParser(ctx, storage)
+	ep.Chain = chain
+	ep.Client = NewChainClient(chain)
+	ep.Logger = ep.Logger.With("chain", chain.Name)
+	ep.BlockPollingFreq = chain.BlockTime
+
+	return ep, nil
+}
+
+// NewIdleParser returns a parser whose monitor isn't running, so it can be
+// configured first: Start runs the monitor, while Poll processes new blocks
+// on demand.
+func NewIdleParser(ctx context.Context, storage Storage) *EthereumParser {
 	if storage == nil {
 		storage = NewMemoryStorage() // Use default storage
 	}
 
 	ep := &EthereumParser{
 		ctx:              ctx,
+		Chain:            Mainnet,
 		Client:           NewEthereumClient(),
 		storage:          storage,
-		tx_chan:          make(chan Transaction),
+		feed:             newFeed(),
 		BlockPollingFreq: 5 * time.Second,
 		stopChan:         make(chan struct{}),
-	}
+		bloomCache:       make(map[string]BloomBits),
+		ABIs:             NewABIRegistry(),
+		Logger:           slog.Default(),
 
-	go ep.startMonitor()
+		MempoolPollingFreq: 2 * time.Second,
+		PendingTimeout:     30 * time.Minute,
+		pendingChan:        make(chan Transaction, 256),
+		pending:            make(map[string]*pendingTx),
+	}
+	ep.matcher.Store(&matcherBox{NewHashSetMatcher(false)})
+	ep.RefreshSubscriptions() // The storage may already hold subscriptions
 
 	return ep
 }
@@ -51,29 +172,145 @@ func (ep *EthereumParser) GetCurrentBlock() uint64 {
 }
 
 func (ep *EthereumParser) Subscribe(address string) bool {
-	validAddress := regexp.MustCompile(`^0x[a-fA-F0-9]{40}$`)
-	if validAddress.MatchString(address) {
-		return ep.storage.Subscribe(address)
+	return ep.AddSubscription(Subscription{Address: address})
+}
+
+func (ep *EthereumParser) AddSubscription(sub Subscription) bool {
+	if !ep.addSubscription(sub) {
+		return false
 	}
-	return false
+	ep.RefreshSubscriptions()
+	return true
+}
+
+func (ep *EthereumParser) SubscribeMany(subs []Subscription) int {
+	added := 0
+	for _, sub := range subs {
+		if ep.addSubscription(sub) {
+			added++
+		}
+	}
+	if added > 0 {
+		ep.RefreshSubscriptions()
+	}
+	return added
+}
+
+func (ep *EthereumParser) addSubscription(sub Subscription) bool {
+	if !validAddress.MatchString(sub.Address) {
+		return false
+	}
+	if sub.Chain != 0 && sub.Chain != ep.Chain.ChainID {
+		return false
+	}
+	sub.Address = normalizeAddress(sub.Address)
+	sub.Chain = ep.Chain.ChainID
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = time.Now().UTC()
+	}
+	if sub.StartBlock == 0 { // Monitor from the next block onwards by default
+		sub.StartBlock = ep.storage.GetLastProcessedBlockNum() + 1
+	}
+	return ep.storage.AddSubscription(sub)
+}
+
+func (ep *EthereumParser) Unsubscribe(address string, purge bool) bool {
+	if !ep.storage.Unsubscribe(normalizeAddress(address), purge) {
+		return false
+	}
+	ep.balances.drop(normalizeAddress(address))
+	ep.RefreshSubscriptions()
+	return true
+}
+
+func (ep *EthereumParser) ListSubscriptions() []Subscription {
+	return ep.storage.ListSubscriptions()
+}
+
+// SetMatcher replaces the matcher the monitor resolves subscriptions with,
+// e.g. to enable the prefilter of a HashSetMatcher for large watchlists.
+func (ep *EthereumParser) SetMatcher(m Matcher) {
+	ep.refreshMu.Lock()
+	defer ep.refreshMu.Unlock()
+	m.Refresh(ep.storage.ListSubscriptions())
+	ep.matcher.Store(&matcherBox{m})
+}
+
+// RefreshSubscriptions reloads the matcher snapshot from the storage. The
+// parser does it on every subscription change made through it, so this is
+// only needed after changing the storage directly.
+func (ep *EthereumParser) RefreshSubscriptions() {
+	ep.refreshMu.Lock()
+	defer ep.refreshMu.Unlock()
+	ep.currentMatcher().Refresh(ep.storage.ListSubscriptions())
 }
 
 func (ep *EthereumParser) GetTransactions(address string) []Transaction {
-	return ep.storage.GetTransactions(address)
+	return ep.labelled(ep.storage.GetTransactions(normalizeAddress(address)))
+}
+
+func (ep *EthereumParser) QueryTransactions(q TxQuery) (TxPage, error) {
+	q.Address = normalizeAddress(q.Address)
+	page, err := ep.storage.QueryTransactions(q)
+	page.Transactions = ep.labelled(page.Transactions)
+	return page, err
+}
+
+func (ep *EthereumParser) ImportTransactions(txs []Transaction) int {
+	imported := 0
+	for _, tx := range txs {
+		tx.Subscriber = normalizeAddress(tx.Subscriber)
+		if !ep.storage.IsSubscribed(tx.Subscriber) {
+			continue
+		}
+		if tx.Chain == 0 { // Untagged history is taken to be of this chain
+			tx.Chain = ep.Chain.ChainID
+		} else if tx.Chain != ep.Chain.ChainID {
+			continue
+		}
+		if created, ok := ep.storage.UpsertTransaction(tx.Subscriber, tx); ok && created {
+			imported++
+		}
+	}
+	return imported
 }
 
+// Nodes report addresses in lowercase, so checksummed input must be folded
+// to match them.
+func normalizeAddress(address string) string {
+	return strings.ToLower(address)
+}
+
+// Listen returns the live feed shared by its callers, which buffers the
+// records emitted since the first call.
 func (ep *EthereumParser) Listen() <-chan Transaction {
-	return ep.tx_chan
+	return ep.feed.listen()
 }
 
-func (ep *EthereumParser) startMonitor() {
-	if ep.monitorStarted {
+// NewListener returns a live feed of its own, see Broadcaster.
+func (ep *EthereumParser) NewListener() (<-chan Transaction, func()) {
+	return ep.feed.add()
+}
+
+// Start runs the monitor of a parser created by NewChainParser or
+// NewIdleParser, which must be configured first: its fields aren't to be set
+// once it runs.
+func (ep *EthereumParser) Start() {
+	ep.runMu.Lock()
+	defer ep.runMu.Unlock()
+	if ep.started || ep.stopped {
 		return
 	}
-	ep.monitorStarted = true
+	ep.started = true
+	go ep.startMonitor()
+}
 
+// startMonitor runs the monitor until the parser is stopped. It closes the
+// live feed on its way out, being the only goroutine sending to it.
+func (ep *EthereumParser) startMonitor() {
 	ticker := time.NewTicker(ep.BlockPollingFreq)
 
+	defer ep.feed.close()
 	defer ticker.Stop()
 	defer ep.Stop()
 
@@ -83,60 +320,530 @@ func (ep *EthereumParser) startMonitor() {
 			return
 		case <-ep.stopChan:
 			return
+		case tx := <-ep.pendingChan:
+			ep.recordPending(tx)
 		case <-ticker.C:
-			latestBlockNumInstance, err := ep.Client.FetchLatestBlockNumber()
-			if err != nil {
-				log.Println("failed to fetch latest block:", err)
-				continue
-			}
-			latestBlockNum, err := latestBlockNumInstance.ToUint64()
-			if err != nil {
-				log.Println("impossible to use latest block number:", err)
+			if !ep.Poll() {
 				return
 			}
+		}
+	}
+}
 
-			if ep.storage.GetLastProcessedBlockNum() == 0 { // Only executed in the first run
-				ep.storage.SetLastProcessedBlockNum(latestBlockNum - 1)
-			}
+// Poll processes the blocks the chain advanced by since the last poll, as the
+// monitor does every BlockPollingFreq. It is meant for parsers that weren't
+// started, and returns false when the monitor would have stopped.
+func (ep *EthereumParser) Poll() bool {
+	ep.expirePending()
 
-			if latestBlockNum > ep.storage.GetLastProcessedBlockNum() {
-				for blockNum := ep.storage.GetLastProcessedBlockNum() + 1; blockNum <= latestBlockNum; blockNum++ {
-					block, err := ep.Client.FetchBlockByNumber(blockNum)
-					if err != nil {
-						log.Println("impossible to retrieve block information:", err)
-						return
-					}
-					for _, tx := range block.Result.Transactions {
-						for _, addr := range []string{tx.From, tx.To} {
-							if ep.storage.IsSubscribed(addr) {
-								tx.Subscriber = addr
-								if ok := ep.storage.AddTransaction(tx.Subscriber, tx); !ok {
-									log.Println("failed to store transaction, bad storage. exiting now")
-									return
-								}
-								// Send to the live feed
-								select {
-								case ep.tx_chan <- tx:
-								default: // Skip if the channel is full
-								}
-								break
-							}
-						}
-					}
-				}
-				if ok := ep.storage.SetLastProcessedBlockNum(latestBlockNum); !ok {
-					log.Println("failed to set the last processed block number, bad storage. exiting now")
-					return
+	latestBlockNumInstance, err := ep.Client.FetchLatestBlockNumber()
+	if err != nil {
+		ep.Logger.Warn("failed to fetch the latest block number", "error", err)
+		return true
+	}
+	latestBlockNum, err := latestBlockNumInstance.ToUint64()
+	if err != nil {
+		ep.Logger.Error("invalid latest block number, stopping the monitor", "error", err)
+		return false
+	}
+	head := latestBlockNum
+	ep.Metrics.Set("eth_parser_head_block", float64(head), ep.Chain.Name)
+	if latestBlockNum <= ep.Chain.Confirmations {
+		return true
+	}
+	latestBlockNum -= ep.Chain.Confirmations // The latest block deep enough to process
+
+	if ep.storage.GetLastProcessedBlockNum() == 0 { // Only executed in the first run
+		ep.storage.SetLastProcessedBlockNum(latestBlockNum - 1)
+	}
+
+	if lastBlockNum := ep.storage.GetLastProcessedBlockNum(); latestBlockNum > lastBlockNum {
+		processed, ok := latestBlockNum, false
+		if ep.Mode == ModeLogsOnly {
+			ok = ep.processLogRange(lastBlockNum+1, latestBlockNum)
+		} else {
+			processed, ok = ep.processBlocks(lastBlockNum+1, latestBlockNum)
+		}
+		if !ok {
+			return false
+		}
+		if processed == lastBlockNum {
+			ep.recordLag(head)
+			return true // A rejected block is retried at the next poll
+		}
+		if ok := ep.storage.SetLastProcessedBlockNum(processed); !ok {
+			ep.Logger.Error("storage failed to save the last processed block, stopping the monitor", "block", processed)
+			return false
+		}
+		ep.Metrics.Add("eth_parser_blocks_processed_total", float64(processed-lastBlockNum), ep.Chain.Name)
+		ep.Logger.Debug("processed blocks", "from_block", lastBlockNum+1, "to_block", processed, "head", head)
+		if ep.ReconcileEvery > 0 && processed/ep.ReconcileEvery > lastBlockNum/ep.ReconcileEvery {
+			ep.reconcileBalances(processed)
+		}
+	}
+	ep.recordLag(head)
+	return true
+}
+
+// recordLag records how far the last processed block is behind the head of
+// the chain.
+func (ep *EthereumParser) recordLag(head uint64) {
+	processed := ep.storage.GetLastProcessedBlockNum()
+	ep.Metrics.Set("eth_parser_processed_block", float64(processed), ep.Chain.Name)
+	if head > processed {
+		ep.Metrics.Set("eth_parser_block_lag", float64(head-processed), ep.Chain.Name)
+	} else {
+		ep.Metrics.Set("eth_parser_block_lag", 0, ep.Chain.Name)
+	}
+}
+
+// processBlocks downloads every block of the range with its transactions. It
+// returns the last block processed, which is short of toBlock when a block
+// failed verification, and false when a block couldn't be processed.
+func (ep *EthereumParser) processBlocks(fromBlock, toBlock uint64) (uint64, bool) {
+	for blockNum := fromBlock; blockNum <= toBlock; blockNum++ {
+		block, err := ep.Client.FetchBlockByNumber(blockNum)
+		if err != nil {
+			ep.Logger.Error("failed to fetch block, stopping the monitor", "block", blockNum, "error", err)
+			return blockNum - 1, false
+		}
+		if ep.VerifyBlocks {
+			if block, err = ep.verifiedBlock(blockNum, block); err != nil {
+				if ep.reject(blockNum) >= blockRejections {
+					ep.Logger.Error("block rejected at every poll, stopping the monitor", "block", blockNum, "polls", blockRejections, "error", err)
+					return blockNum - 1, false
 				}
+				ep.Logger.Warn("rejected block, retrying at the next poll", "block", blockNum, "error", err)
+				return blockNum - 1, true
+			}
+		}
+		for _, tx := range block.Result.Transactions {
+			tx.Timestamp = block.Result.Timestamp
+			tx.Kind = KindNativeTransfer
+			tx.Status = StatusMined
+			if !ep.recordForSubscribers(blockNum, tx) {
+				return blockNum - 1, false
 			}
+			ep.resolvePending(&tx)
+		}
+		if !ep.processLogs(blockNum, &block.Result) {
+			return blockNum - 1, false
 		}
 	}
+	return toBlock, true
 }
 
-func (ep *EthereumParser) Stop() {
-	ep.closeOnce.Do(func() {
-		close(ep.stopChan)
-		close(ep.tx_chan)
-		ep.monitorStarted = false
+// Fetches of a block that doesn't verify, before it is rejected
+const blockFetchAttempts = 3
+
+// Polls rejecting the same block before the monitor stops, rather than
+// retrying a block that will never verify forever
+const blockRejections = 10
+
+// verifiedBlock verifies block, re-fetching it when it doesn't verify in
+// case the node served a corrupted copy. It returns the verification error
+// of the last attempt when none verified.
+func (ep *EthereumParser) verifiedBlock(blockNum uint64, block *Block) (*Block, error) {
+	err := VerifyBlock(&block.Result)
+	for attempt := 1; err != nil && attempt < blockFetchAttempts; attempt++ {
+		ep.blocksRefetched.Add(1)
+		if block, err = ep.Client.FetchBlockByNumber(blockNum); err == nil {
+			err = VerifyBlock(&block.Result)
+		}
+	}
+	if err != nil {
+		ep.blocksRejected.Add(1)
+		return nil, err
+	}
+	return block, nil
+}
+
+// reject counts a poll rejecting blockNum, returning how many polls in a row
+// did.
+func (ep *EthereumParser) reject(blockNum uint64) int {
+	if ep.rejectedBlock != blockNum {
+		ep.rejectedBlock, ep.rejections = blockNum, 0
+	}
+	ep.rejections++
+	return ep.rejections
+}
+
+func (ep *EthereumParser) IntegrityStats() IntegrityStats {
+	return IntegrityStats{
+		BlocksRefetched: ep.blocksRefetched.Load(),
+		BlocksRejected:  ep.blocksRejected.Load(),
+	}
+}
+
+// recordForSubscribers stores and emits a record of tx for each subscribed
+// party, so a transfer between two subscribers shows up in both histories.
+// It returns false when the storage failed.
+func (ep *EthereumParser) recordForSubscribers(blockNum uint64, tx Transaction) bool {
+	parties := []string{tx.From}
+	if tx.To != tx.From {
+		parties = append(parties, tx.To)
+	}
+	enriched := false
+	for _, addr := range parties {
+		tx.Subscriber = addr
+		tx.Direction = DirectionFor(addr, tx.From, tx.To)
+		if !enriched && ep.isSubscribed(addr) { // Only what gets recorded is worth the work
+			ep.enrich(&tx)
+			enriched = true
+		}
+		if !ep.record(blockNum, tx) {
+			return false
+		}
+	}
+	return true
+}
+
+// record stores and emits tx if its subscriber is monitoring blockNum. It
+// returns false when the storage failed.
+func (ep *EthereumParser) record(blockNum uint64, tx Transaction) bool {
+	sub, ok := ep.currentMatcher().Lookup(tx.Subscriber)
+	if !ok || blockNum < sub.StartBlock {
+		return true
+	}
+	tx.Chain = ep.Chain.ChainID
+	// Upserting keeps reprocessed blocks from duplicating records
+	created, ok := ep.storage.UpsertTransaction(tx.Subscriber, tx)
+	if !ok {
+		ep.Logger.Error("storage failed to save transaction, stopping the monitor", "block", blockNum, "address", tx.Subscriber, "hash", tx.Hash)
+		return false
+	}
+	if created {
+		ep.Metrics.Add("eth_parser_transactions_matched_total", 1, ep.Chain.Name)
+	}
+	// A pending record was replaced, which is news even if not a new record
+	if _, wasPending := ep.pending[tx.Hash]; created || wasPending {
+		ep.emit(tx)
+	}
+	return true
+}
+
+// emit sends a stored record to the live feed, with the labels of its
+// parties. Alert rules see every record, even when no one listens.
+func (ep *EthereumParser) emit(tx Transaction) {
+	ep.label(&tx)
+	if ep.Alerts != nil {
+		ep.Alerts.Evaluate(tx, ep.storage)
+	}
+	if !ep.feed.send(tx) {
+		ep.Metrics.Add("eth_parser_live_events_dropped_total", 1, ep.Chain.Name)
+	}
+}
+
+// enrich decodes the input of tx and checks its sender, as configured. Only
+// native transfers are enriched: the records built from logs have neither
+// input nor signature, their value isn't in the native currency and their
+// receipt is that of the transaction emitting them.
+func (ep *EthereumParser) enrich(tx *Transaction) {
+	if tx.EventKind() != KindNativeTransfer {
+		return
+	}
+	if ep.ABIs != nil {
+		tx.Call = ep.ABIs.Decode(tx.To, tx.Input)
+	}
+	if ep.VerifySenders {
+		ep.verifySender(tx)
+	}
+	if ep.Prices != nil {
+		ep.valueInFiat(tx)
+	}
+	if ep.TrackBalances {
+		ep.fetchReceipt(tx)
+	}
+}
+
+// valueInFiat sets the value of tx in every fiat currency with a price at
+// the time of its block. Missing prices are logged and skipped.
+func (ep *EthereumParser) valueInFiat(tx *Transaction) {
+	for _, currency := range ep.FiatCurrencies {
+		price, err := ep.Prices.Price(currency, tx.Time())
+		if err != nil {
+			ep.Logger.Warn("no fiat price for transaction", "currency", currency, "hash", tx.Hash, "error", err)
+			continue
+		}
+		if tx.Fiat == nil {
+			tx.Fiat = make(FiatValues, len(ep.FiatCurrencies))
+		}
+		tx.Fiat[strings.ToUpper(currency)] = FiatValue(tx.ValueWei(), price)
+	}
+}
+
+// verifySender flags transactions whose signature doesn't recover the From
+// reported by the node, which would reveal a faulty or malicious endpoint.
+func (ep *EthereumParser) verifySender(tx *Transaction) {
+	sender, err := RecoverSender(tx)
+	switch {
+	case err != nil:
+		tx.SenderCheck = SenderUnverifiable
+		ep.sendersUnverifiable.Add(1)
+	case sender != normalizeAddress(tx.From):
+		tx.SenderCheck = SenderMismatch
+		ep.senderMismatches.Add(1)
+		ep.Logger.Warn("sender mismatch, the signature doesn't recover the sender reported by the node", "hash", tx.Hash, "reported", tx.From, "recovered", sender)
+	default:
+		tx.SenderCheck = SenderVerified
+		ep.sendersVerified.Add(1)
+	}
+}
+
+func (ep *EthereumParser) SenderStats() SenderStats {
+	return SenderStats{
+		Verified:     ep.sendersVerified.Load(),
+		Mismatches:   ep.senderMismatches.Load(),
+		Unverifiable: ep.sendersUnverifiable.Load(),
+	}
+}
+
+func (ep *EthereumParser) isSubscribed(address string) bool {
+	_, ok := ep.currentMatcher().Lookup(address)
+	return ok
+}
+
+// matcherBox lets matchers of any type be swapped atomically.
+type matcherBox struct {
+	Matcher
+}
+
+func (ep *EthereumParser) currentMatcher() Matcher {
+	return ep.matcher.Load().Matcher
+}
+
+func (ep *EthereumParser) BloomStats() BloomStats {
+	return BloomStats{
+		BlocksChecked:  ep.blocksChecked.Load(),
+		FetchesAvoided: ep.fetchesAvoided.Load(),
+	}
+}
+
+// processLogs records the events of a block, fetching its logs only when the
+// block's logsBloom says they may concern a subscription. It returns false
+// when the logs couldn't be processed.
+func (ep *EthereumParser) processLogs(blockNum uint64, block *BlockResult) bool {
+	if !ep.LogTracking.enabled() {
+		return true
+	}
+	subs := ep.currentMatcher().Subscriptions()
+	if len(subs) == 0 {
+		return true
+	}
+
+	if bloom, err := ParseBloom(block.LogsBloom); err == nil {
+		ep.blocksChecked.Add(1)
+		if !ep.bloomMayMatch(&bloom, subs) {
+			ep.fetchesAvoided.Add(1)
+			return true
+		}
+	}
+
+	filter := LogFilter{BlockHash: block.Hash}
+	if !ep.LogTracking.ContractEvents {
+		filter.Topics = [][]string{{TransferEventTopic}}
+	}
+	logs, err := ep.Client.FetchLogs(filter)
+	if err != nil {
+		ep.Logger.Error("failed to fetch block logs, stopping the monitor", "block", blockNum, "error", err)
+		return false
+	}
+	for _, l := range logs.Result {
+		if !ep.recordLog(blockNum, block.Timestamp, l) {
+			return false
+		}
+	}
+	return true
+}
+
+// Providers cap the size of eth_getLogs filters, so the subscribed addresses
+// are sent in chunks of at most this many.
+const maxFilterAddresses = 100
+
+// processLogRange is the fast path of ModeLogsOnly: the logs of the whole
+// range are fetched at once and only the headers of the blocks holding
+// matching logs are downloaded, for their timestamp. It returns false when
+// the range couldn't be processed.
+func (ep *EthereumParser) processLogRange(fromBlock, toBlock uint64) bool {
+	subs := ep.currentMatcher().Subscriptions()
+	if len(subs) == 0 || !ep.LogTracking.enabled() {
+		return true
+	}
+
+	var filters []LogFilter
+	for start := 0; start < len(subs); start += maxFilterAddresses {
+		chunk := subs[start:min(start+maxFilterAddresses, len(subs))]
+		addresses := make([]string, 0, len(chunk))
+		topics := make([]string, 0, len(chunk))
+		for _, sub := range chunk {
+			addresses = append(addresses, sub.Address)
+			topics = append(topics, AddressTopic(sub.Address))
+		}
+
+		if ep.LogTracking.TokenTransfers {
+			filters = append(filters,
+				LogFilter{Topics: [][]string{{TransferEventTopic}, topics}},      // Outgoing
+				LogFilter{Topics: [][]string{{TransferEventTopic}, nil, topics}}, // Incoming
+			)
+		}
+		if ep.LogTracking.ContractEvents {
+			filter := LogFilter{Addresses: addresses}
+			// Without EventTopics every event of the subscribed contracts is tracked
+			if len(ep.LogTracking.EventTopics) > 0 {
+				filter.Topics = [][]string{ep.LogTracking.EventTopics}
+			}
+			filters = append(filters, filter)
+		}
+	}
+
+	// The same log may be returned by several filters
+	seen := make(map[string]bool)
+	var logs []Log
+	for _, filter := range filters {
+		filter.FromBlock, filter.ToBlock = fromBlock, toBlock
+		result, err := ep.Client.FetchLogRange(filter)
+		if err != nil {
+			ep.Logger.Error("failed to fetch logs, stopping the monitor", "from_block", fromBlock, "to_block", toBlock, "error", err)
+			return false
+		}
+		for _, l := range result {
+			if id := l.BlockHash + l.LogIndex; !seen[id] {
+				seen[id] = true
+				logs = append(logs, l)
+			}
+		}
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return hexToUint64(logs[i].BlockNumber) < hexToUint64(logs[j].BlockNumber)
+		}
+		return logs[i].LogIdx() < logs[j].LogIdx()
 	})
+
+	timestamps := make(map[uint64]string)
+	for _, l := range logs {
+		blockNum := hexToUint64(l.BlockNumber)
+		if _, exists := timestamps[blockNum]; !exists {
+			header, err := ep.Client.FetchBlockHeaderByNumber(blockNum)
+			if err != nil {
+				ep.Logger.Error("failed to fetch block header, stopping the monitor", "block", blockNum, "error", err)
+				return false
+			}
+			timestamps[blockNum] = header.Result.Timestamp
+		}
+		if !ep.recordLog(blockNum, timestamps[blockNum], l) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ep *EthereumParser) bloomMayMatch(bloom *Bloom, subs []Subscription) bool {
+	if ep.LogTracking.TokenTransfers && bloom.Test(ep.bloomBits(TransferEventTopic)) {
+		for _, sub := range subs {
+			if bloom.Test(ep.bloomBits(AddressTopic(sub.Address))) {
+				return true
+			}
+		}
+	}
+	if ep.LogTracking.ContractEvents {
+		topicMatch := len(ep.LogTracking.EventTopics) == 0
+		for _, topic := range ep.LogTracking.EventTopics {
+			topicMatch = topicMatch || bloom.Test(ep.bloomBits(topic))
+		}
+		if !topicMatch {
+			return false
+		}
+		for _, sub := range subs {
+			if bloom.Test(ep.bloomBits(sub.Address)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bloomBits returns the cached bloom positions of a hex encoded address or topic.
+func (ep *EthereumParser) bloomBits(item string) BloomBits {
+	if bits, exists := ep.bloomCache[item]; exists {
+		return bits
+	}
+	raw, _ := hex.DecodeString(strings.TrimPrefix(item, "0x"))
+	bits := BloomBitsOf(raw)
+	ep.bloomCache[item] = bits
+	return bits
+}
+
+// recordLog stores the records of a log. Logs matching no subscription, which
+// the filters may let through, are skipped by record.
+func (ep *EthereumParser) recordLog(blockNum uint64, timestamp string, l Log) bool {
+	if l.Removed {
+		return true
+	}
+	tx := Transaction{
+		BlockHash:        l.BlockHash,
+		BlockNumber:      l.BlockNumber,
+		Hash:             l.TransactionHash,
+		TransactionIndex: l.TransactionIndex,
+		Timestamp:        timestamp,
+		Status:           StatusMined,
+		Log:              &l,
+	}
+
+	if ep.LogTracking.TokenTransfers && len(l.Topics) == 3 && l.Topics[0] == TransferEventTopic {
+		transfer := tx
+		transfer.Kind = KindTokenTransfer
+		transfer.From = TopicAddress(l.Topics[1])
+		transfer.To = TopicAddress(l.Topics[2])
+		transfer.Value = trimHex(l.Data)
+		if !ep.recordForSubscribers(blockNum, transfer) {
+			return false
+		}
+	}
+
+	if ep.LogTracking.ContractEvents && len(l.Topics) > 0 && ep.trackedTopic(l.Topics[0]) {
+		event := tx
+		event.Kind = KindContractEvent
+		event.Subscriber = strings.ToLower(l.Address)
+		if !ep.record(blockNum, event) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ep *EthereumParser) trackedTopic(topic string) bool {
+	if len(ep.LogTracking.EventTopics) == 0 {
+		return true
+	}
+	for _, tracked := range ep.LogTracking.EventTopics {
+		if strings.EqualFold(tracked, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimHex drops the leading zeros of a hex quantity, e.g. an uint256 word.
+func trimHex(value string) string {
+	trimmed := strings.TrimLeft(strings.TrimPrefix(value, "0x"), "0")
+	if trimmed == "" {
+		return "0x0"
+	}
+	return "0x" + trimmed
+}
+
+// Stop signals the monitor to stop, which closes the live feed once it
+// returns. The feed of a parser that was never started is closed right away.
+func (ep *EthereumParser) Stop() {
+	ep.runMu.Lock()
+	defer ep.runMu.Unlock()
+	if ep.stopped {
+		return
+	}
+	ep.stopped = true
+	close(ep.stopChan)
+	if !ep.started {
+		ep.feed.close()
+	}
 }
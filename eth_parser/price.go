@@ -0,0 +1,204 @@
+package eth_parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PriceSource tells the price of 1 ETH in a fiat currency at a given time.
+type PriceSource interface {
+	Price(currency string, at time.Time) (*big.Rat, error)
+}
+
+// FiatValue converts an amount of wei to a fiat value with 2 decimals.
+func FiatValue(wei *big.Int, price *big.Rat) string {
+	value := new(big.Rat).SetFrac(wei, big.NewInt(1e18)) // 1 ETH = 1e18 wei
+	return value.Mul(value, price).FloatString(2)
+}
+
+type pricePoint struct {
+	at    time.Time
+	price *big.Rat
+}
+
+// CSVPriceSource serves prices from a history of timestamp,currency,price
+// records, e.g. daily closing prices. The price at a given time is the one
+// of the latest record at or before it.
+type CSVPriceSource struct {
+	prices map[string][]pricePoint // Sorted by time, per currency
+}
+
+// LoadPriceFile loads a CSV price history from path.
+func LoadPriceFile(path string) (*CSVPriceSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open price file")
+	}
+	defer file.Close()
+	return LoadPrices(file)
+}
+
+// LoadPrices loads a CSV price history. Timestamps are either unix seconds,
+// RFC 3339 times or dates, and the header row is optional.
+func LoadPrices(r io.Reader) (*CSVPriceSource, error) {
+	var report ImportReport
+	records, err := readCSV(r, []string{"timestamp", "currency", "price"}, &report)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Rejected) > 0 {
+		return nil, errors.Errorf("line %d: %s", report.Rejected[0].Line, report.Rejected[0].Reason)
+	}
+
+	source := &CSVPriceSource{prices: make(map[string][]pricePoint)}
+	for _, record := range records {
+		at, err := parsePriceTime(record.value["timestamp"])
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", record.line)
+		}
+		price, ok := new(big.Rat).SetString(record.value["price"])
+		if !ok || price.Sign() < 0 {
+			return nil, errors.Errorf("line %d: invalid price %q", record.line, record.value["price"])
+		}
+		currency := strings.ToUpper(record.value["currency"])
+		if currency == "" {
+			return nil, errors.Errorf("line %d: missing currency", record.line)
+		}
+		source.prices[currency] = append(source.prices[currency], pricePoint{at, price})
+	}
+	for _, points := range source.prices {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].at.Before(points[j].at) })
+	}
+	return source, nil
+}
+
+func parsePriceTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if at, err := time.Parse(layout, value); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("invalid timestamp %q", value)
+}
+
+func (s *CSVPriceSource) Price(currency string, at time.Time) (*big.Rat, error) {
+	points := s.prices[strings.ToUpper(currency)]
+	i := sort.Search(len(points), func(i int) bool { return points[i].at.After(at) })
+	if i == 0 {
+		return nil, errors.Errorf("no %s price at or before %s", currency, at.Format(time.RFC3339))
+	}
+	return points[i-1].price, nil
+}
+
+// HTTPPriceSource queries a price service with GET <URL>?currency=USD&timestamp=<unix seconds>,
+// which answers with {"price": "2000.50"}. NewPriceHandler serves the same
+// API, so a local stub can stand in for the real service.
+type HTTPPriceSource struct {
+	HTTPClient *http.Client
+	URL        string
+}
+
+func NewHTTPPriceSource(url string) *HTTPPriceSource {
+	return &HTTPPriceSource{HTTPClient: &http.Client{Timeout: 10 * time.Second}, URL: url}
+}
+
+type priceResponse struct {
+	Price json.Number `json:"price"` // Either a JSON number or a string, to keep its precision
+}
+
+func (s *HTTPPriceSource) Price(currency string, at time.Time) (*big.Rat, error) {
+	query := url.Values{"currency": {strings.ToUpper(currency)}, "timestamp": {strconv.FormatInt(at.Unix(), 10)}}
+	resp, err := s.HTTPClient.Get(s.URL + "?" + query.Encode())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query the price service")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, errors.Errorf("price service returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result priceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "failed to decode price")
+	}
+	price, ok := new(big.Rat).SetString(result.Price.String())
+	if !ok || price.Sign() < 0 {
+		return nil, errors.Errorf("invalid price %q", result.Price)
+	}
+	return price, nil
+}
+
+// NewPriceHandler serves the prices of source over the API HTTPPriceSource
+// queries.
+func NewPriceHandler(source PriceSource) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		currency := r.URL.Query().Get("currency")
+		seconds, err := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+		if currency == "" || err != nil {
+			http.Error(w, "expected currency and timestamp parameters", http.StatusBadRequest)
+			return
+		}
+		price, err := source.Price(currency, time.Unix(seconds, 0).UTC())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "{\"price\":%q}\n", price.FloatString(8))
+	})
+}
+
+type priceKey struct {
+	currency string
+	bucket   int64
+}
+
+// CachedPriceSource caches the prices of source by time bucket: every time
+// within a bucket gets the price at its start, queried once.
+type CachedPriceSource struct {
+	source PriceSource
+	bucket time.Duration
+	mu     sync.Mutex
+	prices map[priceKey]*big.Rat
+}
+
+func NewCachedPriceSource(source PriceSource, bucket time.Duration) *CachedPriceSource {
+	return &CachedPriceSource{source: source, bucket: bucket, prices: make(map[priceKey]*big.Rat)}
+}
+
+func (c *CachedPriceSource) Price(currency string, at time.Time) (*big.Rat, error) {
+	start := at.Truncate(c.bucket)
+	key := priceKey{strings.ToUpper(currency), start.Unix()}
+	c.mu.Lock()
+	price, ok := c.prices[key]
+	c.mu.Unlock()
+	if ok {
+		return price, nil
+	}
+
+	// Failures aren't cached, the source may have the price later on
+	price, err := c.source.Price(currency, start)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.prices[key] = price
+	c.mu.Unlock()
+	return price, nil
+}
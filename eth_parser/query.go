@@ -0,0 +1,193 @@
+package eth_parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SortOrder string
+
+const (
+	OrderAscending  SortOrder = "asc"
+	OrderDescending SortOrder = "desc"
+)
+
+// TxQuery selects a page of the transactions stored for an address. Zero
+// values disable the corresponding filter.
+type TxQuery struct {
+	Address        string
+	FromBlock      uint64 // Inclusive
+	ToBlock        uint64 // Inclusive
+	FromTime       time.Time
+	ToTime         time.Time
+	Direction      Direction
+	MinValue       *big.Int // In wei
+	Counterparties []string // Only transactions from or to one of these addresses
+	Order          SortOrder
+	Limit          int
+	Cursor         string // NextCursor of the previous page
+}
+
+type TxPage struct {
+	Transactions []Transaction
+	NextCursor   string // Empty when there are no more transactions
+}
+
+// txKey orders the transactions of an address and anchors the pagination
+// cursor, which stays valid while new transactions are being appended. Log
+// based records sort after the transaction that emitted them. Records at the
+// same position, such as pending transactions or the token transfer and
+// contract event decoded from one log, are told apart by kind and hash, so
+// that no two records share a key.
+type txKey struct {
+	block uint64
+	index uint64
+	event uint64 // 0 for the transaction itself, log index + 1 for its events
+	kind  EventKind
+	hash  string
+}
+
+func keyOf(tx *Transaction) txKey {
+	k := txKey{block: tx.BlockNum(), index: tx.Index(), kind: tx.EventKind(), hash: tx.Hash}
+	if tx.Log != nil {
+		k.event = tx.Log.LogIdx() + 1
+	}
+	return k
+}
+
+func (k txKey) less(other txKey) bool {
+	if k.block != other.block {
+		return k.block < other.block
+	}
+	if k.index != other.index {
+		return k.index < other.index
+	}
+	if k.event != other.event {
+		return k.event < other.event
+	}
+	if k.kind != other.kind {
+		return k.kind < other.kind
+	}
+	return k.hash < other.hash
+}
+
+func encodeCursor(k txKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d:%d:%s:%s", k.block, k.index, k.event, k.kind, k.hash)))
+}
+
+func decodeCursor(cursor string) (txKey, error) {
+	var k txKey
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return k, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	// Kinds and hashes hold no colon, so the fields split unambiguously
+	fields := strings.Split(string(raw), ":")
+	if len(fields) != 5 {
+		return k, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	for i, n := range []*uint64{&k.block, &k.index, &k.event} {
+		if *n, err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+			return k, fmt.Errorf("invalid cursor %q", cursor)
+		}
+	}
+	k.kind, k.hash = EventKind(fields[3]), fields[4]
+	return k, nil
+}
+
+func (q *TxQuery) Validate() error {
+	if q.Order != "" && q.Order != OrderAscending && q.Order != OrderDescending {
+		return fmt.Errorf("invalid sort order %q", q.Order)
+	}
+	if q.Direction != DirectionAny && q.Direction != DirectionIncoming && q.Direction != DirectionOutgoing && q.Direction != DirectionSelf {
+		return fmt.Errorf("invalid direction %q", q.Direction)
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("invalid limit %d", q.Limit)
+	}
+	if q.ToBlock != 0 && q.FromBlock > q.ToBlock {
+		return fmt.Errorf("from block %d is after to block %d", q.FromBlock, q.ToBlock)
+	}
+	if !q.ToTime.IsZero() && q.FromTime.After(q.ToTime) {
+		return fmt.Errorf("from time %s is after to time %s", q.FromTime, q.ToTime)
+	}
+	return nil
+}
+
+// matches applies the filters that can't be resolved by the storage ordering.
+func (q *TxQuery) matches(tx *Transaction) bool {
+	if !tx.MatchesDirection(q.Direction) {
+		return false
+	}
+	if q.MinValue != nil && tx.ValueWei().Cmp(q.MinValue) < 0 {
+		return false
+	}
+	return tx.HasCounterparty(q.Counterparties)
+}
+
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if strings.EqualFold(a, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryTransactions runs q over txs, which must be sorted by txKey. Block and
+// time bounds and the cursor are resolved with binary searches since both
+// block numbers and block timestamps grow along that order.
+func queryTransactions(txs []Transaction, q TxQuery) (TxPage, error) {
+	if err := q.Validate(); err != nil {
+		return TxPage{}, err
+	}
+
+	lo, hi := 0, len(txs)
+	if q.FromBlock != 0 {
+		lo = max(lo, sort.Search(len(txs), func(i int) bool { return txs[i].BlockNum() >= q.FromBlock }))
+	}
+	if q.ToBlock != 0 {
+		hi = min(hi, sort.Search(len(txs), func(i int) bool { return txs[i].BlockNum() > q.ToBlock }))
+	}
+	if !q.FromTime.IsZero() {
+		lo = max(lo, sort.Search(len(txs), func(i int) bool { return !txs[i].Time().Before(q.FromTime) }))
+	}
+	if !q.ToTime.IsZero() {
+		hi = min(hi, sort.Search(len(txs), func(i int) bool { return txs[i].Time().After(q.ToTime) }))
+	}
+
+	descending := q.Order == OrderDescending
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return TxPage{}, err
+		}
+		if descending {
+			hi = min(hi, sort.Search(len(txs), func(i int) bool { return !keyOf(&txs[i]).less(after) }))
+		} else {
+			lo = max(lo, sort.Search(len(txs), func(i int) bool { return after.less(keyOf(&txs[i])) }))
+		}
+	}
+
+	page := TxPage{Transactions: []Transaction{}}
+	for n := 0; n < hi-lo; n++ {
+		i := lo + n
+		if descending {
+			i = hi - 1 - n
+		}
+		if !q.matches(&txs[i]) {
+			continue
+		}
+		if q.Limit > 0 && len(page.Transactions) == q.Limit {
+			page.NextCursor = encodeCursor(keyOf(&page.Transactions[q.Limit-1]))
+			break
+		}
+		page.Transactions = append(page.Transactions, txs[i])
+	}
+	return page, nil
+}
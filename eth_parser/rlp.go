@@ -0,0 +1,78 @@
+package eth_parser
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Recursive Length Prefix encoding, the serialization Ethereum hashes and
+// signs transactions and block headers with.
+
+func rlpString(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	return append(rlpHeader(len(b), 0x80), b...)
+}
+
+func rlpList(items ...[]byte) []byte {
+	size := 0
+	for _, item := range items {
+		size += len(item)
+	}
+	out := rlpHeader(size, 0xc0)
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func rlpHeader(size int, offset byte) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+	var length []byte
+	for n := size; n > 0; n >>= 8 {
+		length = append([]byte{byte(n)}, length...)
+	}
+	return append([]byte{offset + 55 + byte(len(length))}, length...)
+}
+
+// rlpEncoder encodes the hex fields of the node's responses, keeping the
+// first malformed one as its error.
+type rlpEncoder struct {
+	err error
+}
+
+// bytes encodes hex data such as addresses, hashes and inputs.
+func (e *rlpEncoder) bytes(value string) []byte {
+	b, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil && e.err == nil {
+		e.err = errors.Wrapf(err, "invalid hex data %q", value)
+	}
+	return rlpString(b)
+}
+
+// quantity encodes a hex number as its big endian bytes, without leading
+// zeros.
+func (e *rlpEncoder) quantity(value string) []byte {
+	digits := strings.TrimLeft(strings.TrimPrefix(value, "0x"), "0")
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	b, err := hex.DecodeString(digits)
+	if err != nil && e.err == nil {
+		e.err = errors.Wrapf(err, "invalid hex quantity %q", value)
+	}
+	return rlpString(b)
+}
+
+func (e *rlpEncoder) strings(values []string, encode func(string) []byte) []byte {
+	items := make([][]byte, len(values))
+	for i, v := range values {
+		items[i] = encode(v)
+	}
+	return rlpList(items...)
+}
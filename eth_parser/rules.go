@@ -0,0 +1,175 @@
+package eth_parser
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule selects the records to alert on. Zero values disable the
+// corresponding condition, and a record must meet all the others.
+type Rule struct {
+	Name                  string        // Unique within an engine, without spaces
+	Addresses             []string      // Subscribers by address or label, any subscriber when empty
+	Direction             Direction     // Self transfers are both incoming and outgoing
+	MinValue              *big.Int      // In wei, inclusive, only met by native transfers
+	MaxValue              *big.Int      // In wei, inclusive, only met by native transfers
+	Counterparties        []string      // By address or label, any counterparty when empty
+	ExcludeCounterparties []string      // By address or label
+	NewCounterparty       bool          // The subscriber never transacted with the counterparty before
+	Methods               []string      // Called methods by name or selector, e.g. transfer or 0xa9059cbb
+	RateCount             int           // Alerts once more than RateCount records matched within RateWindow
+	RateWindow            time.Duration // Set along with RateCount
+	Cooldown              time.Duration // Repeated alerts are suppressed for this long, the DedupWindow of the engine when 0
+}
+
+// Validate checks that the rule can be evaluated.
+func (r Rule) Validate() error {
+	switch {
+	case r.Name == "" || strings.ContainsAny(r.Name, " \t\n"):
+		return fmt.Errorf("rule names can't be empty or have spaces, got %q", r.Name)
+	case r.Direction != DirectionAny && r.Direction != DirectionIncoming && r.Direction != DirectionOutgoing && r.Direction != DirectionSelf:
+		return fmt.Errorf("rule %s: invalid direction %q", r.Name, r.Direction)
+	case r.MinValue != nil && r.MaxValue != nil && r.MinValue.Cmp(r.MaxValue) > 0:
+		return fmt.Errorf("rule %s: min value is above max value", r.Name)
+	case r.RateCount < 0 || r.RateWindow < 0 || (r.RateCount > 0) != (r.RateWindow > 0):
+		return fmt.Errorf("rule %s: a rate needs both a count and a window", r.Name)
+	case r.Cooldown < 0:
+		return fmt.Errorf("rule %s: cooldown can't be negative", r.Name)
+	}
+	return nil
+}
+
+// String shows the conditions of the rule as the options of the add_rule
+// command, e.g. "--direction=outgoing --min-value=10".
+func (r Rule) String() string {
+	var options []string
+	add := func(name, value string) {
+		options = append(options, "--"+name+"="+value)
+	}
+	if len(r.Addresses) > 0 {
+		add("address", strings.Join(r.Addresses, ","))
+	}
+	if r.Direction != DirectionAny {
+		add("direction", string(r.Direction))
+	}
+	if r.MinValue != nil {
+		add("min-value", formatETHAmount(r.MinValue))
+	}
+	if r.MaxValue != nil {
+		add("max-value", formatETHAmount(r.MaxValue))
+	}
+	if len(r.Counterparties) > 0 {
+		add("counterparty", strings.Join(r.Counterparties, ","))
+	}
+	if len(r.ExcludeCounterparties) > 0 {
+		add("exclude", strings.Join(r.ExcludeCounterparties, ","))
+	}
+	if r.NewCounterparty {
+		options = append(options, "--new-counterparty")
+	}
+	if len(r.Methods) > 0 {
+		add("method", strings.Join(r.Methods, ","))
+	}
+	if r.RateCount > 0 {
+		add("rate", fmt.Sprintf("%d/%s", r.RateCount, r.RateWindow))
+	}
+	if r.Cooldown > 0 {
+		add("cooldown", r.Cooldown.String())
+	}
+	if len(options) == 0 {
+		return "any transaction"
+	}
+	return strings.Join(options, " ")
+}
+
+// ParseRate reads a rate such as "5/10m", more than 5 records within 10
+// minutes.
+func ParseRate(rate string) (int, time.Duration, error) {
+	count, window, found := strings.Cut(rate, "/")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid rate %q, expected count/window such as 5/10m", rate)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return 0, 0, fmt.Errorf("invalid rate count %q", count)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate window %q", window)
+	}
+	return n, d, nil
+}
+
+// formatETHAmount formats wei as a decimal ETH amount without trailing
+// zeros, the inverse of ParseETHAmount.
+func formatETHAmount(wei *big.Int) string {
+	amount := new(big.Rat).SetFrac(wei, big.NewInt(1e18)).FloatString(18)
+	return strings.TrimSuffix(strings.TrimRight(amount, "0"), ".")
+}
+
+// matches tells whether tx meets the conditions of the rule that don't
+// depend on other records.
+func (r *Rule) matches(tx *Transaction) bool {
+	if len(r.Addresses) > 0 && !matchesParty(r.Addresses, tx.Subscriber, tx.SubscriberLabel()) {
+		return false
+	}
+	if !tx.MatchesDirection(r.Direction) {
+		return false
+	}
+	if r.MinValue != nil || r.MaxValue != nil {
+		if tx.EventKind() != KindNativeTransfer {
+			return false
+		}
+		value := tx.ValueWei()
+		if (r.MinValue != nil && value.Cmp(r.MinValue) < 0) || (r.MaxValue != nil && value.Cmp(r.MaxValue) > 0) {
+			return false
+		}
+	}
+	counterparty, label := tx.Counterparty()
+	if len(r.Counterparties) > 0 && !matchesParty(r.Counterparties, counterparty, label) {
+		return false
+	}
+	if matchesParty(r.ExcludeCounterparties, counterparty, label) {
+		return false
+	}
+	if len(r.Methods) > 0 && (tx.Call == nil || !matchesMethod(r.Methods, tx.Call)) {
+		return false
+	}
+	return true
+}
+
+// matchesParty tells whether an address is among parties, given by address
+// or label.
+func matchesParty(parties []string, address, label string) bool {
+	for _, party := range parties {
+		if strings.HasPrefix(party, "0x") {
+			if strings.EqualFold(party, address) {
+				return true
+			}
+		} else if label != "" && strings.EqualFold(party, label) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesMethod(methods []string, call *Call) bool {
+	for _, method := range methods {
+		if strings.EqualFold(method, call.Method) || strings.EqualFold(method, call.Selector) {
+			return true
+		}
+	}
+	return false
+}
+
+// Counterparty returns the other party of tx from the point of view of its
+// subscriber, with its label. It is the subscriber itself for self transfers.
+func (tx *Transaction) Counterparty() (string, string) {
+	if strings.EqualFold(tx.Subscriber, tx.From) {
+		return tx.To, tx.ToLabel
+	}
+	return tx.From, tx.FromLabel
+}
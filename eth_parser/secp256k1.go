@@ -0,0 +1,165 @@
+package eth_parser
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// The secp256k1 curve y² = x³ + 7 Ethereum signs transactions with, just
+// enough of it to recover the public key of a signature.
+var (
+	secpP, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+	secpN, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	secpGx, _ = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+	secpGy, _ = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+
+	secpSqrtExp = new(big.Int).Rsh(new(big.Int).Add(secpP, big.NewInt(1)), 2) // p ≡ 3 mod 4
+)
+
+// jacobianPoint is the point (x/z², y/z³), which spares a modular inverse
+// per addition. z is zero for the point at infinity.
+type jacobianPoint struct {
+	x, y, z *big.Int
+}
+
+func newAffinePoint(x, y *big.Int) jacobianPoint {
+	return jacobianPoint{new(big.Int).Set(x), new(big.Int).Set(y), big.NewInt(1)}
+}
+
+func (p jacobianPoint) infinity() bool {
+	return p.z.Sign() == 0
+}
+
+func modP(v *big.Int) *big.Int {
+	return v.Mod(v, secpP)
+}
+
+func mulP(a, b *big.Int) *big.Int {
+	return modP(new(big.Int).Mul(a, b))
+}
+
+func (p jacobianPoint) double() jacobianPoint {
+	if p.infinity() || p.y.Sign() == 0 {
+		return jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	a := mulP(p.x, p.x)
+	b := mulP(p.y, p.y)
+	c := mulP(b, b)
+	d := new(big.Int).Add(p.x, b)
+	d = mulP(d, d)
+	d.Sub(d, a).Sub(d, c).Lsh(d, 1)
+	modP(d)
+	e := new(big.Int).Mul(a, big.NewInt(3))
+	f := mulP(e, e)
+
+	x := modP(new(big.Int).Sub(f, new(big.Int).Lsh(d, 1)))
+	y := mulP(e, new(big.Int).Sub(d, x))
+	y = modP(y.Sub(y, new(big.Int).Lsh(c, 3)))
+	z := modP(new(big.Int).Lsh(mulP(p.y, p.z), 1))
+	return jacobianPoint{x, y, z}
+}
+
+func (p jacobianPoint) add(q jacobianPoint) jacobianPoint {
+	if p.infinity() {
+		return q
+	}
+	if q.infinity() {
+		return p
+	}
+	pz2, qz2 := mulP(p.z, p.z), mulP(q.z, q.z)
+	u1, u2 := mulP(p.x, qz2), mulP(q.x, pz2)
+	s1, s2 := mulP(p.y, mulP(qz2, q.z)), mulP(q.y, mulP(pz2, p.z))
+	if u1.Cmp(u2) == 0 {
+		if s1.Cmp(s2) == 0 {
+			return p.double()
+		}
+		return jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	h := modP(new(big.Int).Sub(u2, u1))
+	r := modP(new(big.Int).Sub(s2, s1))
+	h2 := mulP(h, h)
+	h3 := mulP(h2, h)
+	u1h2 := mulP(u1, h2)
+
+	x := mulP(r, r)
+	x = modP(x.Sub(x, h3).Sub(x, new(big.Int).Lsh(u1h2, 1)))
+	y := mulP(r, new(big.Int).Sub(u1h2, x))
+	y = modP(y.Sub(y, mulP(s1, h3)))
+	z := mulP(h, mulP(p.z, q.z))
+	return jacobianPoint{x, y, z}
+}
+
+func (p jacobianPoint) affine() (*big.Int, *big.Int) {
+	zInv := new(big.Int).ModInverse(p.z, secpP)
+	zInv2 := mulP(zInv, zInv)
+	return mulP(p.x, zInv2), mulP(p.y, mulP(zInv2, zInv))
+}
+
+// doubleScalarMul computes a·P + b·Q in a single pass over the bits of the
+// scalars (Shamir's trick).
+func doubleScalarMul(a *big.Int, p jacobianPoint, b *big.Int, q jacobianPoint) jacobianPoint {
+	pq := p.add(q)
+	result := jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	for i := max(a.BitLen(), b.BitLen()) - 1; i >= 0; i-- {
+		result = result.double()
+		switch {
+		case a.Bit(i) == 1 && b.Bit(i) == 1:
+			result = result.add(pq)
+		case a.Bit(i) == 1:
+			result = result.add(p)
+		case b.Bit(i) == 1:
+			result = result.add(q)
+		}
+	}
+	return result
+}
+
+// ecrecover returns the address of the key that produced the signature
+// (r, s) of hash, given the recovery id telling which of the candidate
+// points r stands for.
+func ecrecover(hash []byte, r, s *big.Int, recoveryID byte) (string, error) {
+	if r.Sign() <= 0 || r.Cmp(secpN) >= 0 || s.Sign() <= 0 || s.Cmp(secpN) >= 0 {
+		return "", errors.New("signature values out of range")
+	}
+	if recoveryID > 3 {
+		return "", errors.Errorf("invalid recovery id %d", recoveryID)
+	}
+
+	// Lift r to the curve point R
+	x := new(big.Int).Set(r)
+	if recoveryID >= 2 {
+		x.Add(x, secpN)
+		if x.Cmp(secpP) >= 0 {
+			return "", errors.New("invalid recovery id for r")
+		}
+	}
+	y2 := mulP(mulP(x, x), x)
+	modP(y2.Add(y2, big.NewInt(7)))
+	y := new(big.Int).Exp(y2, secpSqrtExp, secpP)
+	if mulP(y, y).Cmp(y2) != 0 {
+		return "", errors.New("r is not on the curve")
+	}
+	if y.Bit(0) != uint(recoveryID&1) {
+		y.Sub(secpP, y)
+	}
+
+	// Q = r⁻¹(sR - eG)
+	rInv := new(big.Int).ModInverse(r, secpN)
+	e := new(big.Int).SetBytes(hash)
+	u1 := new(big.Int).Neg(e)
+	u1.Mul(u1, rInv).Mod(u1, secpN)
+	u2 := new(big.Int).Mul(s, rInv)
+	u2.Mod(u2, secpN)
+	q := doubleScalarMul(u1, newAffinePoint(secpGx, secpGy), u2, newAffinePoint(x, y))
+	if q.infinity() {
+		return "", errors.New("recovered the point at infinity")
+	}
+
+	qx, qy := q.affine()
+	pub := make([]byte, 64)
+	qx.FillBytes(pub[:32])
+	qy.FillBytes(pub[32:])
+	return "0x" + hex.EncodeToString(Keccak256(pub)[12:]), nil
+}
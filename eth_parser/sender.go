@@ -0,0 +1,141 @@
+package eth_parser
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type SenderCheck string
+
+const (
+	SenderVerified     SenderCheck = "verified"
+	SenderMismatch     SenderCheck = "mismatch"     // The signature recovers another address than From
+	SenderUnverifiable SenderCheck = "unverifiable" // Unsupported transaction type or malformed signature
+)
+
+// Transaction types of EIP-2718, legacy transactions have none
+const (
+	txTypeLegacy     = 0x0
+	txTypeAccessList = 0x1 // EIP-2930
+	txTypeDynamicFee = 0x2 // EIP-1559
+	txTypeBlob       = 0x3 // EIP-4844
+	txTypeSetCode    = 0x4 // EIP-7702
+)
+
+// errUnsupportedTxType is the cause of the errors about transactions of a
+// type outside of Ethereum mainnet, e.g. the deposits of OP Stack chains.
+var errUnsupportedTxType = errors.New("unsupported transaction type")
+
+type SenderStats struct {
+	Verified     uint64
+	Mismatches   uint64
+	Unverifiable uint64
+}
+
+// RecoverSender recovers the address that signed tx, regardless of the From
+// reported by the node.
+func RecoverSender(tx *Transaction) (string, error) {
+	hash, recoveryID, err := SigningHash(tx)
+	if err != nil {
+		return "", err
+	}
+	r, okR := new(big.Int).SetString(strings.TrimPrefix(tx.R, "0x"), 16)
+	s, okS := new(big.Int).SetString(strings.TrimPrefix(tx.S, "0x"), 16)
+	if !okR || !okS {
+		return "", errors.Errorf("invalid signature values r=%q s=%q", tx.R, tx.S)
+	}
+	return ecrecover(hash, r, s, recoveryID)
+}
+
+// SigningHash rebuilds the payload the sender signed for the type of tx and
+// returns its hash, along with the recovery id of the signature.
+func SigningHash(tx *Transaction) ([]byte, byte, error) {
+	var e rlpEncoder
+	txType, fields, err := txFields(tx, &e)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if txType == txTypeLegacy {
+		v, ok := new(big.Int).SetString(strings.TrimPrefix(tx.V, "0x"), 16)
+		if !ok {
+			return nil, 0, errors.Errorf("invalid signature value v=%q", tx.V)
+		}
+		switch {
+		case v.Cmp(big.NewInt(27)) == 0 || v.Cmp(big.NewInt(28)) == 0: // Before EIP-155
+			return Keccak256(rlpList(fields...)), byte(v.Uint64() - 27), e.err
+		case v.Cmp(big.NewInt(35)) >= 0: // v = chainId * 2 + 35 + recovery id
+			v.Sub(v, big.NewInt(35))
+			recoveryID := byte(v.Bit(0))
+			chainID := v.Rsh(v, 1).Bytes()
+			fields = append(fields, rlpString(chainID), rlpString(nil), rlpString(nil))
+			return Keccak256(rlpList(fields...)), recoveryID, e.err
+		default:
+			return nil, 0, errors.Errorf("invalid signature value v=%q", tx.V)
+		}
+	}
+
+	parity := tx.YParity
+	if parity == "" { // Older nodes only report v, which carries the parity for typed transactions
+		parity = tx.V
+	}
+	if !isHexQuantity(parity) || hexToUint64(parity) > 1 {
+		return nil, 0, errors.Errorf("invalid signature parity %q", parity)
+	}
+	payload := append([]byte{byte(txType)}, rlpList(fields...)...)
+	return Keccak256(payload), byte(hexToUint64(parity)), e.err
+}
+
+// txFields returns the RLP encoded fields of tx that its signature covers,
+// in the order of its type.
+func txFields(tx *Transaction, e *rlpEncoder) (uint64, [][]byte, error) {
+	txType := uint64(txTypeLegacy)
+	if tx.Type != "" {
+		if !isHexQuantity(tx.Type) {
+			return 0, nil, errors.Errorf("invalid transaction type %q", tx.Type)
+		}
+		txType = hexToUint64(tx.Type)
+	}
+
+	switch txType {
+	case txTypeLegacy:
+		return txType, [][]byte{
+			e.quantity(tx.Nonce), e.quantity(tx.GasPrice), e.quantity(tx.Gas),
+			e.bytes(tx.To), e.quantity(tx.Value), e.bytes(tx.Input),
+		}, nil
+	case txTypeAccessList:
+		return txType, [][]byte{
+			e.quantity(tx.ChainID), e.quantity(tx.Nonce), e.quantity(tx.GasPrice), e.quantity(tx.Gas),
+			e.bytes(tx.To), e.quantity(tx.Value), e.bytes(tx.Input), encodeAccessList(tx.AccessList, e),
+		}, nil
+	case txTypeDynamicFee, txTypeBlob, txTypeSetCode:
+		fields := [][]byte{
+			e.quantity(tx.ChainID), e.quantity(tx.Nonce), e.quantity(tx.MaxPriorityFeePerGas), e.quantity(tx.MaxFeePerGas),
+			e.quantity(tx.Gas), e.bytes(tx.To), e.quantity(tx.Value), e.bytes(tx.Input), encodeAccessList(tx.AccessList, e),
+		}
+		switch txType {
+		case txTypeBlob:
+			fields = append(fields, e.quantity(tx.MaxFeePerBlobGas), e.strings(tx.BlobVersionedHashes, e.bytes))
+		case txTypeSetCode:
+			auths := make([][]byte, len(tx.AuthorizationList))
+			for i, a := range tx.AuthorizationList {
+				auths[i] = rlpList(e.quantity(a.ChainID), e.bytes(a.Address), e.quantity(a.Nonce),
+					e.quantity(a.YParity), e.quantity(a.R), e.quantity(a.S))
+			}
+			fields = append(fields, rlpList(auths...))
+		}
+		return txType, fields, nil
+	default:
+		return 0, nil, errors.Wrapf(errUnsupportedTxType, "type %s", tx.Type)
+	}
+}
+
+func encodeAccessList(list []AccessTuple, e *rlpEncoder) []byte {
+	tuples := make([][]byte, len(list))
+	for i, tuple := range list {
+		tuples[i] = rlpList(e.bytes(tuple.Address), e.strings(tuple.StorageKeys, e.bytes))
+	}
+	return rlpList(tuples...)
+}
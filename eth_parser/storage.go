@@ -1,42 +1,95 @@
 package eth_parser
 
 import (
+	"sort"
 	"sync"
 )
 
 type Storage interface {
 	Subscribe(address string) bool
+	AddSubscription(sub Subscription) bool
+	Unsubscribe(address string, purge bool) bool // purge also drops the address' stored transactions
 	IsSubscribed(address string) bool
-	AddTransaction(address string, tx Transaction) bool
+	GetSubscription(address string) (Subscription, bool)
+	ListSubscriptions() []Subscription
+	AddTransaction(address string, tx Transaction) bool // Idempotent, duplicates are ignored
+	// UpsertTransaction inserts the transaction or replaces the stored record
+	// with the same hash and kind. created reports whether it was new.
+	UpsertTransaction(address string, tx Transaction) (created bool, ok bool)
 	GetTransactions(address string) []Transaction
+	QueryTransactions(q TxQuery) (TxPage, error)
 	SetLastProcessedBlockNum(num uint64) bool
 	GetLastProcessedBlockNum() uint64
+	// Address book, of subscribed addresses or not
+	SetLabel(address, label string) bool
+	RemoveLabel(address string) bool
+	GetLabel(address string) (string, bool)
+	ListLabels() []AddressLabel
+}
+
+// txID identifies a stored record of a subscriber. A transaction may produce
+// several records of different kinds, but only one of each, except for log
+// based records which are told apart by their log index.
+type txID struct {
+	hash     string
+	kind     EventKind
+	logIndex string
+}
+
+func idOf(tx *Transaction) txID {
+	id := txID{hash: tx.Hash, kind: tx.EventKind()}
+	if tx.Log != nil {
+		id.logIndex = tx.Log.LogIndex
+	}
+	return id
 }
 
 type MemoryStorage struct {
 	mu                    sync.Mutex
-	subscribers           map[string]bool
+	subscribers           map[string]Subscription
 	transactions          map[string][]Transaction
+	index                 map[string]map[txID]txKey // Locates stored records for deduplication
+	labels                map[string]string
 	lastProcessedBlockNum uint64
 }
 
 func NewMemoryStorage() Storage {
 	return &MemoryStorage{
-		subscribers:  make(map[string]bool),
+		subscribers:  make(map[string]Subscription),
 		transactions: make(map[string][]Transaction),
+		index:        make(map[string]map[txID]txKey),
+		labels:       make(map[string]string),
 	}
 }
 
 func (s *MemoryStorage) Subscribe(address string) bool {
+	return s.AddSubscription(Subscription{Address: address})
+}
+
+func (s *MemoryStorage) AddSubscription(sub Subscription) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, exists := s.subscribers[address]; !exists {
-		s.subscribers[address] = true
+	if _, exists := s.subscribers[sub.Address]; !exists {
+		s.subscribers[sub.Address] = sub
 		return true
 	}
 	return false
 }
 
+func (s *MemoryStorage) Unsubscribe(address string, purge bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.subscribers[address]; !exists {
+		return false
+	}
+	delete(s.subscribers, address)
+	if purge {
+		delete(s.transactions, address)
+		delete(s.index, address)
+	}
+	return true
+}
+
 func (s *MemoryStorage) IsSubscribed(address string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -44,20 +97,106 @@ func (s *MemoryStorage) IsSubscribed(address string) bool {
 	return exists
 }
 
+func (s *MemoryStorage) GetSubscription(address string) (Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, exists := s.subscribers[address]
+	return sub, exists
+}
+
+func (s *MemoryStorage) ListSubscriptions() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := make([]Subscription, 0, len(s.subscribers))
+	for _, sub := range s.subscribers {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Address < subs[j].Address })
+	return subs
+}
+
 func (s *MemoryStorage) AddTransaction(address string, tx Transaction) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, exists := s.subscribers[address]; exists {
-		s.transactions[address] = append(s.transactions[address], tx)
-		return true
+	if _, exists := s.subscribers[address]; !exists {
+		return false
 	}
-	return false
+	if _, exists := s.index[address][idOf(&tx)]; !exists {
+		s.insert(address, tx)
+	}
+	return true
+}
+
+func (s *MemoryStorage) UpsertTransaction(address string, tx Transaction) (bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.subscribers[address]; !exists {
+		return false, false
+	}
+	id := idOf(&tx)
+	key, exists := s.index[address][id]
+	if !exists {
+		s.insert(address, tx)
+		return true, true
+	}
+
+	txs := s.transactions[address]
+	i := s.find(txs, key, id)
+	if key == keyOf(&tx) {
+		txs[i] = tx
+		return false, true
+	}
+	// The record moved, e.g. its block changed, so it has to be re-sorted
+	s.transactions[address] = append(txs[:i], txs[i+1:]...)
+	s.insert(address, tx)
+	return false, true
+}
+
+func (s *MemoryStorage) insert(address string, tx Transaction) {
+	if s.index[address] == nil {
+		s.index[address] = make(map[txID]txKey)
+	}
+	s.index[address][idOf(&tx)] = keyOf(&tx)
+	s.transactions[address] = insertSorted(s.transactions[address], tx)
+}
+
+// find returns the position of the record id, stored under key.
+func (s *MemoryStorage) find(txs []Transaction, key txKey, id txID) int {
+	i := sort.Search(len(txs), func(i int) bool { return !keyOf(&txs[i]).less(key) })
+	for ; i < len(txs); i++ {
+		if idOf(&txs[i]) == id {
+			break
+		}
+	}
+	return i
 }
 
+// insertSorted keeps the transactions of an address ordered by block and
+// index. Transactions arrive in order, so this is an append in practice.
+func insertSorted(txs []Transaction, tx Transaction) []Transaction {
+	key := keyOf(&tx)
+	i := len(txs)
+	for i > 0 && key.less(keyOf(&txs[i-1])) {
+		i--
+	}
+	txs = append(txs, Transaction{})
+	copy(txs[i+1:], txs[i:])
+	txs[i] = tx
+	return txs
+}
+
+// GetTransactions returns a copy of the records of address, which upserts
+// rewrite in place.
 func (s *MemoryStorage) GetTransactions(address string) []Transaction {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.transactions[address]
+	return append([]Transaction(nil), s.transactions[address]...)
+}
+
+func (s *MemoryStorage) QueryTransactions(q TxQuery) (TxPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return queryTransactions(s.transactions[q.Address], q)
 }
 
 func (s *MemoryStorage) SetLastProcessedBlockNum(num uint64) bool {
@@ -72,3 +211,38 @@ func (s *MemoryStorage) GetLastProcessedBlockNum() uint64 {
 	defer s.mu.Unlock()
 	return s.lastProcessedBlockNum
 }
+
+func (s *MemoryStorage) SetLabel(address, label string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.labels[address] = label
+	return true
+}
+
+func (s *MemoryStorage) RemoveLabel(address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.labels[address]; !exists {
+		return false
+	}
+	delete(s.labels, address)
+	return true
+}
+
+func (s *MemoryStorage) GetLabel(address string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	label, exists := s.labels[address]
+	return label, exists
+}
+
+func (s *MemoryStorage) ListLabels() []AddressLabel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	labels := make([]AddressLabel, 0, len(s.labels))
+	for address, label := range s.labels {
+		labels = append(labels, AddressLabel{Address: address, Label: label})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Address < labels[j].Address })
+	return labels
+}
@@ -0,0 +1,167 @@
+package test
+
+import (
+	"eth-tx-parser/eth_parser"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// abiWord left pads a hex value to a 32 bytes ABI word.
+func abiWord(hexValue string) string {
+	return fmt.Sprintf("%064s", strings.TrimPrefix(hexValue, "0x"))
+}
+
+func abiInput(selector string, words ...string) string {
+	return selector + strings.Join(words, "")
+}
+
+func Test_ABIRegistry_DecodeBuiltin(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		want  *eth_parser.Call
+	}{
+		{
+			name:  "Plain transfer",
+			input: "0x",
+			want:  nil,
+		},
+		{
+			name:  "ERC-20 transfer",
+			input: abiInput("0xa9059cbb", abiWord(testAddress(2)), abiWord("de0b6b3a7640000")),
+			want: &eth_parser.Call{
+				Selector:  "0xa9059cbb",
+				Method:    "transfer",
+				Signature: "transfer(address,uint256)",
+				Args: []eth_parser.CallArg{
+					{Name: "to", Type: "address", Value: testAddress(2)},
+					{Name: "value", Type: "uint256", Value: "1000000000000000000"},
+				},
+			},
+		},
+		{
+			name: "Swap with a dynamic array",
+			input: abiInput("0x7ff36ab5",
+				abiWord("1"), abiWord("80"), abiWord(testAddress(1)), abiWord("64"),
+				abiWord("2"), abiWord(testAddress(3)), abiWord(testAddress(4)),
+			),
+			want: &eth_parser.Call{
+				Selector:  "0x7ff36ab5",
+				Method:    "swapExactETHForTokens",
+				Signature: "swapExactETHForTokens(uint256,address[],address,uint256)",
+				Args: []eth_parser.CallArg{
+					{Name: "amountOutMin", Type: "uint256", Value: "1"},
+					{Name: "path", Type: "address[]", Value: "[" + testAddress(3) + ", " + testAddress(4) + "]"},
+					{Name: "to", Type: "address", Value: testAddress(1)},
+					{Name: "deadline", Type: "uint256", Value: "100"},
+				},
+			},
+		},
+		{
+			name:  "Unknown selector",
+			input: abiInput("0xdeadbeef", abiWord("1")),
+			want:  &eth_parser.Call{Selector: "0xdeadbeef"},
+		},
+		{
+			name:  "Truncated arguments",
+			input: abiInput("0xa9059cbb", abiWord(testAddress(2))),
+			want:  &eth_parser.Call{Selector: "0xa9059cbb"},
+		},
+		{
+			name:  "Array length beyond the input",
+			input: abiInput("0x7ff36ab5", abiWord("1"), abiWord("80"), abiWord(testAddress(1)), abiWord("64"), abiWord("ffffffff")),
+			want:  &eth_parser.Call{Selector: "0x7ff36ab5"},
+		},
+	}
+
+	registry := eth_parser.NewABIRegistry()
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got := registry.Decode(testAddress(9), tc.input)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Decode() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_ABIRegistry_LoadABI(t *testing.T) {
+	abi := `{"contractName": "Registry", "abi": [
+		{"type": "event", "name": "Updated", "inputs": []},
+		{"type": "function", "name": "update", "inputs": [
+			{"name": "name", "type": "string"},
+			{"name": "delta", "type": "int64"},
+			{"name": "entry", "type": "tuple", "components": [
+				{"name": "owner", "type": "address"},
+				{"name": "ids", "type": "uint256[]"}
+			]},
+			{"name": "flags", "type": "bool[2]"}
+		]}
+	]}`
+	registry := eth_parser.NewABIRegistry()
+	if err := registry.LoadABI(testAddress(5), strings.NewReader(abi)); err != nil {
+		t.Fatalf("LoadABI() error = %v", err)
+	}
+
+	// update(string,int64,(address,uint256[]),bool[2])
+	selector := fmt.Sprintf("0x%x", eth_parser.Keccak256([]byte("update(string,int64,(address,uint256[]),bool[2])"))[:4])
+	input := abiInput(selector,
+		abiWord("a0"), strings.Repeat("f", 63)+"b", abiWord("e0"), abiWord("1"), abiWord("0"), // Head
+		abiWord("5"), "68656c6c6f"+strings.Repeat("0", 54), // name
+		abiWord(testAddress(6)), abiWord("40"), abiWord("2"), abiWord("7"), abiWord("8"), // entry
+	)
+
+	want := &eth_parser.Call{
+		Selector:  selector,
+		Method:    "update",
+		Signature: "update(string,int64,(address,uint256[]),bool[2])",
+		Args: []eth_parser.CallArg{
+			{Name: "name", Type: "string", Value: `"hello"`},
+			{Name: "delta", Type: "int64", Value: "-5"},
+			{Name: "entry", Type: "(address,uint256[])", Value: "(" + testAddress(6) + ", [7, 8])"},
+			{Name: "flags", Type: "bool[2]", Value: "[true, false]"},
+		},
+	}
+	if diff := cmp.Diff(want, registry.Decode(testAddress(5), input)); diff != "" {
+		t.Errorf("Decode() mismatch (-want +got):\n%s", diff)
+	}
+	// The ABI only applies to its contract
+	if got := registry.Decode(testAddress(6), input); got.Method != "" {
+		t.Errorf("Decode() of another contract = %+v, want an unknown method", got)
+	}
+
+	if err := registry.LoadABI(testAddress(5), strings.NewReader(`[{"type": "function", "name": "f", "inputs": [{"type": "fixed128x18"}]}]`)); err == nil {
+		t.Errorf("LoadABI() of an unsupported type succeeded")
+	}
+}
+
+func Test_EthereumParser_DecodesCalls(t *testing.T) {
+	storage := eth_parser.NewMemoryStorage()
+	storage.AddSubscription(eth_parser.Subscription{Address: testAddress(1), StartBlock: 1})
+	client := NewClientMock()
+	client.SetLatestBlockNumber(2)
+	client.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{
+		Number: "0x2",
+		Transactions: []eth_parser.Transaction{
+			{Hash: testHash(1), BlockNumber: "0x2", From: testAddress(1), To: testAddress(7), Value: "0x0",
+				Input: abiInput("0x095ea7b3", abiWord(testAddress(8)), abiWord("ff"))},
+			{Hash: testHash(2), BlockNumber: "0x2", From: testAddress(1), To: testAddress(8), Value: "0x1", Input: "0x"},
+		},
+	}})
+	storage.SetLastProcessedBlockNum(1)
+	newTestParser(t, storage, client).Poll()
+
+	txs := storage.GetTransactions(testAddress(1))
+	if len(txs) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(txs))
+	}
+	if call := txs[0].Call; call == nil || call.String() != "approve(spender="+testAddress(8)+", value=255)" {
+		t.Errorf("Call = %v, want the decoded approve call", call)
+	}
+	if txs[1].Call != nil {
+		t.Errorf("Call of a plain transfer = %v, want nil", txs[1].Call)
+	}
+}
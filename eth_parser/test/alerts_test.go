@@ -0,0 +1,243 @@
+package test
+
+import (
+	"encoding/json"
+	"eth-tx-parser/eth_parser"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testTimestamp is the block timestamp of a record minutes after a fixed
+// time.
+func testTimestamp(minutes int) string {
+	return fmt.Sprintf("0x%x", time.Date(2024, 1, 1, 0, minutes, 0, 0, time.UTC).Unix())
+}
+
+func Test_AlertEngine_Conditions(t *testing.T) {
+	treasury, exchange := testAddress(1), testAddress(2)
+	outgoing := eth_parser.Transaction{
+		Subscriber: treasury, Hash: testHash(1), From: treasury, To: exchange, Value: "0x8ac7230489e80000", // 10 ETH
+		FromLabel: "Treasury", ToLabel: "Exchange", Call: &eth_parser.Call{Selector: "0xa9059cbb", Method: "transfer"},
+	}
+	token := outgoing
+	token.Kind = eth_parser.KindTokenTransfer
+
+	tt := []struct {
+		name string
+		rule eth_parser.Rule
+		tx   eth_parser.Transaction
+		want bool
+	}{
+		{name: "Any transaction", rule: eth_parser.Rule{}, tx: outgoing, want: true},
+		{name: "Subscriber label", rule: eth_parser.Rule{Addresses: []string{"treasury"}}, tx: outgoing, want: true},
+		{name: "Other subscriber", rule: eth_parser.Rule{Addresses: []string{testAddress(3)}}, tx: outgoing},
+		{name: "Direction", rule: eth_parser.Rule{Direction: eth_parser.DirectionOutgoing}, tx: outgoing, want: true},
+		{name: "Other direction", rule: eth_parser.Rule{Direction: eth_parser.DirectionIncoming}, tx: outgoing},
+		{name: "Min value reached", rule: eth_parser.Rule{MinValue: big.NewInt(1e18)}, tx: outgoing, want: true},
+		{name: "Max value exceeded", rule: eth_parser.Rule{MaxValue: big.NewInt(1e18)}, tx: outgoing},
+		{name: "Value of a token transfer", rule: eth_parser.Rule{MinValue: big.NewInt(1)}, tx: token},
+		{name: "Counterparty address", rule: eth_parser.Rule{Counterparties: []string{"0x" + strings.ToUpper(exchange[2:])}}, tx: outgoing, want: true},
+		{name: "Excluded counterparty label", rule: eth_parser.Rule{ExcludeCounterparties: []string{"Exchange"}}, tx: outgoing},
+		{name: "Method name", rule: eth_parser.Rule{Methods: []string{"approve", "transfer"}}, tx: outgoing, want: true},
+		{name: "Method selector", rule: eth_parser.Rule{Methods: []string{"0x095ea7b3"}}, tx: outgoing},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := eth_parser.NewAlertEngine()
+			tc.rule.Name = "rule"
+			if err := engine.AddRule(tc.rule); err != nil {
+				t.Fatalf("AddRule() error = %v", err)
+			}
+
+			engine.Evaluate(tc.tx, eth_parser.NewMemoryStorage())
+
+			if got := len(engine.Recent()) == 1; got != tc.want {
+				t.Errorf("alert raised = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_AlertEngine_Message(t *testing.T) {
+	engine := eth_parser.NewAlertEngine()
+	engine.AddRule(eth_parser.Rule{Name: "treasury-outflow", Direction: eth_parser.DirectionOutgoing})
+
+	engine.Evaluate(eth_parser.Transaction{
+		Subscriber: testAddress(1), Hash: testHash(1), From: testAddress(1), To: testAddress(2), Value: "0xde0b6b3a7640000",
+		FromLabel: "Treasury", Timestamp: testTimestamp(0),
+	}, nil)
+
+	want := fmt.Sprintf("[treasury-outflow] outgoing transfer of 1.00000000 ETH from %s (Treasury) to %s, tx %s", testAddress(1), testAddress(2), testHash(1))
+	if alerts := engine.Recent(); len(alerts) != 1 || alerts[0].String() != want {
+		t.Errorf("Recent() = %v, want %q", alerts, want)
+	}
+}
+
+func Test_AlertEngine_NewCounterparty(t *testing.T) {
+	subscriber, known := testAddress(1), testAddress(2)
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(subscriber)
+	storage.AddTransaction(subscriber, eth_parser.Transaction{Subscriber: subscriber, Hash: testHash(1), BlockNumber: "0x1", From: known, To: subscriber})
+
+	engine := eth_parser.NewAlertEngine()
+	engine.AddRule(eth_parser.Rule{Name: "new-counterparty", NewCounterparty: true})
+
+	for i, to := range []string{known, testAddress(3)} {
+		tx := eth_parser.Transaction{Subscriber: subscriber, Hash: testHash(i + 2), BlockNumber: "0x2", From: subscriber, To: to}
+		storage.AddTransaction(subscriber, tx) // Records are stored before they are emitted
+		engine.Evaluate(tx, storage)
+	}
+
+	alerts := engine.Recent()
+	if len(alerts) != 1 || alerts[0].Transaction.To != testAddress(3) {
+		t.Errorf("Recent() = %v, want a single alert for %s", alerts, testAddress(3))
+	}
+}
+
+func Test_AlertEngine_RateAndDedup(t *testing.T) {
+	subscriber := testAddress(1)
+	engine := eth_parser.NewAlertEngine()
+	engine.AddRule(eth_parser.Rule{Name: "burst", RateCount: 2, RateWindow: 10 * time.Minute, Cooldown: 30 * time.Minute})
+	engine.AddRule(eth_parser.Rule{Name: "any"})
+
+	evaluate := func(hash, minute int, status eth_parser.TxStatus) {
+		engine.Evaluate(eth_parser.Transaction{
+			Subscriber: subscriber, Hash: testHash(hash), From: testAddress(2), To: subscriber, Timestamp: testTimestamp(minute), Status: status,
+		}, nil)
+	}
+	evaluate(1, 0, eth_parser.StatusPending)
+	evaluate(1, 1, eth_parser.StatusMined) // The same transaction, once mined
+	evaluate(2, 2, "")
+	evaluate(3, 15, "") // The first two left the window
+	evaluate(4, 16, "")
+	evaluate(5, 20, "")
+	evaluate(6, 21, "") // Still above the rate, but within the cooldown
+	evaluate(7, 48, "")
+	evaluate(8, 49, "")
+	evaluate(9, 50, "") // The cooldown is over
+
+	type alert struct {
+		Rule  string
+		Hash  string
+		Count int
+	}
+	var got []alert
+	for _, a := range engine.Recent() {
+		got = append(got, alert{a.Rule, a.Transaction.Hash, a.Count})
+	}
+	want := []alert{
+		{"any", testHash(1), 0},
+		{"any", testHash(2), 0},
+		{"any", testHash(3), 0},
+		{"any", testHash(4), 0},
+		{"burst", testHash(5), 3},
+		{"any", testHash(5), 0},
+		{"any", testHash(6), 0},
+		{"any", testHash(7), 0},
+		{"any", testHash(8), 0},
+		{"burst", testHash(9), 3},
+		{"any", testHash(9), 0},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Recent() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_AlertEngine_Rules(t *testing.T) {
+	engine := eth_parser.NewAlertEngine()
+	rule := eth_parser.Rule{Name: "burst", Addresses: []string{"Treasury"}, MinValue: big.NewInt(15e17), RateCount: 5, RateWindow: 10 * time.Minute}
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	if want := "--address=Treasury --min-value=1.5 --rate=5/10m0s"; rule.String() != want {
+		t.Errorf("String() = %q, want %q", rule.String(), want)
+	}
+
+	for _, invalid := range []eth_parser.Rule{
+		{Name: "burst"},
+		{Name: "two words"},
+		{Name: "range", MinValue: big.NewInt(2), MaxValue: big.NewInt(1)},
+		{Name: "rate", RateCount: 5},
+	} {
+		if err := engine.AddRule(invalid); err == nil {
+			t.Errorf("AddRule(%+v) expected an error", invalid)
+		}
+	}
+
+	if !engine.RemoveRule("burst") || engine.RemoveRule("burst") {
+		t.Error("RemoveRule() should succeed once")
+	}
+	if rules := engine.Rules(); len(rules) != 0 {
+		t.Errorf("Rules() = %v, want none", rules)
+	}
+}
+
+func Test_AlertSinks(t *testing.T) {
+	received := make(chan eth_parser.Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert eth_parser.Alert
+		json.NewDecoder(r.Body).Decode(&alert)
+		received <- alert
+	}))
+	defer server.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	var out strings.Builder
+	engine := eth_parser.NewAlertEngine(eth_parser.NewWebhookSink(failing.URL), eth_parser.NewWebhookSink(server.URL), eth_parser.NewWriterSink(&out))
+	engine.AddRule(eth_parser.Rule{Name: "any"})
+
+	engine.Evaluate(eth_parser.Transaction{Subscriber: testAddress(1), Hash: testHash(1), From: testAddress(1), To: testAddress(2), Timestamp: testTimestamp(0)}, nil)
+	engine.Stop() // Delivers the queued alerts
+
+	select {
+	case alert := <-received:
+		if alert.Rule != "any" || alert.Transaction.Hash != testHash(1) {
+			t.Errorf("webhook received %+v", alert)
+		}
+	default:
+		t.Error("expected the webhook to receive the alert, despite the failing one")
+	}
+	if want := "ALERT 2024-01-01T00:00:00Z [any] outgoing transfer"; !strings.HasPrefix(out.String(), want) {
+		t.Errorf("expected the output to start with %q, got %q", want, out.String())
+	}
+
+	// A stopped engine raises no more alerts
+	engine.Evaluate(eth_parser.Transaction{Subscriber: testAddress(1), Hash: testHash(2)}, nil)
+	if n := len(engine.Recent()); n != 1 {
+		t.Errorf("expected 1 alert, got %d", n)
+	}
+}
+
+func Test_EthereumParser_Alerts(t *testing.T) {
+	address := testAddress(1)
+	parser := setupMultiChainParser(t, address)
+	engine := eth_parser.NewAlertEngine()
+	engine.AddRule(eth_parser.Rule{Name: "exchange-deposit", Direction: eth_parser.DirectionIncoming, Counterparties: []string{"Exchange"}})
+
+	parser.Subscribe(address)
+	parser.SetLabel(testAddress(9), "Exchange")
+	for _, chain := range []string{"mainnet", "polygon"} {
+		chainParser, _ := parser.Chain(chain)
+		chainParser.(*eth_parser.EthereumParser).Alerts = engine
+	}
+	pollChains(parser, 2)
+
+	// Rules see the records of every chain, though no one listens to the feed
+	chains := map[uint64]bool{}
+	for _, alert := range engine.Recent() {
+		chains[alert.Transaction.Chain] = true
+	}
+	if !chains[1] || !chains[137] {
+		t.Errorf("expected an alert from each chain, got %v", engine.Recent())
+	}
+}
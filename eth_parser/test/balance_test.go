@@ -0,0 +1,136 @@
+package test
+
+import (
+	"errors"
+	"eth-tx-parser/eth_parser"
+	"math/big"
+	"testing"
+)
+
+// receipt of a transaction that used gasUsed gas at 1 gwei.
+func receipt(status string, gasUsed int64) *eth_parser.Receipt {
+	return &eth_parser.Receipt{Status: status, GasUsed: "0x" + big.NewInt(gasUsed).Text(16), EffectiveGasPrice: "0x3b9aca00"}
+}
+
+func Test_BalanceDelta(t *testing.T) {
+	address, other := testAddress(1), testAddress(2)
+	tt := []struct {
+		name   string
+		tx     eth_parser.Transaction
+		want   int64 // gwei
+		wantOK bool
+	}{
+		{name: "Incoming", tx: eth_parser.Transaction{From: other, To: address, Value: "0x3b9aca00"}, want: 1, wantOK: true},
+		{name: "Failed incoming", tx: eth_parser.Transaction{From: other, To: address, Value: "0x3b9aca00", Receipt: receipt("0x0", 30000)}, want: 0, wantOK: true},
+		{name: "Outgoing", tx: eth_parser.Transaction{From: address, To: other, Value: "0x3b9aca00", Receipt: receipt("0x1", 21000)}, want: -21001, wantOK: true},
+		{name: "Failed outgoing", tx: eth_parser.Transaction{From: address, To: other, Value: "0x3b9aca00", Receipt: receipt("0x0", 30000)}, want: -30000, wantOK: true},
+		{name: "Self transfer", tx: eth_parser.Transaction{From: address, To: address, Value: "0x3b9aca00", Receipt: receipt("0x1", 21000)}, want: -21000, wantOK: true},
+		{name: "Outgoing without receipt", tx: eth_parser.Transaction{From: address, To: other, Value: "0x3b9aca00"}, want: -1, wantOK: false},
+		{name: "Blob fee", tx: eth_parser.Transaction{From: address, To: other, Value: "0x0", Receipt: &eth_parser.Receipt{
+			Status: "0x1", GasUsed: "0x5208", EffectiveGasPrice: "0x3b9aca00", BlobGasUsed: "0x20000", BlobGasPrice: "0x3b9aca00",
+		}}, want: -21000 - 131072, wantOK: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			delta, ok := eth_parser.BalanceDelta(address, &tc.tx)
+			want := new(big.Int).Mul(big.NewInt(tc.want), big.NewInt(1e9))
+			if delta.Cmp(want) != 0 || ok != tc.wantOK {
+				t.Errorf("BalanceDelta() = %s, %v, want %s, %v", delta, ok, want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func Test_EthereumParser_Balance(t *testing.T) {
+	address, other := testAddress(1), testAddress(2)
+	eth := func(amount string) *big.Int {
+		wei, _ := eth_parser.ParseETHAmount(amount)
+		return wei
+	}
+
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(address)
+	storage.SetLastProcessedBlockNum(1)
+	client := NewClientMock()
+	client.SetLatestBlockNumber(1)
+	client.SetBalance(address, 1, eth("10"))
+	client.SetBalance(address, 3, eth("10.6"))
+	parser := newTestParser(t, storage, client)
+
+	report, err := parser.Balance(address)
+	if err != nil {
+		t.Fatalf("Balance() error = %v", err)
+	}
+	if report.AnchorBlock != 1 || report.Tracked.Cmp(eth("10")) != 0 || report.Discrepancy().Sign() != 0 {
+		t.Errorf("first Balance() = %+v, want tracking to start at block 1 with the reported balance", report)
+	}
+
+	// Received 1 ETH, sent 0.5 ETH for a 0.000021 ETH fee and 0.1 ETH without receipt
+	for _, tx := range []eth_parser.Transaction{
+		{Subscriber: address, Hash: testHash(1), BlockNumber: "0x1", From: other, To: address, Value: "0xde0b6b3a7640000"}, // Before tracking
+		{Subscriber: address, Hash: testHash(2), BlockNumber: "0x2", From: other, To: address, Value: "0xde0b6b3a7640000"},
+		{Subscriber: address, Hash: testHash(3), BlockNumber: "0x2", From: address, To: other, Value: "0x6f05b59d3b20000", Receipt: receipt("0x1", 21000)},
+		{Subscriber: address, Hash: testHash(4), BlockNumber: "0x3", From: address, To: other, Value: "0x16345785d8a0000"},
+		{Subscriber: address, Hash: testHash(5), BlockNumber: "0x3", Kind: eth_parser.KindTokenTransfer, From: other, To: address, Value: "0x64"},
+		{Subscriber: address, Hash: testHash(6), BlockNumber: "0x4", From: other, To: address, Value: "0xde0b6b3a7640000"}, // Not processed yet
+	} {
+		storage.AddTransaction(address, tx)
+	}
+	storage.SetLastProcessedBlockNum(3)
+
+	report, err = parser.Balance(address)
+	if err != nil {
+		t.Fatalf("Balance() error = %v", err)
+	}
+	if report.Block != 3 || report.AnchorBlock != 1 || report.MissingFees != 1 {
+		t.Errorf("Balance() = %+v, want block 3 tracked since block 1 with a missing fee", report)
+	}
+	if want := eth("10.399979"); report.Tracked.Cmp(want) != 0 {
+		t.Errorf("Tracked = %s, want %s", report.Tracked, want)
+	}
+	if want := eth("0.200021"); report.Discrepancy().Cmp(want) != 0 {
+		t.Errorf("Discrepancy() = %s, want %s", report.Discrepancy(), want)
+	}
+
+	if _, err := parser.Balance(other); !errors.Is(err, eth_parser.ErrNotSubscribed) {
+		t.Errorf("Balance() of an address that isn't subscribed error = %v, want ErrNotSubscribed", err)
+	}
+	if _, err := parser.Balance("0x123"); !errors.Is(err, eth_parser.ErrInvalidAddress) {
+		t.Errorf("Balance() of an invalid address error = %v, want ErrInvalidAddress", err)
+	}
+}
+
+func Test_EthereumParser_TrackBalances(t *testing.T) {
+	address, other := testAddress(1), testAddress(2)
+
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(address)
+	storage.SetLastProcessedBlockNum(1)
+	client := NewClientMock()
+	client.SetLatestBlockNumber(2)
+	client.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{
+		Number:       "0x2",
+		Transactions: []eth_parser.Transaction{{Hash: testHash(1), BlockNumber: "0x2", From: address, To: other, Value: "0x1"}},
+	}})
+	client.Receipts[testHash(1)] = receipt("0x1", 21000)
+	client.SetBalance(address, 2, big.NewInt(1e18))
+	parser := newTestParser(t, storage, client)
+	parser.TrackBalances = true
+	parser.ReconcileEvery = 1
+	parser.Poll()
+
+	stored := storage.GetTransactions(address)
+	if len(stored) != 1 || stored[0].Receipt == nil || *stored[0].Receipt != *receipt("0x1", 21000) {
+		t.Fatalf("expected the transaction with its receipt, got %+v", stored)
+	}
+
+	// The reconciliation after block 2 started tracking there
+	report, err := parser.Balance(address)
+	if err != nil {
+		t.Fatalf("Balance() error = %v", err)
+	}
+	if report.AnchorBlock != 2 || report.Tracked.Cmp(big.NewInt(1e18)) != 0 {
+		t.Errorf("Balance() = %+v, want tracking since block 2", report)
+	}
+}
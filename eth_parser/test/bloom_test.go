@@ -0,0 +1,45 @@
+package test
+
+import (
+	"encoding/hex"
+	"eth-tx-parser/eth_parser"
+	"testing"
+)
+
+func Test_Keccak256(t *testing.T) {
+	tt := []struct {
+		input string
+		want  string
+	}{
+		{input: "", want: "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{input: "Transfer(address,address,uint256)", want: eth_parser.TransferEventTopic[2:]},
+	}
+
+	for _, tc := range tt {
+		if got := hex.EncodeToString(eth_parser.Keccak256([]byte(tc.input))); got != tc.want {
+			t.Errorf("Keccak256(%q) = %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func Test_Bloom(t *testing.T) {
+	var bloom eth_parser.Bloom
+	address, _ := hex.DecodeString("5a0b54d5dc17e0aadc383d2db43b0a0d3e029c4c")
+	bloom.Add(eth_parser.BloomBitsOf(address))
+
+	parsed, err := eth_parser.ParseBloom(bloom.Hex())
+	if err != nil {
+		t.Fatalf("ParseBloom() error = %v", err)
+	}
+	if !parsed.Test(eth_parser.BloomBitsOf(address)) {
+		t.Errorf("bloom does not contain the added address")
+	}
+	other, _ := hex.DecodeString("0000000000000000000000000000000000000001")
+	if parsed.Test(eth_parser.BloomBitsOf(other)) {
+		t.Errorf("bloom contains an address that was never added")
+	}
+
+	if _, err := eth_parser.ParseBloom("0x1234"); err == nil {
+		t.Errorf("ParseBloom() accepted a short bloom")
+	}
+}
@@ -0,0 +1,268 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"eth-tx-parser/eth_parser"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_ImportSubscriptions(t *testing.T) {
+	tt := []struct {
+		name         string
+		format       eth_parser.Format
+		input        string
+		wantImported int
+		wantRejected []eth_parser.RejectedEntry
+	}{
+		{
+			name:   "CSV with header",
+			format: eth_parser.FormatCSV,
+			input: strings.Join([]string{
+				"address,label,start_block,tags",
+				testAddress(1) + ",hot wallet,100,exchange;hot",
+				"# Cold storage",
+				strings.ToUpper(testAddress(2)[2:]) + ",cold,,",
+				"0x" + strings.ToUpper(testAddress(3)[2:]) + ",cold,,",
+				testAddress(1) + ",again,,",
+				testAddress(4) + ",,soon,",
+				testAddress(9) + ",,,",
+			}, "\n"),
+			wantImported: 2,
+			wantRejected: []eth_parser.RejectedEntry{
+				{Line: 4, Reason: `invalid address "` + strings.ToUpper(testAddress(2)[2:]) + `"`},
+				{Line: 6, Reason: "duplicate of line 2"},
+				{Line: 7, Reason: `invalid start_block "soon"`},
+				{Line: 8, Reason: "already subscribed to " + testAddress(9)},
+			},
+		},
+		{
+			name:         "CSV without header",
+			format:       eth_parser.FormatCSV,
+			input:        testAddress(1) + ",hot wallet,100\n" + testAddress(2) + ",a,b,c,d,e,f\n",
+			wantImported: 1,
+			wantRejected: []eth_parser.RejectedEntry{
+				{Line: 2, Reason: "expected at most 6 fields, got 7"},
+			},
+		},
+		{
+			name:   "JSON",
+			format: eth_parser.FormatJSON,
+			input: `[
+				{"address": "` + testAddress(1) + `", "label": "hot wallet", "start_block": 100, "tags": ["exchange"]},
+				{"address": 12},
+				{"address": "0x123"}
+			]`,
+			wantImported: 1,
+			wantRejected: []eth_parser.RejectedEntry{
+				{Line: 2, Reason: "json: cannot unmarshal number into Go struct field Subscription.address of type string"},
+				{Line: 3, Reason: `invalid address "0x123"`},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := eth_parser.NewMemoryStorage()
+			storage.Subscribe(testAddress(9))
+			parser := eth_parser.NewEthereumParser(context.Background(), storage)
+
+			report, err := eth_parser.ImportSubscriptions(parser, strings.NewReader(tc.input), tc.format)
+			if err != nil {
+				t.Fatalf("ImportSubscriptions() error = %v", err)
+			}
+			if report.Imported != tc.wantImported {
+				t.Errorf("Imported = %d, want %d", report.Imported, tc.wantImported)
+			}
+			if report.Read != tc.wantImported+len(tc.wantRejected) {
+				t.Errorf("Read = %d, want %d", report.Read, tc.wantImported+len(tc.wantRejected))
+			}
+			if diff := cmp.Diff(tc.wantRejected, report.Rejected); diff != "" {
+				t.Errorf("Rejected mismatch (-want +got):\n%s", diff)
+			}
+
+			sub, ok := storage.GetSubscription(testAddress(1))
+			if !ok || sub.Label != "hot wallet" || sub.StartBlock != 100 {
+				t.Errorf("GetSubscription() = %+v, %v", sub, ok)
+			}
+		})
+	}
+}
+
+func Test_ExportSubscriptionsRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	subs := []eth_parser.Subscription{
+		{Address: testAddress(1), Label: "hot, wallet", StartBlock: 100, CreatedAt: createdAt, Tags: []string{"exchange", "hot"}, Chain: eth_parser.Mainnet.ChainID},
+		{Address: testAddress(2), StartBlock: 7, CreatedAt: createdAt, Chain: eth_parser.Mainnet.ChainID},
+	}
+
+	for _, format := range []eth_parser.Format{eth_parser.FormatCSV, eth_parser.FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			source := &ParserMock{ReturnListSubscriptions: subs}
+			var buf bytes.Buffer
+			if err := eth_parser.ExportSubscriptions(source, &buf, format); err != nil {
+				t.Fatalf("ExportSubscriptions() error = %v", err)
+			}
+
+			storage := eth_parser.NewMemoryStorage()
+			parser := eth_parser.NewEthereumParser(context.Background(), storage)
+			report, err := eth_parser.ImportSubscriptions(parser, &buf, format)
+			if err != nil || report.Imported != 2 {
+				t.Fatalf("ImportSubscriptions() = %+v, %v", report, err)
+			}
+			if diff := cmp.Diff(subs, storage.ListSubscriptions()); diff != "" {
+				t.Errorf("subscriptions mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_TransactionsRoundTrip(t *testing.T) {
+	txs := []eth_parser.Transaction{
+		{
+			Subscriber: testAddress(1), Kind: eth_parser.KindNativeTransfer, Status: eth_parser.StatusMined,
+			Direction: eth_parser.DirectionIncoming, Hash: testHash(1), BlockNumber: "0x10", BlockHash: testHash(100),
+			TransactionIndex: "0x0", Timestamp: "0x65e1c2a0", From: testAddress(2), To: testAddress(1),
+			Value: "0xde0b6b3a7640000", Gas: "0x5208", GasPrice: "0x3b9aca00", Nonce: "0x1", Input: "0x",
+			V: "0x1b", R: "0x1", S: "0x2", Fiat: eth_parser.FiatValues{"USD": "2500.00", "EUR": "2300.00"}, Chain: eth_parser.Mainnet.ChainID,
+		},
+		{
+			Subscriber: testAddress(1), Kind: eth_parser.KindTokenTransfer, Status: eth_parser.StatusMined,
+			Direction: eth_parser.DirectionOutgoing, Hash: testHash(2), BlockNumber: "0x11", BlockHash: testHash(101),
+			TransactionIndex: "0x3", Timestamp: "0x65e1c2ac", From: testAddress(1), To: testAddress(3), Value: "0x64", Chain: eth_parser.Mainnet.ChainID,
+			Log: &eth_parser.Log{
+				Address:          testAddress(4),
+				Topics:           []string{eth_parser.TransferEventTopic, eth_parser.AddressTopic(testAddress(1)), eth_parser.AddressTopic(testAddress(3))},
+				Data:             "0x64",
+				BlockNumber:      "0x11",
+				BlockHash:        testHash(101),
+				TransactionHash:  testHash(2),
+				TransactionIndex: "0x3",
+				LogIndex:         "0x5",
+			},
+		},
+	}
+
+	for _, format := range []eth_parser.Format{eth_parser.FormatCSV, eth_parser.FormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			source := eth_parser.NewMemoryStorage()
+			source.Subscribe(testAddress(1))
+			for _, tx := range txs {
+				source.AddTransaction(testAddress(1), tx)
+			}
+			var buf bytes.Buffer
+			written, err := eth_parser.ExportTransactions(eth_parser.NewEthereumParser(context.Background(), source), &buf, "", format)
+			if err != nil || written != 2 {
+				t.Fatalf("ExportTransactions() = %d, %v", written, err)
+			}
+			exported := buf.String()
+
+			storage := eth_parser.NewMemoryStorage()
+			storage.Subscribe(testAddress(1))
+			parser := eth_parser.NewEthereumParser(context.Background(), storage)
+			report, err := eth_parser.ImportTransactions(parser, strings.NewReader(exported), format)
+			if err != nil || report.Imported != 2 || len(report.Rejected) != 0 {
+				t.Fatalf("ImportTransactions() = %+v, %v", report, err)
+			}
+			if diff := cmp.Diff(txs, storage.GetTransactions(testAddress(1))); diff != "" {
+				t.Errorf("transactions mismatch (-want +got):\n%s", diff)
+			}
+
+			// Importing the same history again only replaces the stored records
+			report, _ = eth_parser.ImportTransactions(parser, strings.NewReader(exported), format)
+			if report.Imported != 0 || report.Read != 2 {
+				t.Errorf("second ImportTransactions() = %+v, want nothing imported", report)
+			}
+		})
+	}
+}
+
+func Test_ImportTransactionsRejected(t *testing.T) {
+	input := strings.Join([]string{
+		"subscriber,hash,block_number,kind,log_index,fiat",
+		testAddress(1) + "," + testHash(1) + ",0x10,,",
+		testAddress(2) + "," + testHash(2) + ",0x10,,",
+		testAddress(1) + ",0x123,0x10,,",
+		testAddress(1) + "," + testHash(3) + ",16,,",
+		testAddress(1) + "," + testHash(4) + ",0x10,token_transfer,",
+		testAddress(1) + "," + testHash(5) + ",0x10,swap,",
+		testAddress(1) + "," + testHash(7) + ",0x10,,,USD",
+		testAddress(1) + `,"` + testHash(6),
+	}, "\n")
+
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(testAddress(1))
+	parser := eth_parser.NewEthereumParser(context.Background(), storage)
+
+	report, err := eth_parser.ImportTransactions(parser, strings.NewReader(input), eth_parser.FormatCSV)
+	if err != nil {
+		t.Fatalf("ImportTransactions() error = %v", err)
+	}
+	want := eth_parser.ImportReport{
+		Read:     8,
+		Imported: 1,
+		Rejected: []eth_parser.RejectedEntry{
+			{Line: 3, Reason: "not subscribed to " + testAddress(2)},
+			{Line: 4, Reason: `invalid hash "0x123"`},
+			{Line: 5, Reason: `invalid block number "16"`},
+			{Line: 6, Reason: "token_transfer without a log index"},
+			{Line: 7, Reason: `unknown kind "swap"`},
+			{Line: 8, Reason: `invalid fiat value "" for "USD"`},
+			{Line: 9, Reason: `extraneous or missing " in quoted-field`},
+		},
+	}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("ImportTransactions() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_MultiChainParser_BulkRoundTrip(t *testing.T) {
+	address := testAddress(1)
+	source := setupMultiChainParser(t, address)
+	source.Subscribe(address)
+	source.AddSubscription(eth_parser.Subscription{Address: testAddress(2), Chain: 137})
+	pollChains(source, 2)
+
+	// An address subscribed on both chains is exported once per chain, and
+	// imported back on each of them
+	var subs bytes.Buffer
+	if err := eth_parser.ExportSubscriptions(source, &subs, eth_parser.FormatCSV); err != nil {
+		t.Fatalf("ExportSubscriptions() error = %v", err)
+	}
+	exported := subs.String()
+	parser := setupMultiChainParser(t, address)
+	report, err := eth_parser.ImportSubscriptions(parser, strings.NewReader(exported), eth_parser.FormatCSV)
+	if err != nil || report.Imported != 3 || len(report.Rejected) != 0 {
+		t.Fatalf("ImportSubscriptions() = %+v, %v", report, err)
+	}
+	type key struct {
+		Address string
+		Chain   uint64
+	}
+	keys := func(subs []eth_parser.Subscription) []key {
+		var keys []key
+		for _, sub := range subs {
+			keys = append(keys, key{sub.Address, sub.Chain})
+		}
+		return keys
+	}
+	if diff := cmp.Diff(keys(source.ListSubscriptions()), keys(parser.ListSubscriptions())); diff != "" {
+		t.Errorf("subscriptions mismatch (-want +got):\n%s", diff)
+	}
+	report, _ = eth_parser.ImportSubscriptions(parser, strings.NewReader(exported), eth_parser.FormatCSV)
+	if report.Imported != 0 || len(report.Rejected) != 3 {
+		t.Errorf("second ImportSubscriptions() = %+v, want every entry already subscribed", report)
+	}
+
+	// The history of each chain is written once, though the address is
+	// listed on both
+	var txs bytes.Buffer
+	written, err := eth_parser.ExportTransactions(source, &txs, "", eth_parser.FormatJSONL)
+	if err != nil || written != 2 {
+		t.Errorf("ExportTransactions() = %d, %v, want the transaction of each chain", written, err)
+	}
+}
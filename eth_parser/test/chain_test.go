@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"eth-tx-parser/eth_parser"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testChain is a chain whose parser polls fast, meant to be pointed at a mock
+// node.
+func testChain(id uint64, name, symbol string) eth_parser.ChainConfig {
+	return eth_parser.ChainConfig{
+		ChainID:        id,
+		Name:           name,
+		RPCURLs:        []string{"http://localhost:0"},
+		NativeSymbol:   symbol,
+		NativeDecimals: 18,
+		BlockTime:      10 * time.Millisecond,
+	}
+}
+
+// newChainParser returns a parser of chain reading client that wasn't
+// started, so tests can drive it with Poll.
+func newChainParser(t *testing.T, chain eth_parser.ChainConfig, storage eth_parser.Storage, client *ClientMock) *eth_parser.EthereumParser {
+	t.Helper()
+	parser, err := eth_parser.NewChainParser(context.Background(), chain, storage)
+	if err != nil {
+		t.Fatalf("NewChainParser() error = %v", err)
+	}
+	parser.Client = client
+	t.Cleanup(parser.Stop)
+	return parser
+}
+
+func Test_LookupChain(t *testing.T) {
+	for _, nameOrID := range []string{"polygon", "Polygon", "137"} {
+		if chain, ok := eth_parser.LookupChain(nameOrID); !ok || chain.ChainID != 137 || chain.NativeSymbol != "POL" {
+			t.Errorf("LookupChain(%q) = %+v, %v, want Polygon", nameOrID, chain, ok)
+		}
+	}
+	if _, ok := eth_parser.LookupChain("optimism"); ok {
+		t.Error("LookupChain() found a chain that isn't registered")
+	}
+}
+
+func Test_ChainConfig_Validate(t *testing.T) {
+	valid := testChain(100, "gnosis", "XDAI")
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	tt := []struct {
+		name   string
+		modify func(c *eth_parser.ChainConfig)
+	}{
+		{name: "No chain ID", modify: func(c *eth_parser.ChainConfig) { c.ChainID = 0 }},
+		{name: "No name", modify: func(c *eth_parser.ChainConfig) { c.Name = "" }},
+		{name: "No RPC endpoint", modify: func(c *eth_parser.ChainConfig) { c.RPCURLs = nil }},
+		{name: "No symbol", modify: func(c *eth_parser.ChainConfig) { c.NativeSymbol = "" }},
+		{name: "Negative decimals", modify: func(c *eth_parser.ChainConfig) { c.NativeDecimals = -1 }},
+		{name: "No block time", modify: func(c *eth_parser.ChainConfig) { c.BlockTime = 0 }},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			chain := valid
+			tc.modify(&chain)
+			if err := chain.Validate(); err == nil {
+				t.Error("Validate() expected an error")
+			}
+			if _, err := eth_parser.NewChainParser(context.Background(), chain, nil); err == nil {
+				t.Error("NewChainParser() expected an error")
+			}
+		})
+	}
+}
+
+func Test_Transaction_ETHAmount(t *testing.T) {
+	eth_parser.RegisterChain(testChain(100, "gnosis", "XDAI"))
+	usdc := testChain(101, "six decimals", "USDC")
+	usdc.NativeDecimals = 6
+	eth_parser.RegisterChain(usdc)
+
+	tt := []struct {
+		chain uint64
+		want  string
+	}{
+		{chain: 0, want: "1.50000000 ETH"}, // Untagged
+		{chain: eth_parser.Mainnet.ChainID, want: "1.50000000 ETH"},
+		{chain: eth_parser.Polygon.ChainID, want: "1.50000000 POL"},
+		{chain: 100, want: "1.50000000 XDAI"},
+		{chain: 101, want: "1500000000000.00000000 USDC"},
+	}
+	for _, tc := range tt {
+		tx := eth_parser.Transaction{Value: "0x14d1120d7b160000", Chain: tc.chain} // 1.5e18
+		if got := tx.ETHAmount(); got != tc.want {
+			t.Errorf("ETHAmount() on chain %d = %q, want %q", tc.chain, got, tc.want)
+		}
+	}
+	if got := eth_parser.Polygon.FormatAmount(big.NewInt(1e17)); got != "0.10000000 POL" {
+		t.Errorf("FormatAmount() = %q", got)
+	}
+}
+
+func Test_EthereumParser_Confirmations(t *testing.T) {
+	address := testAddress(1)
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(address)
+	storage.SetLastProcessedBlockNum(1)
+
+	chain := testChain(100, "gnosis", "XDAI")
+	chain.Confirmations = 2
+	client := NewClientMock()
+	client.SetLatestBlockNumber(3)
+	client.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{
+		Number:       "0x2",
+		Transactions: []eth_parser.Transaction{{Hash: testHash(1), BlockNumber: "0x2", From: testAddress(2), To: address, Value: "0x1"}},
+	}})
+	parser := newChainParser(t, chain, storage, client)
+
+	parser.Poll()
+	if got := storage.GetLastProcessedBlockNum(); got != 1 {
+		t.Fatalf("processed up to block %d with only 1 confirmation of block 2", got)
+	}
+
+	client.SetLatestBlockNumber(4)
+	parser.Poll()
+	stored := storage.GetTransactions(address)
+	if len(stored) != 1 || stored[0].Chain != 100 {
+		t.Fatalf("expected the transaction of block 2 tagged with chain 100, got %+v", stored)
+	}
+}
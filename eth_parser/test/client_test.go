@@ -1,11 +1,14 @@
 package test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"eth-tx-parser/eth_parser"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 )
 
@@ -55,6 +58,52 @@ func setupMockServer() *httptest.Server {
 			return
 		}
 
+		// Handle eth_getBalance request
+		if reqBody["method"] == "eth_getBalance" {
+			jsonResponse := []byte(`{"jsonrpc":"2.0","id":1,"result":"0xde0b6b3a7640000"}`)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(jsonResponse)
+			return
+		}
+
+		// Handle eth_getTransactionReceipt request, unknown for any other hash
+		if reqBody["method"] == "eth_getTransactionReceipt" {
+			jsonResponse := []byte(`{"jsonrpc":"2.0","id":1,"result":null}`)
+			if params, _ := reqBody["params"].([]interface{}); len(params) == 1 && params[0] == "0xthash" {
+				jsonResponse = []byte(`{"jsonrpc":"2.0","id":1,"result":{"status":"0x1","gasUsed":"0x5208","effectiveGasPrice":"0x3b9aca00"}}`)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(jsonResponse)
+			return
+		}
+
+		// Handle eth_getTransactionByHash request, unknown for any other hash
+		if reqBody["method"] == "eth_getTransactionByHash" {
+			jsonResponse := []byte(`{"jsonrpc":"2.0","id":1,"result":null}`)
+			if params, _ := reqBody["params"].([]interface{}); len(params) == 1 && params[0] == "0xthash" {
+				jsonResponse = []byte(`{"jsonrpc":"2.0","id":1,"result":{"hash":"0xthash","from":"0xfrom","to":"0xto","nonce":"0x15","value":"0x0","blockNumber":null}}`)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(jsonResponse)
+			return
+		}
+
+		// Handle the pending transaction filter requests
+		if reqBody["method"] == "eth_newPendingTransactionFilter" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1f"}`))
+			return
+		}
+		if reqBody["method"] == "eth_getFilterChanges" {
+			jsonResponse := []byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"filter not found"}}`)
+			if params, _ := reqBody["params"].([]interface{}); len(params) == 1 && params[0] == "0x1f" {
+				jsonResponse = []byte(`{"jsonrpc":"2.0","id":1,"result":["0xthash","0xother"]}`)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(jsonResponse)
+			return
+		}
+
 		w.WriteHeader(http.StatusNotFound)
 	})
 
@@ -78,6 +127,38 @@ func Test_FetchLatestblockNum(t *testing.T) {
 	}
 }
 
+func Test_ConcurrentRequestIDs(t *testing.T) {
+	var mu sync.Mutex
+	ids := make(map[float64]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ ID float64 }
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		ids[req.ID]++
+		mu.Unlock()
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer server.Close()
+
+	client := eth_parser.NewEthereumClient()
+	client.(*eth_parser.EthereumRPCClient).EthereumRPCURL = server.URL
+
+	// The monitor, the mempool watcher and balance queries share the client
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.FetchLatestBlockNumber()
+		}()
+	}
+	wg.Wait()
+
+	if len(ids) != 20 {
+		t.Errorf("expected 20 distinct request ids, got %v", ids)
+	}
+}
+
 func Test_FetchBlockByNumber(t *testing.T) {
 	mockServer := setupMockServer()
 	defer mockServer.Close()
@@ -97,3 +178,297 @@ func Test_FetchBlockByNumber(t *testing.T) {
 		t.Errorf("expected block number %s, got %s", want, block.Result.Number)
 	}
 }
+
+// setupLogsServer serves one log per block and rejects eth_getLogs ranges
+// wider than maxRange blocks like public providers do.
+func setupLogsServer(maxRange uint64, ranges *[][2]uint64) *httptest.Server {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		var reqBody struct {
+			Method string                   `json:"method"`
+			Params []map[string]interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		w.Header().Set("Content-Type", "application/json")
+
+		if reqBody.Method != "eth_getLogs" {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+
+		var from, to uint64
+		fmt.Sscanf(reqBody.Params[0]["fromBlock"].(string), "0x%x", &from)
+		fmt.Sscanf(reqBody.Params[0]["toBlock"].(string), "0x%x", &to)
+		*ranges = append(*ranges, [2]uint64{from, to})
+		if to-from+1 > maxRange {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"query returned more than 10000 results"}}`))
+			return
+		}
+
+		logs := []eth_parser.Log{}
+		for num := from; num <= to; num++ {
+			logs = append(logs, eth_parser.Log{BlockNumber: fmt.Sprintf("0x%x", num), LogIndex: "0x0"})
+		}
+		json.NewEncoder(w).Encode(eth_parser.Logs{JsonRPC: "2.0", ID: 1, Result: logs})
+	})
+
+	return httptest.NewServer(handler)
+}
+
+func Test_FetchLogRange(t *testing.T) {
+	var ranges [][2]uint64
+	mockServer := setupLogsServer(10, &ranges)
+	defer mockServer.Close()
+
+	client := eth_parser.NewEthereumClient()
+	client.(*eth_parser.EthereumRPCClient).EthereumRPCURL = mockServer.URL
+	client.(*eth_parser.EthereumRPCClient).LogsChunkSize = 64
+
+	logs, err := client.FetchLogRange(eth_parser.LogFilter{FromBlock: 1, ToBlock: 50, Topics: [][]string{{eth_parser.TransferEventTopic}}})
+	if err != nil {
+		t.Fatalf("FetchLogRange() error = %v", err)
+	}
+
+	if len(logs) != 50 {
+		t.Fatalf("FetchLogRange() returned %d logs, want 50", len(logs))
+	}
+	for i, l := range logs {
+		if want := fmt.Sprintf("0x%x", i+1); l.BlockNumber != want {
+			t.Fatalf("log %d is from block %s, want %s", i, l.BlockNumber, want)
+		}
+	}
+	// 1-50, 1-25 and 1-12 are rejected before settling on chunks of 6 blocks
+	if len(ranges) != 3+9 {
+		t.Errorf("sent %d requests, want 12: %v", len(ranges), ranges)
+	}
+}
+
+func Test_FetchLogRange_RateLimited(t *testing.T) {
+	var ranges [][2]uint64
+	logsServer := setupLogsServer(100, &ranges)
+	defer logsServer.Close()
+	limited := false
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limited { // Same code as a range too large, but passes with time
+			limited = true
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"daily request count exceeded, request rate limited"}}`))
+			return
+		}
+		logsServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer mockServer.Close()
+
+	client := eth_parser.NewEthereumClient().(*eth_parser.EthereumRPCClient)
+	client.EthereumRPCURL = mockServer.URL
+	client.LogsChunkSize = 64
+	client.BackoffScale = 0
+
+	logs, err := client.FetchLogRange(eth_parser.LogFilter{FromBlock: 1, ToBlock: 50})
+	if err != nil {
+		t.Fatalf("FetchLogRange() error = %v", err)
+	}
+	if len(logs) != 50 || len(ranges) != 1 || ranges[0] != [2]uint64{1, 50} {
+		t.Errorf("expected the whole range to be fetched again after the backoff, got %d logs from %v", len(logs), ranges)
+	}
+}
+
+func Test_FetchLogRange_BadRequest(t *testing.T) {
+	var ranges [][2]uint64
+	logsServer := setupLogsServer(10, &ranges)
+	defer logsServer.Close()
+	bare, rejected := false, 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bare {
+			rejected++
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// The node's range error, sent with an HTTP 400
+		rec := httptest.NewRecorder()
+		logsServer.Config.Handler.ServeHTTP(rec, r)
+		if bytes.Contains(rec.Body.Bytes(), []byte(`"error"`)) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write(rec.Body.Bytes())
+	}))
+	defer mockServer.Close()
+
+	client := eth_parser.NewEthereumClient().(*eth_parser.EthereumRPCClient)
+	client.EthereumRPCURL = mockServer.URL
+	client.LogsChunkSize = 64
+
+	logs, err := client.FetchLogRange(eth_parser.LogFilter{FromBlock: 1, ToBlock: 20})
+	if err != nil {
+		t.Fatalf("FetchLogRange() error = %v", err)
+	}
+	if len(logs) != 20 {
+		t.Errorf("FetchLogRange() returned %d logs, want 20", len(logs))
+	}
+
+	// A bare HTTP 400 says nothing of the range, which isn't split
+	bare = true
+	if _, err := client.FetchLogRange(eth_parser.LogFilter{FromBlock: 1, ToBlock: 20}); err == nil {
+		t.Error("FetchLogRange() with a bad request expected an error")
+	}
+	if rejected != 1 {
+		t.Errorf("sent %d requests, want the bad request alone", rejected)
+	}
+}
+
+func Test_RPCErrorIsReturned(t *testing.T) {
+	var ranges [][2]uint64
+	mockServer := setupLogsServer(10, &ranges)
+	defer mockServer.Close()
+
+	client := eth_parser.NewEthereumClient()
+	client.(*eth_parser.EthereumRPCClient).EthereumRPCURL = mockServer.URL
+
+	_, err := client.FetchLatestBlockNumber()
+	var rpcErr *eth_parser.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("FetchLatestBlockNumber() error = %v, want the rpc error -32601", err)
+	}
+}
+
+func Test_FetchBlockHeaderByNumber(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x3039","hash":"0xhash","timestamp":"0x65a0","transactions":["0xthash"]}}`))
+	})
+	mockServer := httptest.NewServer(handler)
+	defer mockServer.Close()
+
+	client := eth_parser.NewEthereumClient()
+	client.(*eth_parser.EthereumRPCClient).EthereumRPCURL = mockServer.URL
+
+	header, err := client.FetchBlockHeaderByNumber(12345)
+	if err != nil {
+		t.Fatalf("FetchBlockHeaderByNumber() error = %v", err)
+	}
+	if header.Result.Timestamp != "0x65a0" || header.Result.Hash != "0xhash" {
+		t.Errorf("unexpected header %+v", header.Result)
+	}
+}
+
+func Test_FetchBalance(t *testing.T) {
+	mockServer := setupMockServer()
+	defer mockServer.Close()
+
+	ec := eth_parser.NewEthereumClient()
+	ec.(*eth_parser.EthereumRPCClient).EthereumRPCURL = mockServer.URL
+
+	balance, err := ec.FetchBalance("0xfrom", 12345)
+	if err != nil {
+		t.Fatalf("returned an error: %v", err)
+	}
+	if balance.String() != "1000000000000000000" {
+		t.Errorf("expected a balance of 1 ETH, got %s wei", balance)
+	}
+}
+
+func Test_FetchTransactionReceipt(t *testing.T) {
+	mockServer := setupMockServer()
+	defer mockServer.Close()
+
+	ec := eth_parser.NewEthereumClient()
+	ec.(*eth_parser.EthereumRPCClient).EthereumRPCURL = mockServer.URL
+
+	receipt, err := ec.FetchTransactionReceipt("0xthash")
+	if err != nil {
+		t.Fatalf("returned an error: %v", err)
+	}
+	want := eth_parser.Receipt{Status: "0x1", GasUsed: "0x5208", EffectiveGasPrice: "0x3b9aca00"}
+	if *receipt != want {
+		t.Errorf("expected receipt %+v, got %+v", want, *receipt)
+	}
+
+	if _, err := ec.FetchTransactionReceipt("0xunknown"); err == nil {
+		t.Error("expected an error for an unknown transaction")
+	}
+}
+
+func Test_FetchTransactionByHash(t *testing.T) {
+	mockServer := setupMockServer()
+	defer mockServer.Close()
+
+	ec := eth_parser.NewEthereumClient()
+	ec.(*eth_parser.EthereumRPCClient).EthereumRPCURL = mockServer.URL
+
+	tx, err := ec.FetchTransactionByHash("0xthash")
+	if err != nil {
+		t.Fatalf("returned an error: %v", err)
+	}
+	if tx == nil || tx.Hash != "0xthash" || tx.Nonce != "0x15" || tx.BlockNumber != "" {
+		t.Errorf("expected the pending transaction 0xthash, got %+v", tx)
+	}
+
+	tx, err = ec.FetchTransactionByHash("0xunknown")
+	if err != nil || tx != nil {
+		t.Errorf("expected no transaction and no error for an unknown hash, got %+v, %v", tx, err)
+	}
+}
+
+func Test_PendingTransactionFilter(t *testing.T) {
+	mockServer := setupMockServer()
+	defer mockServer.Close()
+
+	ec := eth_parser.NewEthereumClient()
+	ec.(*eth_parser.EthereumRPCClient).EthereumRPCURL = mockServer.URL
+
+	filterID, err := ec.NewPendingTransactionFilter()
+	if err != nil {
+		t.Fatalf("returned an error: %v", err)
+	}
+	hashes, err := ec.FetchFilterChanges(filterID)
+	if err != nil {
+		t.Fatalf("returned an error: %v", err)
+	}
+	if len(hashes) != 2 || hashes[0] != "0xthash" || hashes[1] != "0xother" {
+		t.Errorf("expected the hashes of filter %s, got %v", filterID, hashes)
+	}
+
+	if _, err := ec.FetchFilterChanges("0xexpired"); err == nil {
+		t.Error("expected an error for an unknown filter")
+	}
+}
+
+func Test_FallbackURLs(t *testing.T) {
+	mockServer := setupMockServer()
+	defer mockServer.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	client := eth_parser.NewChainClient(testChain(1, "mainnet", "ETH")).(*eth_parser.EthereumRPCClient)
+	client.EthereumRPCURL = down.URL
+	client.FallbackURLs = []string{mockServer.URL}
+	client.MaxAttempts = 2
+	client.BackoffScale = 0
+
+	got, err := client.FetchLatestBlockNumber()
+	if err != nil {
+		t.Fatalf("FetchLatestBlockNumber() error = %v", err)
+	}
+	if got.Result != "0x5b8d80" {
+		t.Errorf("got = %v, want the block number of the fallback node", got.Result)
+	}
+
+	client.FallbackURLs = nil
+	if _, err := client.FetchLatestBlockNumber(); err == nil {
+		t.Error("FetchLatestBlockNumber() without a reachable node expected an error")
+	}
+
+	// The error of the last endpoint tried is returned
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer gateway.Close()
+	client.FallbackURLs = []string{gateway.URL}
+	_, err = client.FetchLatestBlockNumber()
+	var statusErr *eth_parser.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("FetchLatestBlockNumber() error = %v, want the status of the last endpoint", err)
+	}
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"context"
+	"eth-tx-parser/eth_parser"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_EthereumParser_Labels(t *testing.T) {
+	storage := eth_parser.NewMemoryStorage()
+	parser := eth_parser.NewEthereumParser(context.Background(), storage)
+	treasury, exchange, other := testAddress(1), testAddress(2), testAddress(3)
+
+	parser.AddSubscription(eth_parser.Subscription{Address: treasury, Label: "Treasury"})
+	parser.ImportTransactions([]eth_parser.Transaction{
+		{Subscriber: treasury, Hash: testHash(1), BlockNumber: "0x1", From: treasury, To: exchange},
+		{Subscriber: treasury, Hash: testHash(2), BlockNumber: "0x2", From: other, To: treasury},
+	})
+
+	if parser.SetLabel("0x1", "Invalid") {
+		t.Error("SetLabel() of an invalid address = true, want false")
+	}
+	if parser.SetLabel(exchange, " ") {
+		t.Error("SetLabel() without a label = true, want false")
+	}
+	if !parser.SetLabel("0x"+strings.ToUpper(exchange[2:]), "Binance hot wallet") {
+		t.Fatal("SetLabel() of a checksummed address = false, want true")
+	}
+
+	labels := func(txs []eth_parser.Transaction) [][2]string {
+		var out [][2]string
+		for _, tx := range txs {
+			out = append(out, [2]string{tx.FromLabel, tx.ToLabel})
+		}
+		return out
+	}
+
+	// Subscriptions label their address when the address book doesn't
+	want := [][2]string{{"Treasury", "Binance hot wallet"}, {"", "Treasury"}}
+	if diff := cmp.Diff(want, labels(parser.GetTransactions(treasury))); diff != "" {
+		t.Errorf("GetTransactions() labels mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([][2]string{{"", ""}, {"", ""}}, labels(storage.GetTransactions(treasury))); diff != "" {
+		t.Errorf("stored labels mismatch (-want +got):\n%s", diff)
+	}
+
+	// Relabelling applies to the history
+	parser.SetLabel(treasury, "Cold wallet")
+	page, err := parser.QueryTransactions(eth_parser.TxQuery{Address: treasury, Counterparties: []string{exchange}})
+	if err != nil {
+		t.Fatalf("QueryTransactions() error = %v", err)
+	}
+	if diff := cmp.Diff([][2]string{{"Cold wallet", "Binance hot wallet"}}, labels(page.Transactions)); diff != "" {
+		t.Errorf("QueryTransactions() labels mismatch (-want +got):\n%s", diff)
+	}
+
+	wantBook := []eth_parser.AddressLabel{{Address: treasury, Label: "Cold wallet"}, {Address: exchange, Label: "Binance hot wallet"}}
+	if diff := cmp.Diff(wantBook, parser.ListLabels()); diff != "" {
+		t.Errorf("ListLabels() mismatch (-want +got):\n%s", diff)
+	}
+
+	if !parser.RemoveLabel(treasury) {
+		t.Fatal("RemoveLabel() = false, want true")
+	}
+	if parser.RemoveLabel(treasury) {
+		t.Error("RemoveLabel() of an unlabelled address = true, want false")
+	}
+	if got := parser.GetTransactions(treasury)[1].ToLabel; got != "Treasury" {
+		t.Errorf("label after removing the book entry = %q, want the subscription label", got)
+	}
+}
+
+func Test_MultiChainParser_Labels(t *testing.T) {
+	address := testAddress(1)
+	parser := setupMultiChainParser(t, address)
+
+	feed := parser.Listen()
+	parser.Subscribe(address)
+	if !parser.SetLabel(testAddress(9), "Exchange") {
+		t.Fatal("SetLabel() = false, want true")
+	}
+	polygon, _ := parser.Chain("polygon")
+	polygon.SetLabel(testAddress(5), "Bridge")
+
+	want := []eth_parser.AddressLabel{{Address: testAddress(5), Label: "Bridge"}, {Address: testAddress(9), Label: "Exchange"}}
+	if diff := cmp.Diff(want, parser.ListLabels()); diff != "" {
+		t.Errorf("ListLabels() mismatch (-want +got):\n%s", diff)
+	}
+
+	pollChains(parser, 2)
+	// Both chains label the sender in the live feed
+	for i := 0; i < 2; i++ {
+		select {
+		case tx := <-feed:
+			if tx.FromLabel != "Exchange" {
+				t.Errorf("event of chain %d labels the sender %q, want Exchange", tx.Chain, tx.FromLabel)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected an event from each chain")
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"eth-tx-parser/eth_parser"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logBuffer collects JSON log records written from other goroutines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records returns the records logged so far with the message msg.
+func (b *logBuffer) records(t *testing.T, msg string) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid log record %q: %v", line, err)
+		}
+		if record["msg"] == msg {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func newTestLogger(w *logBuffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func Test_Client_Logger(t *testing.T) {
+	mockServer := setupMockServer()
+	defer mockServer.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	var logs logBuffer
+	client := eth_parser.NewChainClient(testChain(1, "mainnet", "ETH")).(*eth_parser.EthereumRPCClient)
+	client.EthereumRPCURL = down.URL
+	client.FallbackURLs = []string{mockServer.URL}
+	client.MaxAttempts = 2
+	client.BackoffScale = 0
+	client.Logger = newTestLogger(&logs)
+
+	if _, err := client.FetchLatestBlockNumber(); err != nil {
+		t.Fatalf("FetchLatestBlockNumber() error = %v", err)
+	}
+
+	retries := logs.records(t, "rpc request failed, retrying")
+	if len(retries) != 1 || retries[0]["level"] != "DEBUG" || retries[0]["method"] != "eth_blockNumber" || retries[0]["attempt"] != float64(1) {
+		t.Errorf("expected a debug record of the retry, got %v", retries)
+	}
+	fallbacks := logs.records(t, "rpc endpoint unreachable, trying the next one")
+	if len(fallbacks) != 1 || fallbacks[0]["level"] != "WARN" || fallbacks[0]["error"] == nil {
+		t.Errorf("expected a warning about the unreachable endpoint, got %v", fallbacks)
+	}
+	if strings.Contains(logs.buf.String(), down.URL) {
+		t.Error("expected the endpoint URL, which may hold credentials, to be left out of the logs")
+	}
+}
+
+func Test_EthereumParser_Logger(t *testing.T) {
+	address := testAddress(1)
+	ep := setupChainParsers(t, address)[0]
+
+	var logs logBuffer
+	ep.Logger = newTestLogger(&logs)
+	ep.Client.(*ClientMock).SetLatestBlockNumber(3) // The mock has no block 3
+	if ep.Poll() {
+		t.Error("Poll() = true, want the monitor to stop")
+	}
+
+	records := logs.records(t, "failed to fetch block, stopping the monitor")
+	if len(records) != 1 {
+		t.Fatalf("expected a record of the failure, got %v", records)
+	}
+	if records[0]["level"] != "ERROR" || records[0]["block"] != float64(3) || records[0]["error"] != "block 3 not found" {
+		t.Errorf("unexpected record %v", records[0])
+	}
+}
@@ -0,0 +1,134 @@
+package test
+
+import (
+	"context"
+	"eth-tx-parser/eth_parser"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testAddress(i int) string {
+	return fmt.Sprintf("0x%040x", i)
+}
+
+func testHash(i int) string {
+	return fmt.Sprintf("0x%064x", i)
+}
+
+func Test_HashSetMatcher(t *testing.T) {
+	for _, prefilter := range []bool{false, true} {
+		t.Run(fmt.Sprintf("prefilter=%v", prefilter), func(t *testing.T) {
+			matcher := eth_parser.NewHashSetMatcher(prefilter)
+			if _, ok := matcher.Lookup(testAddress(1)); ok {
+				t.Errorf("empty matcher matched an address")
+			}
+
+			var subs []eth_parser.Subscription
+			for i := 0; i < 1000; i++ {
+				subs = append(subs, eth_parser.Subscription{Address: testAddress(i), StartBlock: uint64(i)})
+			}
+			matcher.Refresh(subs)
+
+			for i := 0; i < 1000; i++ {
+				sub, ok := matcher.Lookup(testAddress(i))
+				if !ok || sub.StartBlock != uint64(i) {
+					t.Fatalf("Lookup(%s) = %+v, %v", testAddress(i), sub, ok)
+				}
+			}
+			for i := 1000; i < 2000; i++ {
+				if _, ok := matcher.Lookup(testAddress(i)); ok {
+					t.Fatalf("Lookup(%s) matched an address that is not watched", testAddress(i))
+				}
+			}
+			if got := len(matcher.Subscriptions()); got != 1000 {
+				t.Errorf("Subscriptions() returned %d subscriptions, want 1000", got)
+			}
+		})
+	}
+}
+
+func Test_EthereumParser_SubscribeMany(t *testing.T) {
+	storage := eth_parser.NewMemoryStorage()
+	parser := eth_parser.NewEthereumParser(context.Background(), storage)
+
+	list := strings.Join([]string{
+		"# Deposit addresses",
+		testAddress(1),
+		"",
+		testAddress(2),
+		"not an address",
+		testAddress(1), // Duplicate
+	}, "\n")
+	subs, err := eth_parser.ReadAddressList(strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("ReadAddressList() error = %v", err)
+	}
+	if len(subs) != 4 {
+		t.Fatalf("ReadAddressList() returned %d entries, want 4", len(subs))
+	}
+
+	if added := parser.SubscribeMany(subs); added != 2 {
+		t.Errorf("SubscribeMany() = %d, want 2", added)
+	}
+	for _, addr := range []string{testAddress(1), testAddress(2)} {
+		if !storage.IsSubscribed(addr) {
+			t.Errorf("address %s was not subscribed", addr)
+		}
+	}
+}
+
+// Benchmark_MatchBlock measures the subscriber matching of a block of 200
+// transactions against watchlists of growing size, comparing the storage
+// lookups the monitor used to do with the matcher snapshots.
+func Benchmark_MatchBlock(b *testing.B) {
+	const blockTxs = 200
+
+	for _, watched := range []int{5, 1000, 200000} {
+		var subs []eth_parser.Subscription
+		storage := eth_parser.NewMemoryStorage()
+		for i := 0; i < watched; i++ {
+			subs = append(subs, eth_parser.Subscription{Address: testAddress(i)})
+			storage.Subscribe(testAddress(i))
+		}
+
+		// One in a hundred transactions touches a watched address
+		var block []eth_parser.Transaction
+		for i := 0; i < blockTxs; i++ {
+			tx := eth_parser.Transaction{From: testAddress(watched + 2*i), To: testAddress(watched + 2*i + 1)}
+			if i%100 == 0 {
+				tx.To = testAddress(i % watched)
+			}
+			block = append(block, tx)
+		}
+
+		b.Run(fmt.Sprintf("storage/%d", watched), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for _, tx := range block {
+					storage.IsSubscribed(tx.From)
+					storage.IsSubscribed(tx.To)
+				}
+			}
+		})
+
+		for _, prefilter := range []bool{false, true} {
+			matcher := eth_parser.NewHashSetMatcher(prefilter)
+			matcher.Refresh(subs)
+			b.Run(fmt.Sprintf("hashset_prefilter=%v/%d", prefilter, watched), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					for _, tx := range block {
+						matcher.Lookup(tx.From)
+						matcher.Lookup(tx.To)
+					}
+				}
+			})
+		}
+
+		b.Run(fmt.Sprintf("refresh/%d", watched), func(b *testing.B) {
+			matcher := eth_parser.NewHashSetMatcher(false)
+			for n := 0; n < b.N; n++ {
+				matcher.Refresh(subs)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package test
+
+import (
+	"eth-tx-parser/eth_parser"
+	"testing"
+	"time"
+)
+
+// setupMempoolParser returns a parser of a mock node that wasn't started,
+// so tests can configure it before watchMempool.
+func setupMempoolParser(t *testing.T, storage eth_parser.Storage) (*eth_parser.EthereumParser, *ClientMock) {
+	t.Helper()
+	storage.SetLastProcessedBlockNum(1)
+	client := NewClientMock()
+	client.SetLatestBlockNumber(1)
+	parser := newTestParser(t, storage, client)
+	parser.BlockPollingFreq = 1 * time.Millisecond
+	parser.MempoolPollingFreq = 1 * time.Millisecond
+	return parser, client
+}
+
+// watchMempool starts parser and its mempool watcher, returning the events
+// it emits.
+func watchMempool(parser *eth_parser.EthereumParser) <-chan eth_parser.Transaction {
+	feed := parser.Listen()
+	parser.Start()
+	parser.WatchMempool()
+	return feed
+}
+
+// nextEvent waits for the next event of feed.
+func nextEvent(t *testing.T, feed <-chan eth_parser.Transaction) eth_parser.Transaction {
+	t.Helper()
+	select {
+	case tx := <-feed:
+		return tx
+	case <-time.After(time.Second):
+		t.Fatal("expected an event")
+	}
+	return eth_parser.Transaction{}
+}
+
+// checkStatus checks that every transaction stored for address has one of
+// the statuses, in order.
+func checkStatus(t *testing.T, storage eth_parser.Storage, address string, statuses ...eth_parser.TxStatus) []eth_parser.Transaction {
+	t.Helper()
+	stored := storage.GetTransactions(address)
+	done := len(stored) == len(statuses)
+	for i := 0; done && i < len(stored); i++ {
+		done = stored[i].Status == statuses[i]
+	}
+	if !done {
+		t.Fatalf("expected transactions with statuses %v, got %+v", statuses, stored)
+	}
+	return stored
+}
+
+func Test_EthereumParser_PendingToMined(t *testing.T) {
+	sender, recipient, other := testAddress(1), testAddress(2), testAddress(3)
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(recipient)
+	parser, client := setupMempoolParser(t, storage)
+	feed := watchMempool(parser)
+
+	tx := eth_parser.Transaction{Hash: testHash(1), From: sender, To: recipient, Nonce: "0x7", Value: "0x1"}
+	client.AddPendingTransaction(eth_parser.Transaction{Hash: testHash(2), From: sender, To: other, Nonce: "0x8", Value: "0x1"}) // Not subscribed
+	client.AddPendingTransaction(tx)
+	if event := nextEvent(t, feed); event.Status != eth_parser.StatusPending {
+		t.Errorf("expected a pending event, got %+v", event)
+	}
+	pending := checkStatus(t, storage, recipient, eth_parser.StatusPending)
+	if pending[0].Hash != tx.Hash || pending[0].Direction != eth_parser.DirectionIncoming {
+		t.Errorf("unexpected pending record %+v", pending[0])
+	}
+
+	mined := tx
+	mined.BlockNumber = "0x2"
+	client.SetTransaction(tx.Hash, &mined)
+	client.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{Number: "0x2", Transactions: []eth_parser.Transaction{mined}}})
+	client.SetLatestBlockNumber(2)
+
+	// The mined record is emitted although it only updated the pending one
+	if event := nextEvent(t, feed); event.Status != eth_parser.StatusMined {
+		t.Errorf("expected a mined event, got %+v", event)
+	}
+	stored := checkStatus(t, storage, recipient, eth_parser.StatusMined)
+	if stored[0].BlockNumber != "0x2" {
+		t.Errorf("expected the pending record to be replaced by the mined one, got %+v", stored[0])
+	}
+}
+
+func Test_EthereumParser_PendingReplaced(t *testing.T) {
+	sender, recipient := testAddress(1), testAddress(2)
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(sender)
+	parser, client := setupMempoolParser(t, storage)
+	feed := watchMempool(parser)
+
+	original := eth_parser.Transaction{Hash: testHash(1), From: sender, To: recipient, Nonce: "0x7", Value: "0x1"}
+	client.AddPendingTransaction(original)
+	nextEvent(t, feed)
+	checkStatus(t, storage, sender, eth_parser.StatusPending)
+
+	// A speed-up of the same nonce is mined instead
+	speedUp := original
+	speedUp.Hash = testHash(2)
+	speedUp.BlockNumber = "0x2"
+	client.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{Number: "0x2", Transactions: []eth_parser.Transaction{speedUp}}})
+	client.SetLatestBlockNumber(2)
+	nextEvent(t, feed) // The mined speed-up and the replaced original
+	nextEvent(t, feed)
+
+	stored := checkStatus(t, storage, sender, eth_parser.StatusReplaced, eth_parser.StatusMined)
+	if stored[0].Hash != original.Hash || stored[0].ReplacedBy != speedUp.Hash {
+		t.Errorf("expected %s to be replaced by %s, got %+v", original.Hash, speedUp.Hash, stored[0])
+	}
+}
+
+func Test_EthereumParser_PendingDropped(t *testing.T) {
+	sender, recipient := testAddress(1), testAddress(2)
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(sender)
+	parser, client := setupMempoolParser(t, storage)
+	parser.PendingTimeout = 1 * time.Millisecond
+	feed := watchMempool(parser)
+
+	tx := eth_parser.Transaction{Hash: testHash(1), From: sender, To: recipient, Nonce: "0x7", Value: "0x1"}
+	client.AddPendingTransaction(tx)
+	nextEvent(t, feed)
+	checkStatus(t, storage, sender, eth_parser.StatusPending)
+
+	client.SetTransaction(tx.Hash, nil) // Evicted from the mempool
+	if event := nextEvent(t, feed); event.Status != eth_parser.StatusDropped {
+		t.Errorf("expected a dropped event, got %+v", event)
+	}
+	checkStatus(t, storage, sender, eth_parser.StatusDropped)
+}
+
+func Test_EthereumParser_PendingStartBlock(t *testing.T) {
+	sender, recipient := testAddress(1), testAddress(2)
+	storage := eth_parser.NewMemoryStorage()
+	storage.AddSubscription(eth_parser.Subscription{Address: sender, StartBlock: 10})
+	storage.Subscribe(recipient)
+	parser, client := setupMempoolParser(t, storage)
+	feed := watchMempool(parser)
+	parser.WatchMempool() // Already watching
+
+	client.AddPendingTransaction(eth_parser.Transaction{Hash: testHash(1), From: sender, To: recipient, Nonce: "0x7", Value: "0x1"})
+	if event := nextEvent(t, feed); event.Subscriber != recipient {
+		t.Errorf("expected a pending event for %s, got %+v", recipient, event)
+	}
+	checkStatus(t, storage, recipient, eth_parser.StatusPending)
+	// The sender's subscription doesn't monitor the next block yet
+	checkStatus(t, storage, sender)
+
+	if got := client.PendingFilters(); got != 1 {
+		t.Errorf("created %d pending transaction filters, want a single watcher's", got)
+	}
+}
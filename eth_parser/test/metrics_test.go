@@ -0,0 +1,160 @@
+package test
+
+import (
+	"bytes"
+	"eth-tx-parser/eth_parser"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Metrics_Exposition(t *testing.T) {
+	metrics := eth_parser.NewMetrics()
+	metrics.Set("eth_parser_head_block", 19000000, "mainnet")
+	metrics.Add("eth_rpc_requests_total", 1, "mainnet", "eth_blockNumber", "ok")
+	metrics.Add("eth_rpc_requests_total", 2, "mainnet", "eth_blockNumber", "ok")
+	metrics.Add("eth_rpc_requests_total", 1, "polygon", "eth_getLogs", "http_429")
+	metrics.ObserveSince("eth_parser_storage_duration_seconds", time.Now(), `ch"ain`, "upsert_transaction")
+
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	defer resp.Body.Close()
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		t.Fatalf("failed to read the metrics: %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the text exposition format", ct)
+	}
+
+	lines := strings.Split(body.String(), "\n")
+	for _, want := range []string{
+		"# TYPE eth_parser_head_block gauge",
+		"eth_parser_head_block{chain=\"mainnet\"} 19000000",
+		"# TYPE eth_rpc_requests_total counter",
+		"eth_rpc_requests_total{chain=\"mainnet\",method=\"eth_blockNumber\",status=\"ok\"} 3",
+		"eth_rpc_requests_total{chain=\"polygon\",method=\"eth_getLogs\",status=\"http_429\"} 1",
+		"# TYPE eth_parser_storage_duration_seconds histogram",
+		"eth_parser_storage_duration_seconds_bucket{chain=\"ch\\\"ain\",operation=\"upsert_transaction\",le=\"0.005\"} 1",
+		"eth_parser_storage_duration_seconds_bucket{chain=\"ch\\\"ain\",operation=\"upsert_transaction\",le=\"+Inf\"} 1",
+		"eth_parser_storage_duration_seconds_count{chain=\"ch\\\"ain\",operation=\"upsert_transaction\"} 1",
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || line == want
+		}
+		if !found {
+			t.Errorf("expected the line %q in:\n%s", want, body.String())
+		}
+	}
+
+	// Metrics are sorted by name, so scrapes are stable
+	if head, storage := strings.Index(body.String(), "eth_parser_head_block"), strings.Index(body.String(), "eth_parser_storage"); head > storage {
+		t.Error("expected the metrics to be sorted by name")
+	}
+}
+
+func Test_Metrics_Nil(t *testing.T) {
+	var metrics *eth_parser.Metrics
+	metrics.Add("eth_rpc_retries_total", 1, "mainnet", "eth_blockNumber")
+	if got := metrics.Value("eth_rpc_retries_total", "mainnet", "eth_blockNumber"); got != 0 {
+		t.Errorf("Value() = %v, want 0", got)
+	}
+}
+
+func Test_Client_Metrics(t *testing.T) {
+	mockServer := setupMockServer()
+	defer mockServer.Close()
+	attempts := 0
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mockServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer rejecting.Close()
+
+	metrics := eth_parser.NewMetrics()
+	client := eth_parser.NewChainClient(testChain(137, "polygon", "POL")).(*eth_parser.EthereumRPCClient)
+	client.EthereumRPCURL = flaky.URL
+	client.BackoffScale = 0
+	client.Metrics = metrics
+
+	if _, err := client.FetchLatestBlockNumber(); err != nil {
+		t.Fatalf("FetchLatestBlockNumber() error = %v", err)
+	}
+	client.EthereumRPCURL = rejecting.URL
+	client.FetchLatestBlockNumber()
+
+	for _, tc := range []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"eth_rpc_requests_total", []string{"polygon", "eth_blockNumber", "ok"}, 1},
+		{"eth_rpc_requests_total", []string{"polygon", "eth_blockNumber", "http_403"}, 1},
+		{"eth_rpc_retries_total", []string{"polygon", "eth_blockNumber"}, 1},
+		{"eth_rpc_request_duration_seconds", []string{"polygon", "eth_blockNumber"}, 2},
+	} {
+		if got := metrics.Value(tc.name, tc.labels...); got != tc.want {
+			t.Errorf("%s%v = %v, want %v", tc.name, tc.labels, got, tc.want)
+		}
+	}
+}
+
+func Test_EthereumParser_Metrics(t *testing.T) {
+	address := testAddress(1)
+	metrics := eth_parser.NewMetrics()
+
+	for _, ep := range setupChainParsers(t, address) {
+		ep.Subscribe(address)
+		ep.Metrics = metrics
+		ep.Client.(*ClientMock).SetLatestBlockNumber(2)
+		ep.Poll()
+	}
+
+	for _, chain := range []string{"mainnet", "polygon"} {
+		for _, tc := range []struct {
+			name string
+			want float64
+		}{
+			{"eth_parser_head_block", 2},
+			{"eth_parser_processed_block", 2},
+			{"eth_parser_block_lag", 0},
+			{"eth_parser_blocks_processed_total", 1},
+			{"eth_parser_transactions_matched_total", 1},
+			{"eth_parser_live_events_dropped_total", 1}, // No one listens to the feed
+		} {
+			if got := metrics.Value(tc.name, chain); got != tc.want {
+				t.Errorf("%s{chain=%q} = %v, want %v", tc.name, chain, got, tc.want)
+			}
+		}
+	}
+}
+
+func Test_MeteredStorage(t *testing.T) {
+	metrics := eth_parser.NewMetrics()
+	storage := eth_parser.NewMeteredStorage(eth_parser.NewMemoryStorage(), metrics, "mainnet")
+
+	storage.Subscribe(testAddress(1))
+	storage.UpsertTransaction(testAddress(1), eth_parser.Transaction{Subscriber: testAddress(1), Hash: testHash(1)})
+	storage.UpsertTransaction(testAddress(1), eth_parser.Transaction{Subscriber: testAddress(1), Hash: testHash(2)})
+
+	if txs := storage.GetTransactions(testAddress(1)); len(txs) != 2 {
+		t.Errorf("expected 2 transactions, got %d", len(txs))
+	}
+	if got := metrics.Value("eth_parser_storage_duration_seconds", "mainnet", "upsert_transaction"); got != 2 {
+		t.Errorf("expected 2 upsert observations, got %v", got)
+	}
+}
@@ -3,13 +3,30 @@ package test
 import (
 	"eth-tx-parser/eth_parser"
 	"fmt"
+	"math/big"
+	"sync"
 )
 
 type ParserMock struct {
-	ReturnGetCurrentBlock uint64
-	ReturnSubscribe       bool
-	ReturnGetTransactions []eth_parser.Transaction
-	ReturnListen          chan eth_parser.Transaction
+	ReturnGetCurrentBlock    uint64
+	ReturnSubscribe          bool
+	ReturnUnsubscribe        bool
+	ReturnSubscribeMany      int
+	LastSubscribeMany        []eth_parser.Subscription
+	ReturnListSubscriptions  []eth_parser.Subscription
+	ReturnGetTransactions    []eth_parser.Transaction
+	ReturnNextCursor         string
+	ReturnQueryErr           error
+	LastQuery                eth_parser.TxQuery
+	ReturnImportTransactions int
+	LastImportTransactions   []eth_parser.Transaction
+	ReturnBalance            eth_parser.BalanceReport
+	ReturnBalanceErr         error
+	ReturnListen             chan eth_parser.Transaction
+	ReturnSetLabel           bool
+	ReturnRemoveLabel        bool
+	ReturnListLabels         []eth_parser.AddressLabel
+	LastLabel                eth_parser.AddressLabel
 }
 
 func (m *ParserMock) GetCurrentBlock() uint64 {
@@ -20,46 +37,232 @@ func (m *ParserMock) Subscribe(address string) bool {
 	return m.ReturnSubscribe
 }
 
+func (m *ParserMock) AddSubscription(sub eth_parser.Subscription) bool {
+	return m.ReturnSubscribe
+}
+
+func (m *ParserMock) SubscribeMany(subs []eth_parser.Subscription) int {
+	m.LastSubscribeMany = subs
+	return m.ReturnSubscribeMany
+}
+
+func (m *ParserMock) Unsubscribe(address string, purge bool) bool {
+	return m.ReturnUnsubscribe
+}
+
+func (m *ParserMock) ListSubscriptions() []eth_parser.Subscription {
+	return m.ReturnListSubscriptions
+}
+
 func (m *ParserMock) GetTransactions(address string) []eth_parser.Transaction {
 	return m.ReturnGetTransactions
 }
 
+func (m *ParserMock) QueryTransactions(q eth_parser.TxQuery) (eth_parser.TxPage, error) {
+	m.LastQuery = q
+	return eth_parser.TxPage{Transactions: m.ReturnGetTransactions, NextCursor: m.ReturnNextCursor}, m.ReturnQueryErr
+}
+
+func (m *ParserMock) ImportTransactions(txs []eth_parser.Transaction) int {
+	m.LastImportTransactions = txs
+	return m.ReturnImportTransactions
+}
+
+func (m *ParserMock) Balance(address string) (eth_parser.BalanceReport, error) {
+	return m.ReturnBalance, m.ReturnBalanceErr
+}
+
+func (m *ParserMock) SetLabel(address, label string) bool {
+	m.LastLabel = eth_parser.AddressLabel{Address: address, Label: label}
+	return m.ReturnSetLabel
+}
+
+func (m *ParserMock) RemoveLabel(address string) bool {
+	return m.ReturnRemoveLabel
+}
+
+func (m *ParserMock) ListLabels() []eth_parser.AddressLabel {
+	return m.ReturnListLabels
+}
+
 func (m *ParserMock) Listen() <-chan eth_parser.Transaction {
 	return m.ReturnListen
 }
 
 func (m *ParserMock) Stop() {}
 
+// ChainRouterMock follows several chains, each served by its own ParserMock,
+// and answers for all of them with the embedded ParserMock.
+type ChainRouterMock struct {
+	ParserMock
+	ReturnChains []eth_parser.ChainConfig
+	ChainParsers map[uint64]*ParserMock // By chain ID
+}
+
+func (m *ChainRouterMock) Chains() []eth_parser.ChainConfig {
+	return m.ReturnChains
+}
+
+func (m *ChainRouterMock) Chain(nameOrID string) (eth_parser.Parser, bool) {
+	for _, chain := range m.ReturnChains {
+		if chain.Name == nameOrID || fmt.Sprint(chain.ChainID) == nameOrID {
+			return m.ChainParsers[chain.ChainID], true
+		}
+	}
+	return nil, false
+}
+
 type ClientMock struct {
-	LatestBlockNumber *eth_parser.BlockNumber
-	BlockByNumber     map[uint64]*eth_parser.Block
-	Err               error
+	HeaderByNumber  map[uint64]*eth_parser.Block
+	LogsByBlockHash map[string][]eth_parser.Log
+	RangeLogs       []eth_parser.Log               // Served by FetchLogRange
+	LogFilters      []eth_parser.LogFilter         // Filters received by FetchLogs and FetchLogRange
+	Balances        map[string]map[uint64]*big.Int // Per address and block
+	Receipts        map[string]*eth_parser.Receipt
+	Err             error
+
+	mu            sync.Mutex // Guards what tests change while the parser runs
+	latestBlock   *eth_parser.BlockNumber
+	blocks        map[uint64]*eth_parser.Block
+	txByHash      map[string]*eth_parser.Transaction
+	pendingHashes []string // Served once by FetchFilterChanges
+	filters       int      // Pending transaction filters created
 }
 
 func NewClientMock() *ClientMock {
 	return &ClientMock{
-		BlockByNumber: make(map[uint64]*eth_parser.Block),
+		HeaderByNumber:  make(map[uint64]*eth_parser.Block),
+		LogsByBlockHash: make(map[string][]eth_parser.Log),
+		Balances:        make(map[string]map[uint64]*big.Int),
+		Receipts:        make(map[string]*eth_parser.Receipt),
+		blocks:          make(map[uint64]*eth_parser.Block),
+		txByHash:        make(map[string]*eth_parser.Transaction),
 	}
 }
 
 func (m *ClientMock) FetchLatestBlockNumber() (*eth_parser.BlockNumber, error) {
-	return m.LatestBlockNumber, m.Err
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.latestBlock, m.Err
 }
 
 func (m *ClientMock) FetchBlockByNumber(blockNumber uint64) (*eth_parser.Block, error) {
-	if block, exists := m.BlockByNumber[blockNumber]; exists {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if block, exists := m.blocks[blockNumber]; exists {
 		return block, m.Err
 	}
 	return nil, fmt.Errorf("block %d not found", blockNumber)
 }
 
 func (m *ClientMock) SetLatestBlockNumber(blockNum uint64) {
-	m.LatestBlockNumber = &eth_parser.BlockNumber{
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latestBlock = &eth_parser.BlockNumber{
 		JsonRPC: "2.0",
 		Result:  fmt.Sprintf("0x%x", blockNum),
 	}
 }
 
 func (m *ClientMock) SetBlockByNumber(blockNum uint64, block *eth_parser.Block) {
-	m.BlockByNumber[blockNum] = block
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blocks[blockNum] = block
+}
+
+func (m *ClientMock) FetchLogs(filter eth_parser.LogFilter) (*eth_parser.Logs, error) {
+	m.LogFilters = append(m.LogFilters, filter)
+	return &eth_parser.Logs{Result: m.LogsByBlockHash[filter.BlockHash]}, m.Err
+}
+
+func (m *ClientMock) FetchBlockHeaderByNumber(blockNumber uint64) (*eth_parser.Block, error) {
+	if header, exists := m.HeaderByNumber[blockNumber]; exists {
+		return header, m.Err
+	}
+	return nil, fmt.Errorf("block header %d not found", blockNumber)
+}
+
+// FetchLogRange serves the RangeLogs within the filter's block range, leaving
+// the address and topic matching to the caller.
+func (m *ClientMock) FetchLogRange(filter eth_parser.LogFilter) ([]eth_parser.Log, error) {
+	m.LogFilters = append(m.LogFilters, filter)
+	var logs []eth_parser.Log
+	for _, l := range m.RangeLogs {
+		var num uint64
+		fmt.Sscanf(l.BlockNumber, "0x%x", &num)
+		if num >= filter.FromBlock && num <= filter.ToBlock {
+			logs = append(logs, l)
+		}
+	}
+	return logs, m.Err
+}
+
+func (m *ClientMock) SetBalance(address string, blockNum uint64, wei *big.Int) {
+	if m.Balances[address] == nil {
+		m.Balances[address] = make(map[uint64]*big.Int)
+	}
+	m.Balances[address][blockNum] = wei
+}
+
+func (m *ClientMock) FetchBalance(address string, blockNumber uint64) (*big.Int, error) {
+	if wei, exists := m.Balances[address][blockNumber]; exists {
+		return wei, m.Err
+	}
+	return nil, fmt.Errorf("no balance of %s at block %d", address, blockNumber)
+}
+
+func (m *ClientMock) FetchTransactionReceipt(hash string) (*eth_parser.Receipt, error) {
+	if receipt, exists := m.Receipts[hash]; exists {
+		return receipt, m.Err
+	}
+	return nil, fmt.Errorf("no receipt for transaction %s", hash)
+}
+
+// AddPendingTransaction makes tx known to the node and serves its hash at
+// the next FetchFilterChanges.
+func (m *ClientMock) AddPendingTransaction(tx eth_parser.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.txByHash[tx.Hash] = &tx
+	m.pendingHashes = append(m.pendingHashes, tx.Hash)
+}
+
+// SetTransaction changes what the node knows of a transaction, nil meaning
+// it forgot about it.
+func (m *ClientMock) SetTransaction(hash string, tx *eth_parser.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.txByHash[hash] = tx
+}
+
+func (m *ClientMock) FetchTransactionByHash(hash string) (*eth_parser.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if tx := m.txByHash[hash]; tx != nil {
+		copied := *tx
+		return &copied, m.Err
+	}
+	return nil, m.Err
+}
+
+func (m *ClientMock) NewPendingTransactionFilter() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filters++
+	return "0x1", m.Err
+}
+
+// PendingFilters returns how many pending transaction filters were created.
+func (m *ClientMock) PendingFilters() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.filters
+}
+
+func (m *ClientMock) FetchFilterChanges(filterID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hashes := m.pendingHashes
+	m.pendingHashes = nil
+	return hashes, m.Err
 }
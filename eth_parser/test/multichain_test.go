@@ -0,0 +1,270 @@
+package test
+
+import (
+	"context"
+	"eth-tx-parser/eth_parser"
+	"testing"
+	"time"
+)
+
+// setupMultiChainParser follows two chains whose block 2 holds a transfer
+// to address. The chains aren't started, pollChains drives them.
+func setupMultiChainParser(t *testing.T, address string) *eth_parser.MultiChainParser {
+	t.Helper()
+	parser, err := eth_parser.NewMultiChainParser(setupChainParsers(t, address)...)
+	if err != nil {
+		t.Fatalf("NewMultiChainParser() error = %v", err)
+	}
+	t.Cleanup(parser.Stop)
+	return parser
+}
+
+// setupChainParsers returns the parsers of the chains setupMultiChainParser
+// follows.
+func setupChainParsers(t *testing.T, address string) []*eth_parser.EthereumParser {
+	t.Helper()
+	var parsers []*eth_parser.EthereumParser
+	for i, chain := range []eth_parser.ChainConfig{testChain(1, "mainnet", "ETH"), testChain(137, "polygon", "POL")} {
+		storage := eth_parser.NewMemoryStorage()
+		storage.SetLastProcessedBlockNum(1)
+		ep, err := eth_parser.NewChainParser(context.Background(), chain, storage)
+		if err != nil {
+			t.Fatalf("NewChainParser() error = %v", err)
+		}
+		client := NewClientMock()
+		client.SetLatestBlockNumber(1)
+		client.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{
+			Number:       "0x2",
+			Transactions: []eth_parser.Transaction{{Hash: testHash(i + 1), BlockNumber: "0x2", From: testAddress(9), To: address, Value: "0x1"}},
+		}})
+		ep.Client = client
+		t.Cleanup(ep.Stop)
+		parsers = append(parsers, ep)
+	}
+	t.Cleanup(func() {
+		for _, chain := range []eth_parser.ChainConfig{eth_parser.Mainnet, eth_parser.Polygon} {
+			eth_parser.RegisterChain(chain) // Undo the registration of the test chains
+		}
+	})
+	return parsers
+}
+
+// pollChains has every chain of parser process the blocks up to blockNum.
+func pollChains(parser *eth_parser.MultiChainParser, blockNum uint64) {
+	for _, chain := range parser.Chains() {
+		chainParser, _ := parser.Chain(chain.Name)
+		ep := chainParser.(*eth_parser.EthereumParser)
+		ep.Client.(*ClientMock).SetLatestBlockNumber(blockNum)
+		ep.Poll()
+	}
+}
+
+func Test_MultiChainParser(t *testing.T) {
+	address := testAddress(1)
+	parser := setupMultiChainParser(t, address)
+
+	feed := parser.Listen()
+	if !parser.Subscribe(address) {
+		t.Fatal("Subscribe() = false, want true")
+	}
+	subs := parser.ListSubscriptions()
+	if len(subs) != 2 || subs[0].Chain != 1 || subs[1].Chain != 137 {
+		t.Fatalf("expected a subscription on each chain, got %+v", subs)
+	}
+	if !parser.AddSubscription(eth_parser.Subscription{Address: testAddress(2), Chain: 137}) {
+		t.Fatal("AddSubscription() on polygon = false, want true")
+	}
+	if parser.AddSubscription(eth_parser.Subscription{Address: testAddress(3), Chain: 10}) {
+		t.Error("AddSubscription() on a chain that isn't followed = true, want false")
+	}
+
+	for _, chain := range []string{"mainnet", "polygon"} {
+		if _, ok := parser.Chain(chain); !ok {
+			t.Fatalf("Chain(%s) not found", chain)
+		}
+	}
+	pollChains(parser, 2)
+
+	// Both chains' events arrive on the same feed, tagged with their chain
+	got := map[uint64]string{}
+	for len(got) < 2 {
+		select {
+		case tx := <-feed:
+			got[tx.Chain] = tx.ETHAmount()
+		case <-time.After(time.Second):
+			t.Fatalf("expected an event from each chain, got %v", got)
+		}
+	}
+	if got[1] != "0.00000000 ETH" || got[137] != "0.00000000 POL" {
+		t.Errorf("unexpected events %v", got)
+	}
+
+	txs := parser.GetTransactions(address)
+	if len(txs) != 2 || txs[0].Chain != 1 || txs[1].Chain != 137 {
+		t.Fatalf("expected a transaction of each chain, got %+v", txs)
+	}
+
+	// Pages go through the chains one after the other
+	var hashes []string
+	q := eth_parser.TxQuery{Address: address, Limit: 1}
+	for i := 0; i < 3; i++ {
+		page, err := parser.QueryTransactions(q)
+		if err != nil {
+			t.Fatalf("QueryTransactions() error = %v", err)
+		}
+		for _, tx := range page.Transactions {
+			hashes = append(hashes, tx.Hash)
+		}
+		if q.Cursor = page.NextCursor; q.Cursor == "" {
+			break
+		}
+	}
+	if len(hashes) != 2 || hashes[0] != testHash(1) || hashes[1] != testHash(2) {
+		t.Errorf("paged through %v, want the transaction of each chain", hashes)
+	}
+	if _, err := parser.QueryTransactions(eth_parser.TxQuery{Address: address, Cursor: "10.abc"}); err == nil {
+		t.Error("QueryTransactions() with the cursor of an unknown chain expected an error")
+	}
+	if _, err := parser.QueryTransactions(eth_parser.TxQuery{Address: address, Order: eth_parser.OrderDescending}); err == nil {
+		t.Error("QueryTransactions() in descending order across chains expected an error")
+	}
+
+	if _, err := parser.Balance(address); err == nil {
+		t.Error("Balance() across chains expected an error")
+	}
+}
+
+func Test_MultiChainParser_Listeners(t *testing.T) {
+	address := testAddress(1)
+	parser := setupMultiChainParser(t, address)
+	parser.Subscribe(address)
+
+	// Each listener gets every record, read or not while they are emitted
+	feeds := []<-chan eth_parser.Transaction{parser.Listen()}
+	for i := 0; i < 2; i++ {
+		feed, cancel := parser.NewListener()
+		defer cancel()
+		feeds = append(feeds, feed)
+	}
+	pollChains(parser, 2)
+
+	for i, feed := range feeds {
+		got := map[uint64]bool{}
+		for len(got) < 2 {
+			select {
+			case tx := <-feed:
+				got[tx.Chain] = true
+			case <-time.After(time.Second):
+				t.Fatalf("expected an event from each chain on feed %d, got %v", i, got)
+			}
+		}
+	}
+
+	feed, cancel := parser.NewListener()
+	cancel()
+	if _, ok := <-feed; ok {
+		t.Error("expected the feed of a cancelled listener to be closed")
+	}
+}
+
+func Test_MultiChainParser_Start(t *testing.T) {
+	address := testAddress(1)
+	chains := setupChainParsers(t, address)
+	parser, err := eth_parser.NewMultiChainParser(chains...)
+	if err != nil {
+		t.Fatalf("NewMultiChainParser() error = %v", err)
+	}
+	defer parser.Stop()
+	parser.Subscribe(address)
+
+	// The monitors of the chains, once started, process block 2 on their own
+	feed := parser.Listen()
+	for _, ep := range chains {
+		ep.BlockPollingFreq = time.Millisecond
+		ep.Client.(*ClientMock).SetLatestBlockNumber(2)
+		ep.Start()
+	}
+	got := map[uint64]bool{}
+	for len(got) < 2 {
+		select {
+		case tx := <-feed:
+			got[tx.Chain] = true
+		case <-time.After(time.Second):
+			t.Fatalf("expected an event from each started chain, got %v", got)
+		}
+	}
+}
+
+// countingMatcher counts the refreshes of the matcher.
+type countingMatcher struct {
+	*eth_parser.HashSetMatcher
+	refreshes int
+}
+
+func (m *countingMatcher) Refresh(subs []eth_parser.Subscription) {
+	m.refreshes++
+	m.HashSetMatcher.Refresh(subs)
+}
+
+func Test_MultiChainParser_SubscribeMany(t *testing.T) {
+	parser := setupMultiChainParser(t, testAddress(1))
+	matchers := map[string]*countingMatcher{}
+	for _, chain := range []string{"mainnet", "polygon"} {
+		chainParser, _ := parser.Chain(chain)
+		matchers[chain] = &countingMatcher{HashSetMatcher: eth_parser.NewHashSetMatcher(false)}
+		chainParser.(*eth_parser.EthereumParser).SetMatcher(matchers[chain])
+		matchers[chain].refreshes = 0
+	}
+
+	subs := []eth_parser.Subscription{{Address: testAddress(1), Chain: 137}, {Address: "not an address"}}
+	for i := 2; i < 100; i++ {
+		subs = append(subs, eth_parser.Subscription{Address: testAddress(i)})
+	}
+	if added := parser.SubscribeMany(subs); added != 99 {
+		t.Errorf("SubscribeMany() = %d, want 99", added)
+	}
+
+	// The matcher of each chain is refreshed once for the whole batch
+	for chain, want := range map[string]int{"mainnet": 98, "polygon": 99} {
+		chainParser, _ := parser.Chain(chain)
+		if got := len(chainParser.ListSubscriptions()); got != want {
+			t.Errorf("%s has %d subscriptions, want %d", chain, got, want)
+		}
+		if matchers[chain].refreshes != 1 {
+			t.Errorf("the matcher of %s was refreshed %d times, want once", chain, matchers[chain].refreshes)
+		}
+	}
+}
+
+func Test_MultiChainParser_ImportTransactions(t *testing.T) {
+	address := testAddress(1)
+	parser := setupMultiChainParser(t, address)
+	parser.Subscribe(address)
+
+	imported := parser.ImportTransactions([]eth_parser.Transaction{
+		{Subscriber: address, Hash: testHash(10), BlockNumber: "0x1"},             // Untagged, goes to the primary chain
+		{Subscriber: address, Hash: testHash(11), BlockNumber: "0x1", Chain: 137}, // Polygon
+		{Subscriber: address, Hash: testHash(12), BlockNumber: "0x1", Chain: 10},  // Not followed
+	})
+	if imported != 2 {
+		t.Fatalf("ImportTransactions() = %d, want 2", imported)
+	}
+	polygon, _ := parser.Chain("137")
+	if txs := polygon.GetTransactions(address); len(txs) != 1 || txs[0].Hash != testHash(11) {
+		t.Errorf("polygon transactions = %+v, want the one tagged with its chain", txs)
+	}
+	mainnet, _ := parser.Chain("mainnet")
+	if txs := mainnet.GetTransactions(address); len(txs) != 1 || txs[0].Chain != 1 {
+		t.Errorf("mainnet transactions = %+v, want the untagged one tagged with chain 1", txs)
+	}
+}
+
+func Test_NewMultiChainParser_DuplicateChain(t *testing.T) {
+	first, _ := eth_parser.NewChainParser(context.Background(), eth_parser.Polygon, nil)
+	second, _ := eth_parser.NewChainParser(context.Background(), eth_parser.Polygon, nil)
+	defer first.Stop()
+	defer second.Stop()
+	if _, err := eth_parser.NewMultiChainParser(first, second); err == nil {
+		t.Error("NewMultiChainParser() with a chain twice expected an error")
+	}
+}
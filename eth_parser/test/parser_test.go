@@ -2,12 +2,24 @@ package test
 
 import (
 	"context"
+	"encoding/hex"
 	"eth-tx-parser/eth_parser"
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
 )
 
+// newTestParser returns a parser reading client that wasn't started, so
+// tests can configure it first and drive it with Poll.
+func newTestParser(t *testing.T, storage eth_parser.Storage, client *ClientMock) *eth_parser.EthereumParser {
+	t.Helper()
+	parser := eth_parser.NewIdleParser(context.Background(), storage)
+	parser.Client = client
+	t.Cleanup(parser.Stop)
+	return parser
+}
+
 func Test_EthereumParser_GetCurrentBlock(t *testing.T) {
 	ctx := context.Background()
 	storage := eth_parser.NewMemoryStorage()
@@ -117,3 +129,329 @@ func Test_EthereumParser_StartMonitor(t *testing.T) {
 		t.Fatalf("expected transactions for subscribed address %s, got none", subscribedAddress)
 	}
 }
+
+func Test_EthereumParser_StopWhileEmitting(t *testing.T) {
+	address := testAddress(1)
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(address)
+	storage.SetLastProcessedBlockNum(1)
+
+	client := NewClientMock()
+	client.SetLatestBlockNumber(50)
+	for block := uint64(2); block <= 50; block++ {
+		client.SetBlockByNumber(block, &eth_parser.Block{Result: eth_parser.BlockResult{
+			Number:       fmt.Sprintf("0x%x", block),
+			Transactions: []eth_parser.Transaction{{Hash: testHash(int(block)), BlockNumber: fmt.Sprintf("0x%x", block), From: testAddress(2), To: address, Value: "0x1"}},
+		}})
+	}
+	parser := newTestParser(t, storage, client)
+	parser.BlockPollingFreq = 1 * time.Millisecond
+	parser.Start()
+
+	// Stopping while the monitor emits must close the feed once it returns,
+	// rather than under it
+	<-parser.Listen()
+	parser.Stop()
+	for range parser.Listen() {
+	}
+}
+
+func Test_EthereumParser_Unsubscribe(t *testing.T) {
+	address := "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5"
+
+	tt := []struct {
+		name    string
+		purge   bool
+		wantTxs int
+	}{
+		{name: "Keep stored transactions", purge: false, wantTxs: 1},
+		{name: "Purge stored transactions", purge: true, wantTxs: 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := eth_parser.NewMemoryStorage()
+			parser := eth_parser.NewEthereumParser(context.Background(), storage)
+
+			parser.Subscribe(address)
+			storage.AddTransaction(address, eth_parser.Transaction{Hash: "tx1"})
+
+			if !parser.Unsubscribe(address, tc.purge) {
+				t.Fatalf("Unsubscribe(%s) = false, want true", address)
+			}
+			if storage.IsSubscribed(address) {
+				t.Errorf("address %s is still subscribed", address)
+			}
+			if got := len(parser.GetTransactions(address)); got != tc.wantTxs {
+				t.Errorf("GetTransactions() returned %d transactions, want %d", got, tc.wantTxs)
+			}
+			if parser.Unsubscribe(address, tc.purge) {
+				t.Errorf("second Unsubscribe(%s) = true, want false", address)
+			}
+		})
+	}
+}
+
+func Test_EthereumParser_ListSubscriptions(t *testing.T) {
+	storage := eth_parser.NewMemoryStorage()
+	storage.SetLastProcessedBlockNum(uint64(100))
+	parser := eth_parser.NewEthereumParser(context.Background(), storage)
+
+	parser.AddSubscription(eth_parser.Subscription{
+		Address: "0x95222290DD7278AA3DDD389CC1E1D165CC4BAFE5", // Checksummed input is normalized
+		Label:   "Treasury",
+		Tags:    []string{"cold"},
+	})
+	parser.AddSubscription(eth_parser.Subscription{
+		Address:    "0x0000000000000000000000000000000000000001",
+		StartBlock: 50,
+	})
+
+	subs := parser.ListSubscriptions()
+	if len(subs) != 2 {
+		t.Fatalf("ListSubscriptions() returned %d subscriptions, want 2", len(subs))
+	}
+	if subs[0].Address != "0x0000000000000000000000000000000000000001" || subs[0].StartBlock != 50 {
+		t.Errorf("first subscription = %+v, want explicit start block 50", subs[0])
+	}
+	got := subs[1]
+	if got.Address != "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5" || got.Label != "Treasury" || !reflect.DeepEqual(got.Tags, []string{"cold"}) {
+		t.Errorf("second subscription = %+v, want normalized address with label and tags", got)
+	}
+	if got.StartBlock != 101 {
+		t.Errorf("StartBlock = %d, want the next block 101", got.StartBlock)
+	}
+	if got.CreatedAt.IsZero() {
+		t.Errorf("CreatedAt was not set")
+	}
+}
+
+func Test_EthereumParser_ReplayBlockRange(t *testing.T) {
+	subscribedAddress := "0xabc123"
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(subscribedAddress)
+	storage.SetLastProcessedBlockNum(1)
+
+	clientMock := NewClientMock()
+	clientMock.SetLatestBlockNumber(3)
+	for _, num := range []uint64{2, 3} {
+		clientMock.SetBlockByNumber(num, &eth_parser.Block{
+			Result: eth_parser.BlockResult{
+				Number: fmt.Sprintf("0x%x", num),
+				Transactions: []eth_parser.Transaction{
+					{Hash: fmt.Sprintf("0xtx%d", num), BlockNumber: fmt.Sprintf("0x%x", num), From: subscribedAddress, To: "0xdef456", Value: "0x1"},
+				},
+			},
+		})
+	}
+
+	parser := newTestParser(t, storage, clientMock)
+	parser.Poll()
+	// Simulate a crash before the progress was saved so the range is processed again
+	storage.SetLastProcessedBlockNum(1)
+	parser.Poll()
+
+	txs := storage.GetTransactions(subscribedAddress)
+	if len(txs) != 2 {
+		t.Fatalf("stored %d transactions after replaying the range, want 2", len(txs))
+	}
+	for _, tx := range txs {
+		if tx.Status != eth_parser.StatusMined || tx.Kind != eth_parser.KindNativeTransfer {
+			t.Errorf("transaction %s has status %q and kind %q", tx.Hash, tx.Status, tx.Kind)
+		}
+	}
+}
+
+func Test_EthereumParser_RecordsEverySubscriberParty(t *testing.T) {
+	alice, bob := "0xa11ce", "0xb0b"
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(alice)
+	storage.Subscribe(bob)
+	storage.SetLastProcessedBlockNum(1)
+
+	clientMock := NewClientMock()
+	clientMock.SetLatestBlockNumber(2)
+	clientMock.SetBlockByNumber(2, &eth_parser.Block{
+		Result: eth_parser.BlockResult{
+			Number: "0x2",
+			Transactions: []eth_parser.Transaction{
+				{Hash: "0xab", BlockNumber: "0x2", TransactionIndex: "0x0", From: alice, To: bob, Value: "0x1"},
+				{Hash: "0xaa", BlockNumber: "0x2", TransactionIndex: "0x1", From: alice, To: alice, Value: "0x1"},
+			},
+		},
+	})
+
+	newTestParser(t, storage, clientMock).Poll()
+
+	got := map[string][]eth_parser.Direction{}
+	for _, addr := range []string{alice, bob} {
+		for _, tx := range storage.GetTransactions(addr) {
+			if tx.Subscriber != addr {
+				t.Errorf("record of %s stored under %s", tx.Subscriber, addr)
+			}
+			got[addr] = append(got[addr], tx.Direction)
+		}
+	}
+	want := map[string][]eth_parser.Direction{
+		alice: {eth_parser.DirectionOutgoing, eth_parser.DirectionSelf},
+		bob:   {eth_parser.DirectionIncoming},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stored directions = %v, want %v", got, want)
+	}
+}
+
+func Test_EthereumParser_BloomPrefiltering(t *testing.T) {
+	subscriber := "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5"
+	counterparty := "0x0000000000000000000000000000000000000001"
+	token := "0xdac17f958d2ee523a2206206994597c13d831ec7"
+
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(subscriber)
+	storage.SetLastProcessedBlockNum(1)
+
+	bitsOf := func(hexItem string) eth_parser.BloomBits {
+		raw, _ := hex.DecodeString(hexItem[2:])
+		return eth_parser.BloomBitsOf(raw)
+	}
+	// Block 2 only holds a transfer between other addresses
+	var unrelated eth_parser.Bloom
+	for _, item := range []string{token, eth_parser.TransferEventTopic, eth_parser.AddressTopic(counterparty)} {
+		unrelated.Add(bitsOf(item))
+	}
+	// Block 3 holds a transfer to the subscriber
+	matching := unrelated
+	matching.Add(bitsOf(eth_parser.AddressTopic(subscriber)))
+
+	clientMock := NewClientMock()
+	clientMock.SetLatestBlockNumber(3)
+	clientMock.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{Number: "0x2", Hash: "0xb2", LogsBloom: unrelated.Hex()}})
+	clientMock.SetBlockByNumber(3, &eth_parser.Block{Result: eth_parser.BlockResult{Number: "0x3", Hash: "0xb3", LogsBloom: matching.Hex()}})
+	clientMock.LogsByBlockHash["0xb3"] = []eth_parser.Log{{
+		Address:          token,
+		Topics:           []string{eth_parser.TransferEventTopic, eth_parser.AddressTopic(counterparty), eth_parser.AddressTopic(subscriber)},
+		Data:             "0x00000000000000000000000000000000000000000000000000000000000f4240",
+		BlockNumber:      "0x3",
+		BlockHash:        "0xb3",
+		TransactionHash:  "0xt1",
+		TransactionIndex: "0x0",
+		LogIndex:         "0x4",
+	}}
+
+	ep := newTestParser(t, storage, clientMock)
+	ep.LogTracking = eth_parser.LogTracking{TokenTransfers: true}
+	ep.Poll()
+
+	if got, want := ep.BloomStats(), (eth_parser.BloomStats{BlocksChecked: 2, FetchesAvoided: 1}); got != want {
+		t.Errorf("BloomStats() = %+v, want %+v", got, want)
+	}
+	if len(clientMock.LogFilters) != 1 || clientMock.LogFilters[0].BlockHash != "0xb3" {
+		t.Errorf("logs fetched with %+v, want a single fetch for block 0xb3", clientMock.LogFilters)
+	}
+
+	txs := storage.GetTransactions(subscriber)
+	if len(txs) != 1 {
+		t.Fatalf("stored %d records, want 1", len(txs))
+	}
+	got := txs[0]
+	if got.Kind != eth_parser.KindTokenTransfer || got.From != counterparty || got.To != subscriber || got.Value != "0xf4240" || got.Direction != eth_parser.DirectionIncoming {
+		t.Errorf("token transfer record = %+v", got)
+	}
+	if got.Log == nil || got.Log.Address != token {
+		t.Errorf("token transfer record does not carry its log")
+	}
+}
+
+func Test_EthereumParser_LogsOnlyMode(t *testing.T) {
+	subscriber := "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5"
+	counterparty := "0x0000000000000000000000000000000000000001"
+	token := "0xdac17f958d2ee523a2206206994597c13d831ec7"
+
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(subscriber)
+	storage.SetLastProcessedBlockNum(1)
+
+	transfer := func(block uint64, from, to string) eth_parser.Log {
+		return eth_parser.Log{
+			Address:         token,
+			Topics:          []string{eth_parser.TransferEventTopic, eth_parser.AddressTopic(from), eth_parser.AddressTopic(to)},
+			Data:            "0x01",
+			BlockNumber:     fmt.Sprintf("0x%x", block),
+			BlockHash:       fmt.Sprintf("0xb%d", block),
+			TransactionHash: fmt.Sprintf("0xt%d", block),
+			LogIndex:        "0x0",
+		}
+	}
+
+	clientMock := NewClientMock()
+	clientMock.SetLatestBlockNumber(5)
+	// No full blocks are registered, the monitor would stop if it fetched them
+	clientMock.HeaderByNumber[3] = &eth_parser.Block{Result: eth_parser.BlockResult{Number: "0x3", Timestamp: "0x65a0"}}
+	clientMock.HeaderByNumber[5] = &eth_parser.Block{Result: eth_parser.BlockResult{Number: "0x5", Timestamp: "0x65b0"}}
+	clientMock.RangeLogs = []eth_parser.Log{
+		transfer(3, subscriber, counterparty),
+		transfer(5, counterparty, subscriber),
+	}
+
+	parser := newTestParser(t, storage, clientMock)
+	parser.Mode = eth_parser.ModeLogsOnly
+	parser.LogTracking = eth_parser.LogTracking{TokenTransfers: true}
+	parser.Poll()
+
+	if got := parser.GetCurrentBlock(); got != 5 {
+		t.Errorf("GetCurrentBlock() = %d, want 5", got)
+	}
+	if len(clientMock.LogFilters) == 0 || clientMock.LogFilters[0].FromBlock != 2 || clientMock.LogFilters[0].ToBlock != 5 {
+		t.Errorf("logs fetched with %+v, want the range 2 to 5", clientMock.LogFilters)
+	}
+
+	txs := storage.GetTransactions(subscriber)
+	var got []string
+	for _, tx := range txs {
+		got = append(got, fmt.Sprintf("%s %s %s", tx.Hash, tx.Direction, tx.Timestamp))
+	}
+	want := []string{"0xt3 outgoing 0x65a0", "0xt5 incoming 0x65b0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stored records = %v, want %v", got, want)
+	}
+}
+
+func Test_EthereumParser_LogsOnlyModeFilters(t *testing.T) {
+	storage := eth_parser.NewMemoryStorage()
+	for i := 1; i <= 150; i++ {
+		storage.Subscribe(testAddress(i))
+	}
+	storage.SetLastProcessedBlockNum(1)
+
+	clientMock := NewClientMock()
+	clientMock.SetLatestBlockNumber(5)
+
+	parser := newTestParser(t, storage, clientMock)
+	parser.Mode = eth_parser.ModeLogsOnly
+	parser.LogTracking = eth_parser.LogTracking{TokenTransfers: true, ContractEvents: true}
+	parser.Poll()
+
+	// Two chunks of addresses, each fetched for outgoing and incoming
+	// transfers and for contract events
+	if len(clientMock.LogFilters) != 6 {
+		t.Fatalf("logs fetched with %d filters, want 6", len(clientMock.LogFilters))
+	}
+	for i, filter := range clientMock.LogFilters {
+		got, want := 0, 100
+		if i >= 3 {
+			want = 50
+		}
+		switch i % 3 {
+		case 0, 1:
+			got = len(filter.Topics[len(filter.Topics)-1])
+		case 2:
+			got = len(filter.Addresses)
+			if filter.Topics != nil {
+				t.Errorf("filter %d topics = %v, want none without EventTopics", i, filter.Topics)
+			}
+		}
+		if got != want {
+			t.Errorf("filter %d holds %d addresses, want %d", i, got, want)
+		}
+	}
+}
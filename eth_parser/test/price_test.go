@@ -0,0 +1,240 @@
+package test
+
+import (
+	"eth-tx-parser/eth_parser"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const prices = `timestamp,currency,price
+2024-01-01,USD,2300
+2024-01-01,eur,2100.5
+1704153600,USD,2400.25
+2024-01-03T12:00:00Z,USD,2500
+`
+
+func loadPrices(t *testing.T) *eth_parser.CSVPriceSource {
+	t.Helper()
+	source, err := eth_parser.LoadPrices(strings.NewReader(prices))
+	if err != nil {
+		t.Fatalf("LoadPrices() error = %v", err)
+	}
+	return source
+}
+
+func Test_CSVPriceSource(t *testing.T) {
+	tt := []struct {
+		name     string
+		currency string
+		at       string
+		want     string
+		wantErr  bool
+	}{
+		{name: "Exact time", currency: "USD", at: "2024-01-01T00:00:00Z", want: "2300.00"},
+		{name: "Latest record before", currency: "USD", at: "2024-01-02T23:59:59Z", want: "2400.25"},
+		{name: "After the last record", currency: "USD", at: "2024-02-01T00:00:00Z", want: "2500.00"},
+		{name: "Lowercase currency", currency: "eur", at: "2024-01-05T00:00:00Z", want: "2100.50"},
+		{name: "Before the first record", currency: "USD", at: "2023-12-31T23:59:59Z", wantErr: true},
+		{name: "Unknown currency", currency: "GBP", at: "2024-01-02T00:00:00Z", wantErr: true},
+	}
+
+	source := loadPrices(t)
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			at, _ := time.Parse(time.RFC3339, tc.at)
+			price, err := source.Price(tc.currency, at)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Price() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && price.FloatString(2) != tc.want {
+				t.Errorf("Price() = %s, want %s", price.FloatString(2), tc.want)
+			}
+		})
+	}
+}
+
+func Test_LoadPricesInvalid(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+	}{
+		{name: "Invalid timestamp", input: "yesterday,USD,2300\n"},
+		{name: "Invalid price", input: "2024-01-01,USD,much\n"},
+		{name: "Negative price", input: "2024-01-01,USD,-1\n"},
+		{name: "Missing currency", input: "2024-01-01,,2300\n"},
+		{name: "Too many fields", input: "2024-01-01,USD,2300,extra\n"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := eth_parser.LoadPrices(strings.NewReader(tc.input)); err == nil {
+				t.Error("LoadPrices() expected an error")
+			}
+		})
+	}
+}
+
+func Test_HTTPPriceSource(t *testing.T) {
+	server := httptest.NewServer(eth_parser.NewPriceHandler(loadPrices(t)))
+	defer server.Close()
+	source := eth_parser.NewHTTPPriceSource(server.URL)
+
+	price, err := source.Price("usd", time.Date(2024, 1, 2, 6, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Price() error = %v", err)
+	}
+	if price.FloatString(2) != "2400.25" {
+		t.Errorf("Price() = %s, want 2400.25", price.FloatString(2))
+	}
+
+	if _, err := source.Price("GBP", time.Date(2024, 1, 2, 6, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("Price() of an unknown currency expected an error")
+	}
+}
+
+type countingPriceSource struct {
+	calls []time.Time
+}
+
+func (s *countingPriceSource) Price(currency string, at time.Time) (*big.Rat, error) {
+	s.calls = append(s.calls, at)
+	return big.NewRat(at.Unix(), 1), nil
+}
+
+func Test_CachedPriceSource(t *testing.T) {
+	source := &countingPriceSource{}
+	cached := eth_parser.NewCachedPriceSource(source, time.Hour)
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{start.Add(5 * time.Minute), start.Add(59 * time.Minute), start.Add(61 * time.Minute)} {
+		price, err := cached.Price("USD", at)
+		if err != nil {
+			t.Fatalf("Price() error = %v", err)
+		}
+		if want := at.Truncate(time.Hour).Unix(); price.Cmp(big.NewRat(want, 1)) != 0 {
+			t.Errorf("Price() at %s = %s, want the price at the start of the hour", at, price)
+		}
+	}
+	cached.Price("eur", start)
+	cached.Price("EUR", start)
+
+	want := []time.Time{start, start.Add(time.Hour), start}
+	if diff := cmp.Diff(want, source.calls); diff != "" {
+		t.Errorf("queried prices mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_FiatValue(t *testing.T) {
+	tt := []struct {
+		wei   string
+		price string
+		want  string
+	}{
+		{wei: "1000000000000000000", price: "2500", want: "2500.00"},
+		{wei: "1500000000000000000", price: "2400.25", want: "3600.38"},
+		{wei: "1", price: "2500", want: "0.00"},
+		{wei: "0", price: "2500", want: "0.00"},
+	}
+
+	for _, tc := range tt {
+		wei, _ := new(big.Int).SetString(tc.wei, 10)
+		price, _ := new(big.Rat).SetString(tc.price)
+		if got := eth_parser.FiatValue(wei, price); got != tc.want {
+			t.Errorf("FiatValue(%s, %s) = %s, want %s", tc.wei, tc.price, got, tc.want)
+		}
+	}
+}
+
+func Test_EthereumParser_FiatValuation(t *testing.T) {
+	address := testAddress(1)
+	storage := eth_parser.NewMemoryStorage()
+	storage.AddSubscription(eth_parser.Subscription{Address: address})
+	storage.SetLastProcessedBlockNum(1)
+	client := NewClientMock()
+	client.SetLatestBlockNumber(2)
+	client.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{
+		Number:    "0x2",
+		Timestamp: "0x65944f20", // 2024-01-02T18:00:00Z
+		Transactions: []eth_parser.Transaction{
+			{Hash: testHash(1), BlockNumber: "0x2", From: address, To: testAddress(2), Value: "0xde0b6b3a7640000"},
+		},
+	}})
+	parser := newTestParser(t, storage, client)
+	parser.Prices = eth_parser.NewCachedPriceSource(loadPrices(t), time.Hour)
+	parser.FiatCurrencies = []string{"USD", "EUR", "GBP"} // No GBP prices, left out
+	parser.Poll()
+
+	stored := storage.GetTransactions(address)
+	if len(stored) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(stored))
+	}
+	want := eth_parser.FiatValues{"USD": "2400.25", "EUR": "2100.50"}
+	if diff := cmp.Diff(want, stored[0].Fiat); diff != "" {
+		t.Errorf("Fiat mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_EthereumParser_EnrichesNativeTransfersOnly(t *testing.T) {
+	address := testAddress(1)
+	token := testAddress(3)
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(address)
+	storage.SetLastProcessedBlockNum(1)
+	// The transaction sends ETH to a token contract, which emits a transfer
+	// of its own tokens to the subscriber
+	client := NewClientMock()
+	client.SetLatestBlockNumber(2)
+	client.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{
+		Number:    "0x2",
+		Hash:      "0xb2",
+		Timestamp: "0x65944f20", // 2024-01-02T18:00:00Z
+		Transactions: []eth_parser.Transaction{
+			{Hash: testHash(1), BlockNumber: "0x2", From: address, To: token, Value: "0xde0b6b3a7640000"},
+		},
+	}})
+	client.LogsByBlockHash["0xb2"] = []eth_parser.Log{{
+		Address:         token,
+		Topics:          []string{eth_parser.TransferEventTopic, eth_parser.AddressTopic(token), eth_parser.AddressTopic(address)},
+		Data:            "0x00000000000000000000000000000000000000000000000000000000000f4240",
+		BlockNumber:     "0x2",
+		BlockHash:       "0xb2",
+		TransactionHash: testHash(1),
+		LogIndex:        "0x0",
+	}}
+	client.Receipts[testHash(1)] = &eth_parser.Receipt{Status: "0x1", GasUsed: "0x5208", EffectiveGasPrice: "0x1"}
+	parser := newTestParser(t, storage, client)
+	parser.LogTracking = eth_parser.LogTracking{TokenTransfers: true}
+	parser.Prices = eth_parser.NewCachedPriceSource(loadPrices(t), time.Hour)
+	parser.FiatCurrencies = []string{"USD"}
+	parser.VerifySenders = true
+	parser.TrackBalances = true
+
+	if !parser.Poll() {
+		t.Fatal("Poll() = false, want true")
+	}
+
+	stored := storage.GetTransactions(address)
+	if len(stored) != 2 {
+		t.Fatalf("expected the transfer and the token transfer, got %+v", stored)
+	}
+	for _, tx := range stored {
+		switch tx.EventKind() {
+		case eth_parser.KindNativeTransfer:
+			if tx.Fiat["USD"] != "2400.25" || tx.Receipt == nil || tx.SenderCheck == "" {
+				t.Errorf("expected the transfer to be valued, with its receipt and sender check, got %+v", tx)
+			}
+		case eth_parser.KindTokenTransfer:
+			if tx.Fiat != nil || tx.Receipt != nil || tx.SenderCheck != "" {
+				t.Errorf("expected the token transfer to be left as is, got %+v", tx)
+			}
+		}
+	}
+	if stats := parser.SenderStats(); stats.Unverifiable != 1 {
+		t.Errorf("SenderStats() = %+v, want the transfer checked once", stats)
+	}
+}
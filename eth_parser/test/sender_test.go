@@ -0,0 +1,115 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"eth-tx-parser/eth_parser"
+	"os"
+	"testing"
+)
+
+const signer = "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f"
+
+// loadSignedTxs reads transactions of every type signed by the same key,
+// as returned by eth_getBlockByNumber plus their raw encoding.
+func loadSignedTxs(t *testing.T) []eth_parser.Transaction {
+	t.Helper()
+	file, err := os.Open("testdata/signed_txs.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var txs []eth_parser.Transaction
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var tx eth_parser.Transaction
+		if err := json.Unmarshal(scanner.Bytes(), &tx); err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+func Test_RecoverSender(t *testing.T) {
+	for _, tx := range loadSignedTxs(t) {
+		t.Run("type "+tx.Type+" v "+tx.V, func(t *testing.T) {
+			sender, err := eth_parser.RecoverSender(&tx)
+			if err != nil {
+				t.Fatalf("RecoverSender() error = %v", err)
+			}
+			if sender != signer {
+				t.Errorf("RecoverSender() = %s, want %s", sender, signer)
+			}
+
+			// Any change to the signed fields yields another sender
+			tx.Value += "1"
+			if sender, err := eth_parser.RecoverSender(&tx); err == nil && sender == signer {
+				t.Errorf("RecoverSender() of a tampered transaction = %s", sender)
+			}
+		})
+	}
+}
+
+func Test_RecoverSenderInvalid(t *testing.T) {
+	tt := []struct {
+		name   string
+		modify func(tx *eth_parser.Transaction)
+	}{
+		{name: "Unsupported type", modify: func(tx *eth_parser.Transaction) { tx.Type = "0x7e" }},
+		{name: "Invalid v", modify: func(tx *eth_parser.Transaction) { tx.V = "0x1d" }},
+		{name: "Zero r", modify: func(tx *eth_parser.Transaction) { tx.R = "0x0" }},
+		{name: "Malformed s", modify: func(tx *eth_parser.Transaction) { tx.S = "0xzz" }},
+		{name: "Malformed input", modify: func(tx *eth_parser.Transaction) { tx.Input = "0x123" }},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tx := loadSignedTxs(t)[0]
+			tc.modify(&tx)
+			if sender, err := eth_parser.RecoverSender(&tx); err == nil {
+				t.Errorf("RecoverSender() = %s, want an error", sender)
+			}
+		})
+	}
+}
+
+func Test_EthereumParser_VerifySenders(t *testing.T) {
+	txs := loadSignedTxs(t)
+	forged := txs[3]
+	forged.S = txs[2].S // The node reports a sender that didn't sign the transaction
+	unsupported := txs[0]
+	unsupported.Type = "0x7e"
+	for i, tx := range []*eth_parser.Transaction{&txs[0], &forged, &unsupported} {
+		tx.BlockNumber = "0x2"
+		tx.TransactionIndex = []string{"0x0", "0x1", "0x2"}[i]
+	}
+	unsupported.Hash = testHash(1)
+
+	storage := eth_parser.NewMemoryStorage()
+	storage.AddSubscription(eth_parser.Subscription{Address: signer, StartBlock: 1})
+	storage.SetLastProcessedBlockNum(1)
+	client := NewClientMock()
+	client.SetLatestBlockNumber(2)
+	client.SetBlockByNumber(2, &eth_parser.Block{Result: eth_parser.BlockResult{
+		Number:       "0x2",
+		Transactions: []eth_parser.Transaction{txs[0], forged, unsupported},
+	}})
+	parser := newTestParser(t, storage, client)
+	parser.VerifySenders = true
+	parser.Poll()
+
+	stored := storage.GetTransactions(signer)
+	if len(stored) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(stored))
+	}
+	for i, want := range []eth_parser.SenderCheck{eth_parser.SenderVerified, eth_parser.SenderMismatch, eth_parser.SenderUnverifiable} {
+		if stored[i].SenderCheck != want {
+			t.Errorf("SenderCheck of transaction %d = %q, want %q", i, stored[i].SenderCheck, want)
+		}
+	}
+	if stats := parser.SenderStats(); stats != (eth_parser.SenderStats{Verified: 1, Mismatches: 1, Unverifiable: 1}) {
+		t.Errorf("SenderStats() = %+v", stats)
+	}
+}
@@ -0,0 +1,288 @@
+package test
+
+import (
+	"eth-tx-parser/eth_parser"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const queryAddress = "0xabc123"
+
+// setupQueryStorage stores ten transactions, one per block from 1 to 10, one
+// minute apart and alternating between outgoing and incoming.
+func setupQueryStorage() (eth_parser.Storage, []eth_parser.Transaction) {
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(queryAddress)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var txs []eth_parser.Transaction
+	for i := 1; i <= 10; i++ {
+		tx := eth_parser.Transaction{
+			Subscriber:       queryAddress,
+			Hash:             fmt.Sprintf("hash%d", i),
+			BlockNumber:      fmt.Sprintf("0x%x", i),
+			TransactionIndex: "0x0",
+			Timestamp:        fmt.Sprintf("0x%x", base.Add(time.Duration(i)*time.Minute).Unix()),
+			Value:            fmt.Sprintf("0x%x", i*1000),
+			From:             queryAddress,
+			To:               "0xdef456",
+		}
+		if i%2 == 0 {
+			tx.From, tx.To = tx.To, tx.From
+		}
+		txs = append(txs, tx)
+	}
+	// Insert out of order to check that storage keeps them sorted
+	for _, i := range []int{0, 1, 2, 4, 3, 5, 6, 9, 7, 8} {
+		storage.AddTransaction(queryAddress, txs[i])
+	}
+	return storage, txs
+}
+
+func hashes(txs []eth_parser.Transaction) []string {
+	out := []string{}
+	for _, tx := range txs {
+		out = append(out, tx.Hash)
+	}
+	return out
+}
+
+func Test_MemoryStorage_QueryTransactions(t *testing.T) {
+	storage, _ := setupQueryStorage()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name  string
+		query eth_parser.TxQuery
+		want  []string
+	}{
+		{
+			name:  "No filters",
+			query: eth_parser.TxQuery{},
+			want:  []string{"hash1", "hash2", "hash3", "hash4", "hash5", "hash6", "hash7", "hash8", "hash9", "hash10"},
+		},
+		{
+			name:  "Block range",
+			query: eth_parser.TxQuery{FromBlock: 3, ToBlock: 5},
+			want:  []string{"hash3", "hash4", "hash5"},
+		},
+		{
+			name:  "Time range",
+			query: eth_parser.TxQuery{FromTime: base.Add(8 * time.Minute), ToTime: base.Add(time.Hour)},
+			want:  []string{"hash8", "hash9", "hash10"},
+		},
+		{
+			name:  "Incoming only",
+			query: eth_parser.TxQuery{Direction: eth_parser.DirectionIncoming, ToBlock: 6},
+			want:  []string{"hash2", "hash4", "hash6"},
+		},
+		{
+			name:  "Minimum value, descending",
+			query: eth_parser.TxQuery{MinValue: big.NewInt(7000), Order: eth_parser.OrderDescending},
+			want:  []string{"hash10", "hash9", "hash8", "hash7"},
+		},
+		{
+			name:  "Nothing matches",
+			query: eth_parser.TxQuery{FromBlock: 11},
+			want:  []string{},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.query.Address = queryAddress
+			page, err := storage.QueryTransactions(tc.query)
+			if err != nil {
+				t.Fatalf("QueryTransactions() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, hashes(page.Transactions)); diff != "" {
+				t.Errorf("QueryTransactions() mismatch (-want +got):\n%s", diff)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("NextCursor = %q, want none", page.NextCursor)
+			}
+		})
+	}
+}
+
+func Test_MemoryStorage_QueryTransactionsPagination(t *testing.T) {
+	for _, order := range []eth_parser.SortOrder{eth_parser.OrderAscending, eth_parser.OrderDescending} {
+		t.Run(string(order), func(t *testing.T) {
+			storage, txs := setupQueryStorage()
+
+			q := eth_parser.TxQuery{Address: queryAddress, Direction: eth_parser.DirectionOutgoing, Order: order, Limit: 2}
+			var got []string
+			pages := 0
+			for {
+				page, err := storage.QueryTransactions(q)
+				if err != nil {
+					t.Fatalf("QueryTransactions() error = %v", err)
+				}
+				pages++
+				got = append(got, hashes(page.Transactions)...)
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+
+				// New transactions don't shift the pages being walked
+				if pages == 1 {
+					tx := txs[0]
+					tx.Hash, tx.BlockNumber = "hash11", "0xb"
+					storage.AddTransaction(queryAddress, tx)
+				}
+			}
+
+			want := []string{"hash1", "hash3", "hash5", "hash7", "hash9", "hash11"}
+			if order == eth_parser.OrderDescending {
+				want = []string{"hash9", "hash7", "hash5", "hash3", "hash1"}
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("paginated results mismatch (-want +got):\n%s", diff)
+			}
+			if pages != 3 {
+				t.Errorf("walked %d pages, want 3", pages)
+			}
+		})
+	}
+}
+
+func Test_MemoryStorage_QueryTransactionsSharedPosition(t *testing.T) {
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(queryAddress)
+
+	// Pending records have no block yet, and the token transfer and contract
+	// event decoded from a log sit at the same position
+	for _, hash := range []string{"pending2", "pending1", "pending3"} {
+		storage.AddTransaction(queryAddress, eth_parser.Transaction{Subscriber: queryAddress, Hash: hash, Status: eth_parser.StatusPending})
+	}
+	log := &eth_parser.Log{BlockNumber: "0x2", LogIndex: "0x0"}
+	for _, kind := range []eth_parser.EventKind{eth_parser.KindTokenTransfer, eth_parser.KindContractEvent} {
+		storage.AddTransaction(queryAddress, eth_parser.Transaction{Subscriber: queryAddress, Hash: "logged", BlockNumber: "0x2", Kind: kind, Log: log})
+	}
+
+	for _, order := range []eth_parser.SortOrder{eth_parser.OrderAscending, eth_parser.OrderDescending} {
+		t.Run(string(order), func(t *testing.T) {
+			q := eth_parser.TxQuery{Address: queryAddress, Order: order, Limit: 1}
+			var got []string
+			for {
+				page, err := storage.QueryTransactions(q)
+				if err != nil {
+					t.Fatalf("QueryTransactions() error = %v", err)
+				}
+				for _, tx := range page.Transactions {
+					got = append(got, tx.Hash+" "+string(tx.EventKind()))
+				}
+				if q.Cursor = page.NextCursor; q.Cursor == "" || len(got) > 5 {
+					break
+				}
+			}
+
+			want := []string{"pending1 transfer", "pending2 transfer", "pending3 transfer", "logged contract_event", "logged token_transfer"}
+			if order == eth_parser.OrderDescending {
+				want = []string{"logged token_transfer", "logged contract_event", "pending3 transfer", "pending2 transfer", "pending1 transfer"}
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("paginated results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_MemoryStorage_QueryTransactionsInvalid(t *testing.T) {
+	storage, _ := setupQueryStorage()
+
+	for _, q := range []eth_parser.TxQuery{
+		{Cursor: "not a cursor"},
+		{Order: "sideways"},
+		{FromBlock: 5, ToBlock: 2},
+		{Limit: -1},
+	} {
+		q.Address = queryAddress
+		if _, err := storage.QueryTransactions(q); err == nil {
+			t.Errorf("QueryTransactions(%+v) error = nil, want an error", q)
+		}
+	}
+}
+
+func Test_MemoryStorage_AddTransactionIdempotent(t *testing.T) {
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(queryAddress)
+
+	tx := eth_parser.Transaction{Subscriber: queryAddress, Hash: "hash1", BlockNumber: "0x1"}
+	for i := 0; i < 3; i++ {
+		if !storage.AddTransaction(queryAddress, tx) {
+			t.Fatalf("AddTransaction() = false, want true")
+		}
+	}
+	// A different kind of event from the same transaction is a separate record
+	tx.Kind = "token_transfer"
+	storage.AddTransaction(queryAddress, tx)
+
+	if got := len(storage.GetTransactions(queryAddress)); got != 2 {
+		t.Errorf("stored %d records, want 2", got)
+	}
+}
+
+func Test_MemoryStorage_UpsertTransaction(t *testing.T) {
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(queryAddress)
+	storage.AddTransaction(queryAddress, eth_parser.Transaction{Hash: "hash0", BlockNumber: "0x2"})
+
+	tx := eth_parser.Transaction{Subscriber: queryAddress, Hash: "hash1", BlockNumber: "0x1", Status: "pending"}
+	if created, ok := storage.UpsertTransaction(queryAddress, tx); !created || !ok {
+		t.Fatalf("first UpsertTransaction() = %v, %v, want true, true", created, ok)
+	}
+
+	// Same block, updated fields
+	tx.Status = eth_parser.StatusMined
+	if created, ok := storage.UpsertTransaction(queryAddress, tx); created || !ok {
+		t.Fatalf("second UpsertTransaction() = %v, %v, want false, true", created, ok)
+	}
+	if got := storage.GetTransactions(queryAddress)[0].Status; got != eth_parser.StatusMined {
+		t.Errorf("Status = %q, want %q", got, eth_parser.StatusMined)
+	}
+
+	// Moving to another block keeps the records ordered
+	tx.BlockNumber = "0x3"
+	storage.UpsertTransaction(queryAddress, tx)
+	if diff := cmp.Diff([]string{"hash0", "hash1"}, hashes(storage.GetTransactions(queryAddress))); diff != "" {
+		t.Errorf("records mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, ok := storage.UpsertTransaction("0xunknown", tx); ok {
+		t.Errorf("UpsertTransaction() for a non subscribed address succeeded")
+	}
+}
+
+func Test_MemoryStorage_GetTransactionsWhileUpserting(t *testing.T) {
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(queryAddress)
+	for i := 1; i <= 10; i++ {
+		storage.AddTransaction(queryAddress, eth_parser.Transaction{Hash: fmt.Sprintf("hash%d", i), BlockNumber: fmt.Sprintf("0x%x", i)})
+	}
+	txs := storage.GetTransactions(queryAddress)
+
+	// Moving records between blocks shifts the stored slice, which must not
+	// show through the slices returned before
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			storage.UpsertTransaction(queryAddress, eth_parser.Transaction{Hash: "hash1", BlockNumber: fmt.Sprintf("0x%x", 1+i%20)})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		storage.GetTransactions(queryAddress)
+	}
+	<-done
+
+	want := []string{"hash1", "hash2", "hash3", "hash4", "hash5", "hash6", "hash7", "hash8", "hash9", "hash10"}
+	if diff := cmp.Diff(want, hashes(txs)); diff != "" {
+		t.Errorf("records returned before the upserts changed (-want +got):\n%s", diff)
+	}
+}
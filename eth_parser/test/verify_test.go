@@ -0,0 +1,164 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"eth-tx-parser/eth_parser"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// loadBlocks reads blocks as returned by eth_getBlockByNumber: a Prague block
+// holding the signed transactions and a frontier block without transactions.
+func loadBlocks(t *testing.T) []eth_parser.BlockResult {
+	t.Helper()
+	file, err := os.Open("testdata/blocks.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var blocks []eth_parser.BlockResult
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var block eth_parser.BlockResult
+		if err := json.Unmarshal(scanner.Bytes(), &block); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func Test_VerifyBlock(t *testing.T) {
+	for _, block := range loadBlocks(t) {
+		if err := eth_parser.VerifyBlock(&block); err != nil {
+			t.Errorf("VerifyBlock() of block %s error = %v", block.Number, err)
+		}
+	}
+}
+
+func Test_VerifyBlock_UnsupportedTransactionType(t *testing.T) {
+	// Such as an OP Stack deposit, which leaves the transactionsRoot unchecked
+	block := loadBlocks(t)[0]
+	block.Transactions[0].Type = "0x7e"
+	if err := eth_parser.VerifyBlock(&block); err != nil {
+		t.Errorf("VerifyBlock() error = %v", err)
+	}
+}
+
+func Test_VerifyBlockTampered(t *testing.T) {
+	tt := []struct {
+		name   string
+		modify func(block *eth_parser.BlockResult)
+	}{
+		{name: "Transaction value", modify: func(b *eth_parser.BlockResult) { b.Transactions[2].Value += "1" }},
+		{name: "Transaction order", modify: func(b *eth_parser.BlockResult) {
+			b.Transactions[0], b.Transactions[1] = b.Transactions[1], b.Transactions[0]
+		}},
+		{name: "Missing transaction", modify: func(b *eth_parser.BlockResult) { b.Transactions = b.Transactions[1:] }},
+		{name: "Header field", modify: func(b *eth_parser.BlockResult) { b.GasUsed = "0x1" }},
+		{name: "Missing fork field", modify: func(b *eth_parser.BlockResult) { b.RequestsHash = "" }},
+		{name: "Transaction next to an unsupported type", modify: func(b *eth_parser.BlockResult) {
+			b.Transactions[0].Type = "0x7e"
+			b.Transactions[2].Value += "1"
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			block := loadBlocks(t)[0]
+			tc.modify(&block)
+			if err := eth_parser.VerifyBlock(&block); err == nil {
+				t.Error("VerifyBlock() expected an error")
+			}
+		})
+	}
+}
+
+func Test_TransactionsRoot(t *testing.T) {
+	// Roots computed by go-ethereum for the first n legacy transactions
+	roots := map[int]string{
+		0:   "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+		1:   "0x954aaa24c9286f79966519fed8ebdd27120501a91e9450c2e0886acd195ff521",
+		2:   "0x9bee27503be57cb52597e41315cd4af773e12c588b02e125102005d7e01ef1c7",
+		16:  "0xa6ae134a5d7263ec7743712b9573fe78488d7dc8c7b32f04c385599a8755d0e5",
+		17:  "0x5320c942b80df282ef5135aaa6caed52d556c97410fed73e1749a01a0d0d4720",
+		130: "0x854360f04f4f922834b6241b0b7209ed5e5c8e3799e00904cc14ef8e3948eca1",
+	}
+	txs := make([]eth_parser.Transaction, 130)
+	for i := range txs {
+		txs[i] = eth_parser.Transaction{
+			Nonce: fmt.Sprintf("0x%x", i), GasPrice: "0x1", Gas: "0x5208", To: "0x" + strings.Repeat("35", 20),
+			Value: fmt.Sprintf("0x%x", i), Input: "0x", V: "0x1b", R: "0x1", S: "0x2",
+		}
+	}
+
+	for n, want := range roots {
+		root, err := eth_parser.TransactionsRoot(txs[:n])
+		if err != nil {
+			t.Fatalf("TransactionsRoot() of %d transactions error = %v", n, err)
+		}
+		if root != want {
+			t.Errorf("TransactionsRoot() of %d transactions = %s, want %s", n, root, want)
+		}
+	}
+}
+
+func Test_EthereumParser_VerifyBlocks(t *testing.T) {
+	tt := []struct {
+		name        string
+		tamper      bool
+		wantTxs     int
+		wantLastNum uint64
+		wantStopped bool
+	}{
+		{name: "Valid block", wantTxs: 6, wantLastNum: 2},
+		{name: "Tampered block", tamper: true, wantLastNum: 1, wantStopped: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			block := loadBlocks(t)[0]
+			if tc.tamper {
+				block.Transactions[4].Value = "0x1" // The node lies about a transferred value
+			}
+
+			storage := eth_parser.NewMemoryStorage()
+			storage.AddSubscription(eth_parser.Subscription{Address: signer, StartBlock: 1})
+			storage.SetLastProcessedBlockNum(1)
+			client := NewClientMock()
+			client.SetLatestBlockNumber(2)
+			client.SetBlockByNumber(2, &eth_parser.Block{Result: block})
+			parser := newTestParser(t, storage, client)
+			parser.VerifyBlocks = true
+
+			// A block that never verifies is retried for a while, then the
+			// monitor stops rather than retrying it forever
+			polls, running := 0, true
+			for running && polls < 100 && storage.GetLastProcessedBlockNum() != 2 {
+				running = parser.Poll()
+				polls++
+			}
+			if running == tc.wantStopped {
+				t.Errorf("Poll() = %v after %d polls, want %v", running, polls, !tc.wantStopped)
+			}
+			if got := len(storage.GetTransactions(signer)); got != tc.wantTxs {
+				t.Errorf("expected %d transactions, got %d", tc.wantTxs, got)
+			}
+			if got := storage.GetLastProcessedBlockNum(); got != tc.wantLastNum {
+				t.Errorf("GetLastProcessedBlockNum() = %d, want %d", got, tc.wantLastNum)
+			}
+			stats := parser.IntegrityStats()
+			if tc.tamper && (stats.BlocksRejected != uint64(polls) || stats.BlocksRefetched != 2*stats.BlocksRejected) {
+				t.Errorf("expected the block to be fetched again before each rejection, IntegrityStats() = %+v", stats)
+			}
+			if !tc.tamper && stats.BlocksRejected > 0 {
+				t.Errorf("IntegrityStats() = %+v", stats)
+			}
+		})
+	}
+}
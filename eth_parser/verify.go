@@ -0,0 +1,225 @@
+package eth_parser
+
+import (
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
+// emptyTrieRoot is the root of a trie without entries, keccak256(rlp("")).
+const emptyTrieRoot = "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421"
+
+// VerifyBlock checks that the block hash matches the header fields, that
+// the transactions add up to the transactionsRoot of the header and that
+// each of them hashes to its reported hash. Together they prove the block
+// data wasn't altered, provided the hash itself is trusted.
+//
+// Transactions of a type it can't encode, such as the deposits of OP Stack
+// chains, are left unchecked, and so is the transactionsRoot of their block.
+func VerifyBlock(block *BlockResult) error {
+	hash, err := HeaderHash(block)
+	if err != nil {
+		return err
+	}
+	if hash != block.Hash {
+		return errors.Errorf("header fields hash to %s, not to the block hash %s", hash, block.Hash)
+	}
+
+	encoded := make([][]byte, len(block.Transactions))
+	complete := true
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		if encoded[i], err = EncodeTransaction(tx); errors.Cause(err) == errUnsupportedTxType {
+			complete = false
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to encode transaction %s", tx.Hash)
+		}
+		if txHash := "0x" + hex.EncodeToString(Keccak256(encoded[i])); txHash != tx.Hash {
+			return errors.Errorf("transaction fields hash to %s, not to the transaction hash %s", txHash, tx.Hash)
+		}
+	}
+	if !complete {
+		return nil
+	}
+	if root := trieRoot(encoded); root != block.TransactionsRoot {
+		return errors.Errorf("transactions add up to the root %s, not to the transactionsRoot %s", root, block.TransactionsRoot)
+	}
+	return nil
+}
+
+// HeaderHash computes the block hash from the header fields. Fields added
+// by later forks are included as long as the node reports them.
+func HeaderHash(block *BlockResult) (string, error) {
+	var e rlpEncoder
+	fields := [][]byte{
+		e.bytes(block.ParentHash), e.bytes(block.SHA3Uncles), e.bytes(block.Miner), e.bytes(block.StateRoot),
+		e.bytes(block.TransactionsRoot), e.bytes(block.ReceiptsRoot), e.bytes(block.LogsBloom),
+		e.quantity(block.Difficulty), e.quantity(block.Number), e.quantity(block.GasLimit), e.quantity(block.GasUsed),
+		e.quantity(block.Timestamp), e.bytes(block.ExtraData), e.bytes(block.MixHash), e.bytes(block.Nonce),
+	}
+	forkFields := []struct {
+		value  string
+		encode func(string) []byte
+	}{
+		{block.BaseFeePerGas, e.quantity},      // London
+		{block.WithdrawalsRoot, e.bytes},       // Shanghai
+		{block.BlobGasUsed, e.quantity},        // Cancun
+		{block.ExcessBlobGas, e.quantity},      // Cancun
+		{block.ParentBeaconBlockRoot, e.bytes}, // Cancun
+		{block.RequestsHash, e.bytes},          // Prague
+	}
+	for _, f := range forkFields {
+		if f.value == "" {
+			break
+		}
+		fields = append(fields, f.encode(f.value))
+	}
+	if e.err != nil {
+		return "", errors.Wrap(e.err, "failed to encode header")
+	}
+	return "0x" + hex.EncodeToString(Keccak256(rlpList(fields...))), nil
+}
+
+// EncodeTransaction returns the canonical encoding of a signed transaction,
+// the one its hash and the transactions trie are computed from.
+func EncodeTransaction(tx *Transaction) ([]byte, error) {
+	var e rlpEncoder
+	txType, fields, err := txFields(tx, &e)
+	if err != nil {
+		return nil, err
+	}
+	if txType == txTypeLegacy {
+		fields = append(fields, e.quantity(tx.V), e.quantity(tx.R), e.quantity(tx.S))
+		return rlpList(fields...), e.err
+	}
+	parity := tx.YParity
+	if parity == "" {
+		parity = tx.V
+	}
+	fields = append(fields, e.quantity(parity), e.quantity(tx.R), e.quantity(tx.S))
+	return append([]byte{byte(txType)}, rlpList(fields...)...), e.err
+}
+
+// TransactionsRoot computes the root of the Merkle-Patricia trie of the
+// transactions, keyed by their index in the block.
+func TransactionsRoot(txs []Transaction) (string, error) {
+	encoded := make([][]byte, len(txs))
+	for i := range txs {
+		var err error
+		if encoded[i], err = EncodeTransaction(&txs[i]); err != nil {
+			return "", errors.Wrapf(err, "failed to encode transaction %s", txs[i].Hash)
+		}
+	}
+	return trieRoot(encoded), nil
+}
+
+type trieEntry struct {
+	key   []byte // Nibbles
+	value []byte
+}
+
+// trieRoot builds the trie holding values under the RLP encoding of their
+// index and returns its root hash.
+func trieRoot(values [][]byte) string {
+	if len(values) == 0 {
+		return emptyTrieRoot
+	}
+	entries := make([]trieEntry, len(values))
+	for i, value := range values {
+		var index []byte
+		for n := i; n > 0; n >>= 8 {
+			index = append([]byte{byte(n)}, index...)
+		}
+		key := rlpString(index)
+		nibbles := make([]byte, 0, 2*len(key))
+		for _, b := range key {
+			nibbles = append(nibbles, b>>4, b&0x0f)
+		}
+		entries[i] = trieEntry{key: nibbles, value: value}
+	}
+	return "0x" + hex.EncodeToString(Keccak256(trieNode(entries, 0)))
+}
+
+// trieNode returns the encoded node holding the entries, whose keys are
+// equal up to depth.
+func trieNode(entries []trieEntry, depth int) []byte {
+	if len(entries) == 1 {
+		return rlpList(hexPrefix(entries[0].key[depth:], true), rlpString(entries[0].value))
+	}
+
+	// Nibbles shared by every key become an extension node
+	shared := 0
+	for {
+		pos := depth + shared
+		if pos >= len(entries[0].key) {
+			break
+		}
+		same := true
+		for _, entry := range entries[1:] {
+			if pos >= len(entry.key) || entry.key[pos] != entries[0].key[pos] {
+				same = false
+				break
+			}
+		}
+		if !same {
+			break
+		}
+		shared++
+	}
+	if shared > 0 {
+		child := trieNode(entries, depth+shared)
+		return rlpList(hexPrefix(entries[0].key[depth:depth+shared], false), trieRef(child))
+	}
+
+	// Otherwise a branch node with a child per next nibble
+	var children [16][]trieEntry
+	var value []byte
+	for _, entry := range entries {
+		if len(entry.key) == depth {
+			value = entry.value
+			continue
+		}
+		nibble := entry.key[depth]
+		children[nibble] = append(children[nibble], entry)
+	}
+	items := make([][]byte, 17)
+	for i, child := range children {
+		if len(child) == 0 {
+			items[i] = rlpString(nil)
+		} else {
+			items[i] = trieRef(trieNode(child, depth+1))
+		}
+	}
+	items[16] = rlpString(value)
+	return rlpList(items...)
+}
+
+// trieRef references a child node, which is embedded when shorter than its
+// hash would be.
+func trieRef(node []byte) []byte {
+	if len(node) < 32 {
+		return node
+	}
+	return rlpString(Keccak256(node))
+}
+
+// hexPrefix packs the nibbles of a key, flagging leaves and odd lengths in
+// the first nibble.
+func hexPrefix(nibbles []byte, leaf bool) []byte {
+	var flag byte
+	if leaf {
+		flag = 2
+	}
+	var out []byte
+	if len(nibbles)%2 == 1 {
+		out = append(out, (flag+1)<<4|nibbles[0])
+		nibbles = nibbles[1:]
+	} else {
+		out = append(out, flag<<4)
+	}
+	for i := 0; i < len(nibbles); i += 2 {
+		out = append(out, nibbles[i]<<4|nibbles[i+1])
+	}
+	return rlpString(out)
+}
@@ -2,34 +2,304 @@ package main
 
 import (
 	"context"
+	"errors"
 	"eth-tx-parser/cli"
+	"eth-tx-parser/config"
 	"eth-tx-parser/eth_parser"
+	"eth-tx-parser/parser_rpc"
+	"flag"
 	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	
-	parser := eth_parser.NewEthereumParser(ctx, nil) // Passing nil so it uses the default storage
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err == nil {
+		err = cli.ValidateOutputFormat(cfg.OutputFormat, cfg.OutputColumns)
+	}
+	if err != nil {
+		log.Println(err)
+		os.Exit(cli.ExitUsage)
+	}
+	// The parser logs with the default logger, as does the log package from now on
+	slog.SetDefault(cfg.Logger(os.Stderr))
+	if cfg.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if len(cfg.Command) == 0 { // Kept out of the output of scripted commands
+		var effective strings.Builder
+		if err := cfg.Write(&effective); err != nil {
+			fatal(err)
+		}
+		slog.Info("effective configuration", "config", effective.String())
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	var parser eth_parser.Parser
+	var chains []eth_parser.ChainConfig
+	var alerts *eth_parser.AlertEngine // Rules are evaluated by the parser, so remote parsers have their own
+	if cfg.Remote != "" {
+		client, err := parser_rpc.Dial(ctx, cfg.Remote, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			fatal(err)
+		}
+		parser = client
+	} else {
+		prices, err := priceSource(cfg.PriceFile, cfg.PriceURL)
+		if err != nil {
+			fatal(err)
+		}
+		if chains, err = cfg.ChainConfigs(); err != nil {
+			fatal(err)
+		}
+		if len(cfg.Command) == 0 {
+			logChains(chains)
+		}
+		var metrics *eth_parser.Metrics
+		if cfg.MetricsAddr != "" {
+			metrics = eth_parser.NewMetrics()
+			if err := serveMetrics(cfg.MetricsAddr, metrics); err != nil {
+				fatal(err)
+			}
+		}
+		parsers, err := newParsers(ctx, cfg, chains, metrics)
+		if err != nil {
+			fatal(err)
+		}
+		if alerts, err = newAlertEngine(cfg); err != nil {
+			fatal(err)
+		}
+		for _, ep := range parsers {
+			if rpc, ok := ep.Client.(*eth_parser.EthereumRPCClient); ok {
+				rpc.MaxAttempts = cfg.RPCMaxAttempts
+				rpc.BackoffScale = cfg.RPCBackoffScale
+				rpc.HTTPClient.Timeout = cfg.RPCTimeout
+				rpc.Metrics = metrics
+			}
+			ep.Metrics = metrics
+			ep.LogTracking = eth_parser.LogTracking{
+				TokenTransfers: cfg.TrackTokens,
+				ContractEvents: cfg.TrackEvents,
+			}
+			if cfg.LogsOnly {
+				ep.Mode = eth_parser.ModeLogsOnly
+			}
+			ep.Alerts = alerts // Shared, so rules see the records of every chain
+			ep.VerifySenders = cfg.VerifySenders
+			ep.VerifyBlocks = cfg.VerifyBlocks
+			if cfg.TrackBalances {
+				ep.TrackBalances = true
+				ep.ReconcileEvery = cfg.ReconcileEvery
+			}
+			if prices != nil && ep.Chain.NativeSymbol == "ETH" { // Prices are of ETH
+				ep.Prices = prices
+				ep.FiatCurrencies = cfg.Fiat
+			}
+			if cfg.ABIDir != "" {
+				if _, err := ep.ABIs.LoadABIDir(cfg.ABIDir); err != nil {
+					fatal(err)
+				}
+			}
+			if cfg.WatchMempool {
+				ep.WatchMempool()
+			}
+			ep.Start() // Configured, its fields are no longer set
+		}
+		if len(parsers) == 1 {
+			parser = parsers[0]
+		} else if parser, err = eth_parser.NewMultiChainParser(parsers...); err != nil {
+			fatal(err)
+		}
+	}
+
+	if subs := cfg.ParserSubscriptions(chains); len(subs) > 0 {
+		if added := parser.SubscribeMany(subs); added < len(subs) {
+			slog.Info("some configured addresses were already subscribed", "subscribed", added, "configured", len(subs))
+		}
+	}
+
+	if cfg.GRPCAddr != "" {
+		if err := serveGRPC(cfg.GRPCAddr, parser); err != nil {
+			fatal(err)
+		}
+	}
+
 	cli := cli.NewCLI(ctx, parser)
+	cli.SetOutputFormat(cfg.OutputFormat, cfg.OutputColumns) // Validated with the config
+	cli.HistoryFile = cfg.HistoryFile
+	cli.Alerts = alerts
 
-	setupSignalHandling(cancel)
+	setupSignalHandling(cancel, len(cfg.Command) == 0)
 
+	if len(cfg.Command) > 0 {
+		os.Exit(cli.Exec(cfg.Command))
+	}
 	cli.Run()
 }
 
-func setupSignalHandling(cancelFunc context.CancelFunc) {
+func serveGRPC(addr string, parser eth_parser.Parser) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	gs := grpc.NewServer()
+	parser_rpc.NewServer(parser).Register(gs)
+
+	go func() {
+		if err := gs.Serve(lis); err != nil {
+			slog.Error("gRPC server stopped", "addr", addr, "error", err)
+		}
+	}()
+	return nil
+}
+
+// serveMetrics serves the metrics on /metrics, in the Prometheus text format.
+func serveMetrics(addr string, metrics *eth_parser.Metrics) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+
+	go func() {
+		if err := http.Serve(lis, mux); err != nil {
+			slog.Error("metrics server stopped", "addr", addr, "error", err)
+		}
+	}()
+	return nil
+}
+
+// Prices within this span are considered the same, so each is fetched once
+const priceBucket = time.Hour
+
+func priceSource(priceFile, priceURL string) (eth_parser.PriceSource, error) {
+	var source eth_parser.PriceSource
+	switch {
+	case priceFile != "" && priceURL != "":
+		return nil, fmt.Errorf("price_file and price_url can't be combined")
+	case priceFile != "":
+		prices, err := eth_parser.LoadPriceFile(priceFile)
+		if err != nil {
+			return nil, err
+		}
+		source = prices
+	case priceURL != "":
+		source = eth_parser.NewHTTPPriceSource(priceURL)
+	default:
+		return nil, nil
+	}
+	return eth_parser.NewCachedPriceSource(source, priceBucket), nil
+}
+
+// newAlertEngine returns an engine with the configured rules, delivering
+// alerts to the configured sinks.
+func newAlertEngine(cfg *config.Config) (*eth_parser.AlertEngine, error) {
+	rules, err := cfg.ParserRules()
+	if err != nil {
+		return nil, err
+	}
+	var sinks []eth_parser.AlertSink
+	for _, sink := range cfg.AlertSinks {
+		switch sink {
+		case "log":
+			sinks = append(sinks, eth_parser.LogSink{})
+		case "stdout":
+			sinks = append(sinks, eth_parser.NewWriterSink(os.Stdout))
+		default: // Validated as a URL with the config
+			sinks = append(sinks, eth_parser.NewWebhookSink(sink))
+		}
+	}
+	alerts := eth_parser.NewAlertEngine(sinks...)
+	alerts.DedupWindow = cfg.AlertDedupWindow
+	for _, rule := range rules {
+		if err := alerts.AddRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return alerts, nil
+}
+
+func logChains(chains []eth_parser.ChainConfig) {
+	for _, chain := range chains {
+		endpoints := make([]string, len(chain.RPCURLs))
+		for i, u := range chain.RPCURLs {
+			endpoints[i] = config.MaskURL(u)
+		}
+		slog.Info("following chain", "chain", chain.Name, "chain_id", chain.ChainID, "endpoints", strings.Join(endpoints, ","), "polling", chain.BlockTime, "confirmations", chain.Confirmations)
+	}
+}
+
+// newParsers returns a parser per chain, each with its own storage of the
+// configured backend, metered when metrics are collected.
+func newParsers(ctx context.Context, cfg *config.Config, chains []eth_parser.ChainConfig, metrics *eth_parser.Metrics) ([]*eth_parser.EthereumParser, error) {
+	var parsers []*eth_parser.EthereumParser
+	for _, chain := range chains {
+		storage := newStorage(cfg.Storage)
+		if metrics != nil {
+			storage = eth_parser.NewMeteredStorage(storage, metrics, chain.Name)
+		}
+		ep, err := eth_parser.NewChainParser(ctx, chain, storage)
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, ep)
+	}
+	return parsers, nil
+}
+
+// newStorage returns a storage of the backend, which the config validated.
+func newStorage(backend string) eth_parser.Storage {
+	switch backend {
+	case "memory":
+		return eth_parser.NewMemoryStorage()
+	}
+	return nil // The parser falls back to its default storage
+}
+
+// fatal prints err and exits, even in quiet mode.
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// setupSignalHandling cancels the context on SIGINT or SIGTERM, with the
+// signal as the cause. A command run by Exec then unwinds through it and
+// exits with the status shells report for the signal, as it does right away
+// when signalled again, or when the prompt, which may be waiting for a line,
+// is running.
+func setupSignalHandling(cancelFunc context.CancelCauseFunc, prompt bool) {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		<-sigs
-		fmt.Println("\nReceived termination signal, exiting...")
-		cancelFunc()
-		os.Exit(0)
+		sig := <-sigs
+		fmt.Fprintln(os.Stderr, "\nReceived termination signal, exiting...")
+		cancelFunc(cli.SignalError{Signal: sig})
+		if !prompt {
+			sig = <-sigs
+		}
+		os.Exit(128 + int(sig.(syscall.Signal)))
 	}()
 }
@@ -0,0 +1,270 @@
+package parser_rpc
+
+import (
+	"context"
+	"eth-tx-parser/eth_parser"
+	"eth-tx-parser/parser_rpc/pb"
+	"log/slog"
+	"math/big"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// Client implements eth_parser.Parser against a remote ParserService, so code
+// written for a local parser (e.g. cli.NewCLI) can run against a server.
+type Client struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	conn   *grpc.ClientConn
+	rpc    pb.ParserServiceClient
+
+	Logger *slog.Logger // slog.Default() when created, the failed calls are logged with it
+
+	tx_chan    chan eth_parser.Transaction
+	listenOnce sync.Once
+	feedOnce   sync.Once
+	closeOnce  sync.Once
+}
+
+// Dial creates a client of a ParserService listening on target, which is
+// connected to on the first call.
+func Dial(ctx context.Context, target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial parser service at %s", target)
+	}
+	return NewClient(ctx, conn), nil
+}
+
+// NewClient wraps an existing connection. The connection is closed by Stop.
+func NewClient(ctx context.Context, conn *grpc.ClientConn) *Client {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Client{
+		ctx:     ctx,
+		cancel:  cancel,
+		conn:    conn,
+		rpc:     pb.NewParserServiceClient(conn),
+		Logger:  slog.Default(),
+		tx_chan: make(chan eth_parser.Transaction),
+	}
+}
+
+func (c *Client) GetCurrentBlock() uint64 {
+	resp, err := c.rpc.GetCurrentBlock(c.ctx, &pb.GetCurrentBlockRequest{})
+	if err != nil {
+		c.Logger.Error("failed to get current block", "error", err)
+		return 0
+	}
+	return resp.GetBlockNumber()
+}
+
+func (c *Client) Subscribe(address string) bool {
+	return c.AddSubscription(eth_parser.Subscription{Address: address})
+}
+
+func (c *Client) AddSubscription(sub eth_parser.Subscription) bool {
+	resp, err := c.rpc.Subscribe(c.ctx, toProtoSubscribeRequest(sub))
+	if err != nil {
+		c.Logger.Error("failed to subscribe", "address", sub.Address, "error", err)
+		return false
+	}
+	return resp.GetSubscribed()
+}
+
+// Large watchlists and histories are sent in batches to stay below the gRPC
+// message size limit. Transactions are far bigger than subscriptions, their
+// input alone may take kilobytes.
+const (
+	subscribeBatchSize = 10000
+	importBatchSize    = 500
+)
+
+func (c *Client) SubscribeMany(subs []eth_parser.Subscription) int {
+	added := 0
+	for start := 0; start < len(subs); start += subscribeBatchSize {
+		req := &pb.SubscribeManyRequest{}
+		for _, sub := range subs[start:min(start+subscribeBatchSize, len(subs))] {
+			req.Subscriptions = append(req.Subscriptions, toProtoSubscribeRequest(sub))
+		}
+		resp, err := c.rpc.SubscribeMany(c.ctx, req)
+		if err != nil {
+			c.Logger.Error("failed to subscribe", "error", err)
+			return added
+		}
+		added += int(resp.GetAdded())
+	}
+	return added
+}
+
+func (c *Client) ImportTransactions(txs []eth_parser.Transaction) int {
+	imported := 0
+	for start := 0; start < len(txs); start += importBatchSize {
+		req := &pb.ImportTransactionsRequest{}
+		for _, tx := range txs[start:min(start+importBatchSize, len(txs))] {
+			req.Transactions = append(req.Transactions, toProtoTransaction(tx))
+		}
+		resp, err := c.rpc.ImportTransactions(c.ctx, req)
+		if err != nil {
+			c.Logger.Error("failed to import transactions", "error", err)
+			return imported
+		}
+		imported += int(resp.GetImported())
+	}
+	return imported
+}
+
+func (c *Client) Unsubscribe(address string, purge bool) bool {
+	resp, err := c.rpc.Unsubscribe(c.ctx, &pb.UnsubscribeRequest{Address: address, PurgeTransactions: purge})
+	if err != nil {
+		c.Logger.Error("failed to unsubscribe", "address", address, "error", err)
+		return false
+	}
+	return resp.GetUnsubscribed()
+}
+
+func (c *Client) ListSubscriptions() []eth_parser.Subscription {
+	resp, err := c.rpc.ListSubscriptions(c.ctx, &pb.ListSubscriptionsRequest{})
+	if err != nil {
+		c.Logger.Error("failed to list subscriptions", "error", err)
+		return nil
+	}
+	subs := make([]eth_parser.Subscription, 0, len(resp.GetSubscriptions()))
+	for _, sub := range resp.GetSubscriptions() {
+		subs = append(subs, fromProtoSubscription(sub))
+	}
+	return subs
+}
+
+// GetTransactions walks every page of the remote history.
+func (c *Client) GetTransactions(address string) []eth_parser.Transaction {
+	var txs []eth_parser.Transaction
+	q := eth_parser.TxQuery{Address: address}
+	for {
+		page, err := c.QueryTransactions(q)
+		if err != nil {
+			c.Logger.Error("failed to get transactions", "address", address, "error", err)
+			return txs
+		}
+		txs = append(txs, page.Transactions...)
+		if page.NextCursor == "" {
+			return txs
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func (c *Client) QueryTransactions(q eth_parser.TxQuery) (eth_parser.TxPage, error) {
+	resp, err := c.rpc.GetTransactions(c.ctx, toProtoQuery(q))
+	if err != nil {
+		return eth_parser.TxPage{}, errors.Wrap(err, "failed to query transactions")
+	}
+	page := eth_parser.TxPage{
+		Transactions: make([]eth_parser.Transaction, 0, len(resp.GetTransactions())),
+		NextCursor:   resp.GetNextPageToken(),
+	}
+	for _, tx := range resp.GetTransactions() {
+		page.Transactions = append(page.Transactions, fromProtoTransaction(tx))
+	}
+	return page, nil
+}
+
+func (c *Client) Balance(address string) (eth_parser.BalanceReport, error) {
+	resp, err := c.rpc.GetBalance(c.ctx, &pb.GetBalanceRequest{Address: address})
+	if err != nil {
+		return eth_parser.BalanceReport{}, errors.Wrap(err, "failed to get balance")
+	}
+	tracked, okTracked := new(big.Int).SetString(resp.GetTracked(), 10)
+	reported, okReported := new(big.Int).SetString(resp.GetReported(), 10)
+	if !okTracked || !okReported {
+		return eth_parser.BalanceReport{}, errors.Errorf("invalid balances %q and %q", resp.GetTracked(), resp.GetReported())
+	}
+	return eth_parser.BalanceReport{
+		Address:     resp.GetAddress(),
+		Block:       resp.GetBlock(),
+		AnchorBlock: resp.GetAnchorBlock(),
+		Tracked:     tracked,
+		Reported:    reported,
+		MissingFees: int(resp.GetMissingFees()),
+		Chain:       resp.GetChain(),
+	}, nil
+}
+
+func (c *Client) SetLabel(address, label string) bool {
+	resp, err := c.rpc.SetLabel(c.ctx, &pb.SetLabelRequest{Address: address, Label: label})
+	if err != nil {
+		c.Logger.Error("failed to set label", "address", address, "error", err)
+		return false
+	}
+	return resp.GetSet()
+}
+
+func (c *Client) RemoveLabel(address string) bool {
+	resp, err := c.rpc.RemoveLabel(c.ctx, &pb.RemoveLabelRequest{Address: address})
+	if err != nil {
+		c.Logger.Error("failed to remove label", "address", address, "error", err)
+		return false
+	}
+	return resp.GetRemoved()
+}
+
+func (c *Client) ListLabels() []eth_parser.AddressLabel {
+	resp, err := c.rpc.ListLabels(c.ctx, &pb.ListLabelsRequest{})
+	if err != nil {
+		c.Logger.Error("failed to list labels", "error", err)
+		return nil
+	}
+	labels := make([]eth_parser.AddressLabel, 0, len(resp.GetLabels()))
+	for _, l := range resp.GetLabels() {
+		labels = append(labels, eth_parser.AddressLabel{Address: l.GetAddress(), Label: l.GetLabel()})
+	}
+	return labels
+}
+
+// Listen opens a single unfiltered stream on first use and shares it with
+// every caller, matching the semantics of the local parser.
+func (c *Client) Listen() <-chan eth_parser.Transaction {
+	c.listenOnce.Do(func() {
+		stream, err := c.rpc.Listen(c.ctx, &pb.ListenRequest{})
+		if err != nil {
+			c.Logger.Error("failed to open live feed", "error", err)
+			c.closeFeed()
+			return
+		}
+		go c.receive(stream)
+	})
+	return c.tx_chan
+}
+
+func (c *Client) receive(stream pb.ParserService_ListenClient) {
+	defer c.closeFeed()
+	for {
+		tx, err := stream.Recv()
+		if err != nil {
+			if c.ctx.Err() == nil {
+				c.Logger.Error("live feed interrupted", "error", err)
+			}
+			return
+		}
+		select {
+		case c.tx_chan <- fromProtoTransaction(tx):
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// The feed is closed by its receiving goroutine, or right here when Listen
+// was never called.
+func (c *Client) closeFeed() {
+	c.feedOnce.Do(func() { close(c.tx_chan) })
+}
+
+func (c *Client) Stop() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.conn.Close()
+	})
+	c.listenOnce.Do(c.closeFeed)
+}
@@ -0,0 +1,275 @@
+package parser_rpc
+
+import (
+	"eth-tx-parser/eth_parser"
+	"eth-tx-parser/parser_rpc/pb"
+	"fmt"
+	"math/big"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toProtoSubscription(sub eth_parser.Subscription) *pb.Subscription {
+	return &pb.Subscription{
+		Address:    sub.Address,
+		Label:      sub.Label,
+		CreatedAt:  timestamppb.New(sub.CreatedAt),
+		StartBlock: sub.StartBlock,
+		Tags:       sub.Tags,
+		Chain:      sub.Chain,
+	}
+}
+
+func fromProtoSubscription(sub *pb.Subscription) eth_parser.Subscription {
+	return eth_parser.Subscription{
+		Address:    sub.GetAddress(),
+		Label:      sub.GetLabel(),
+		CreatedAt:  sub.GetCreatedAt().AsTime(),
+		StartBlock: sub.GetStartBlock(),
+		Tags:       sub.GetTags(),
+		Chain:      sub.GetChain(),
+	}
+}
+
+func toProtoSubscribeRequest(sub eth_parser.Subscription) *pb.SubscribeRequest {
+	return &pb.SubscribeRequest{
+		Address:    sub.Address,
+		Label:      sub.Label,
+		StartBlock: sub.StartBlock,
+		Tags:       sub.Tags,
+		Chain:      sub.Chain,
+	}
+}
+
+func fromProtoSubscribeRequest(req *pb.SubscribeRequest) eth_parser.Subscription {
+	return eth_parser.Subscription{
+		Address:    req.GetAddress(),
+		Label:      req.GetLabel(),
+		StartBlock: req.GetStartBlock(),
+		Tags:       req.GetTags(),
+		Chain:      req.GetChain(),
+	}
+}
+
+func toProtoTransaction(tx eth_parser.Transaction) *pb.Transaction {
+	ptx := &pb.Transaction{
+		Subscriber:           tx.Subscriber,
+		BlockHash:            tx.BlockHash,
+		BlockNumber:          tx.BlockNumber,
+		From:                 tx.From,
+		Gas:                  tx.Gas,
+		GasPrice:             tx.GasPrice,
+		Hash:                 tx.Hash,
+		Input:                tx.Input,
+		Nonce:                tx.Nonce,
+		To:                   tx.To,
+		TransactionIndex:     tx.TransactionIndex,
+		Value:                tx.Value,
+		V:                    tx.V,
+		R:                    tx.R,
+		S:                    tx.S,
+		Timestamp:            tx.Timestamp,
+		Kind:                 string(tx.Kind),
+		Status:               string(tx.Status),
+		Direction:            directions[tx.Direction],
+		SenderCheck:          string(tx.SenderCheck),
+		Type:                 tx.Type,
+		ChainId:              tx.ChainID,
+		MaxFeePerGas:         tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+		Fiat:                 tx.Fiat,
+		ReplacedBy:           tx.ReplacedBy,
+		Chain:                tx.Chain,
+		FromLabel:            tx.FromLabel,
+		ToLabel:              tx.ToLabel,
+		YParity:              tx.YParity,
+		MaxFeePerBlobGas:     tx.MaxFeePerBlobGas,
+		BlobVersionedHashes:  tx.BlobVersionedHashes,
+	}
+	if tx.Receipt != nil {
+		ptx.Receipt = &pb.Receipt{
+			Status:            tx.Receipt.Status,
+			GasUsed:           tx.Receipt.GasUsed,
+			EffectiveGasPrice: tx.Receipt.EffectiveGasPrice,
+			BlobGasUsed:       tx.Receipt.BlobGasUsed,
+			BlobGasPrice:      tx.Receipt.BlobGasPrice,
+		}
+	}
+	for _, tuple := range tx.AccessList {
+		ptx.AccessList = append(ptx.AccessList, &pb.AccessTuple{Address: tuple.Address, StorageKeys: tuple.StorageKeys})
+	}
+	for _, auth := range tx.AuthorizationList {
+		ptx.AuthorizationList = append(ptx.AuthorizationList, &pb.Authorization{
+			ChainId: auth.ChainID,
+			Address: auth.Address,
+			Nonce:   auth.Nonce,
+			YParity: auth.YParity,
+			R:       auth.R,
+			S:       auth.S,
+		})
+	}
+	if tx.Log != nil {
+		ptx.Log = &pb.Log{
+			Address:  tx.Log.Address,
+			Topics:   tx.Log.Topics,
+			Data:     tx.Log.Data,
+			LogIndex: tx.Log.LogIndex,
+		}
+	}
+	if tx.Call != nil {
+		ptx.Call = &pb.Call{Selector: tx.Call.Selector, Method: tx.Call.Method, Signature: tx.Call.Signature}
+		for _, arg := range tx.Call.Args {
+			ptx.Call.Args = append(ptx.Call.Args, &pb.CallArg{Name: arg.Name, Type: arg.Type, Value: arg.Value})
+		}
+	}
+	return ptx
+}
+
+func fromProtoTransaction(tx *pb.Transaction) eth_parser.Transaction {
+	etx := eth_parser.Transaction{
+		Subscriber:           tx.GetSubscriber(),
+		BlockHash:            tx.GetBlockHash(),
+		BlockNumber:          tx.GetBlockNumber(),
+		From:                 tx.GetFrom(),
+		Gas:                  tx.GetGas(),
+		GasPrice:             tx.GetGasPrice(),
+		Hash:                 tx.GetHash(),
+		Input:                tx.GetInput(),
+		Nonce:                tx.GetNonce(),
+		To:                   tx.GetTo(),
+		TransactionIndex:     tx.GetTransactionIndex(),
+		Value:                tx.GetValue(),
+		V:                    tx.GetV(),
+		R:                    tx.GetR(),
+		S:                    tx.GetS(),
+		Timestamp:            tx.GetTimestamp(),
+		Kind:                 eth_parser.EventKind(tx.GetKind()),
+		Status:               eth_parser.TxStatus(tx.GetStatus()),
+		Direction:            fromProtoDirection(tx.GetDirection()),
+		SenderCheck:          eth_parser.SenderCheck(tx.GetSenderCheck()),
+		Type:                 tx.GetType(),
+		ChainID:              tx.GetChainId(),
+		MaxFeePerGas:         tx.GetMaxFeePerGas(),
+		MaxPriorityFeePerGas: tx.GetMaxPriorityFeePerGas(),
+		Fiat:                 tx.GetFiat(),
+		ReplacedBy:           tx.GetReplacedBy(),
+		Chain:                tx.GetChain(),
+		FromLabel:            tx.GetFromLabel(),
+		ToLabel:              tx.GetToLabel(),
+		YParity:              tx.GetYParity(),
+		MaxFeePerBlobGas:     tx.GetMaxFeePerBlobGas(),
+		BlobVersionedHashes:  tx.GetBlobVersionedHashes(),
+	}
+	if r := tx.GetReceipt(); r != nil {
+		etx.Receipt = &eth_parser.Receipt{
+			Status:            r.GetStatus(),
+			GasUsed:           r.GetGasUsed(),
+			EffectiveGasPrice: r.GetEffectiveGasPrice(),
+			BlobGasUsed:       r.GetBlobGasUsed(),
+			BlobGasPrice:      r.GetBlobGasPrice(),
+		}
+	}
+	for _, tuple := range tx.GetAccessList() {
+		etx.AccessList = append(etx.AccessList, eth_parser.AccessTuple{Address: tuple.GetAddress(), StorageKeys: tuple.GetStorageKeys()})
+	}
+	for _, auth := range tx.GetAuthorizationList() {
+		etx.AuthorizationList = append(etx.AuthorizationList, eth_parser.Authorization{
+			ChainID: auth.GetChainId(),
+			Address: auth.GetAddress(),
+			Nonce:   auth.GetNonce(),
+			YParity: auth.GetYParity(),
+			R:       auth.GetR(),
+			S:       auth.GetS(),
+		})
+	}
+	if l := tx.GetLog(); l != nil {
+		etx.Log = &eth_parser.Log{
+			Address:          l.GetAddress(),
+			Topics:           l.GetTopics(),
+			Data:             l.GetData(),
+			LogIndex:         l.GetLogIndex(),
+			BlockNumber:      tx.GetBlockNumber(),
+			BlockHash:        tx.GetBlockHash(),
+			TransactionHash:  tx.GetHash(),
+			TransactionIndex: tx.GetTransactionIndex(),
+		}
+	}
+	if c := tx.GetCall(); c != nil {
+		etx.Call = &eth_parser.Call{Selector: c.GetSelector(), Method: c.GetMethod(), Signature: c.GetSignature()}
+		for _, arg := range c.GetArgs() {
+			etx.Call.Args = append(etx.Call.Args, eth_parser.CallArg{Name: arg.GetName(), Type: arg.GetType(), Value: arg.GetValue()})
+		}
+	}
+	return etx
+}
+
+var directions = map[eth_parser.Direction]pb.Direction{
+	eth_parser.DirectionAny:      pb.Direction_DIRECTION_ANY,
+	eth_parser.DirectionIncoming: pb.Direction_DIRECTION_INCOMING,
+	eth_parser.DirectionOutgoing: pb.Direction_DIRECTION_OUTGOING,
+	eth_parser.DirectionSelf:     pb.Direction_DIRECTION_SELF,
+}
+
+func fromProtoDirection(direction pb.Direction) eth_parser.Direction {
+	for d, protoDirection := range directions {
+		if direction == protoDirection {
+			return d
+		}
+	}
+	return eth_parser.DirectionAny
+}
+
+func toProtoQuery(q eth_parser.TxQuery) *pb.GetTransactionsRequest {
+	req := &pb.GetTransactionsRequest{
+		Address:        q.Address,
+		PageSize:       uint32(q.Limit),
+		PageToken:      q.Cursor,
+		FromBlock:      q.FromBlock,
+		ToBlock:        q.ToBlock,
+		Direction:      directions[q.Direction],
+		Counterparties: q.Counterparties,
+	}
+	if !q.FromTime.IsZero() {
+		req.FromTime = timestamppb.New(q.FromTime)
+	}
+	if !q.ToTime.IsZero() {
+		req.ToTime = timestamppb.New(q.ToTime)
+	}
+	if q.MinValue != nil {
+		req.MinValue = q.MinValue.String()
+	}
+	if q.Order == eth_parser.OrderDescending {
+		req.Order = pb.SortOrder_SORT_ORDER_DESCENDING
+	}
+	return req
+}
+
+func fromProtoQuery(req *pb.GetTransactionsRequest) (eth_parser.TxQuery, error) {
+	q := eth_parser.TxQuery{
+		Address:        req.GetAddress(),
+		Limit:          int(req.GetPageSize()),
+		Cursor:         req.GetPageToken(),
+		FromBlock:      req.GetFromBlock(),
+		ToBlock:        req.GetToBlock(),
+		Counterparties: req.GetCounterparties(),
+		Order:          eth_parser.OrderAscending,
+	}
+	if req.FromTime != nil {
+		q.FromTime = req.GetFromTime().AsTime()
+	}
+	if req.ToTime != nil {
+		q.ToTime = req.GetToTime().AsTime()
+	}
+	q.Direction = fromProtoDirection(req.GetDirection())
+	if req.GetMinValue() != "" {
+		minValue, ok := new(big.Int).SetString(req.GetMinValue(), 10)
+		if !ok {
+			return q, fmt.Errorf("invalid min value %q", req.GetMinValue())
+		}
+		q.MinValue = minValue
+	}
+	if req.GetOrder() == pb.SortOrder_SORT_ORDER_DESCENDING {
+		q.Order = eth_parser.OrderDescending
+	}
+	return q, nil
+}
@@ -0,0 +1,245 @@
+package parser_rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"eth-tx-parser/eth_parser"
+	"eth-tx-parser/eth_parser/test"
+	"eth-tx-parser/parser_rpc/pb"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func setupClient(t *testing.T, parser eth_parser.Parser) *Client {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	gs := grpc.NewServer()
+	NewServer(parser).Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }
+	client, err := Dial(context.Background(), "passthrough:///bufnet",
+		grpc.WithContextDialer(dialer),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(client.Stop)
+	return client
+}
+
+func Test_Client_ImplementsParser(t *testing.T) {
+	var _ eth_parser.Parser = &Client{}
+}
+
+func Test_Client_GetCurrentBlockAndSubscribe(t *testing.T) {
+	client := setupClient(t, &test.ParserMock{ReturnGetCurrentBlock: 42, ReturnSubscribe: true})
+
+	if got := client.GetCurrentBlock(); got != 42 {
+		t.Errorf("GetCurrentBlock() = %d, want 42", got)
+	}
+	if !client.Subscribe("0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5") {
+		t.Errorf("Subscribe() = false, want true")
+	}
+}
+
+func Test_Client_Logger(t *testing.T) {
+	client := setupClient(t, &test.ParserMock{ReturnQueryErr: errors.New("node down")})
+	var logs bytes.Buffer
+	client.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	if txs := client.GetTransactions("0x123"); len(txs) != 0 {
+		t.Errorf("GetTransactions() = %+v, want none", txs)
+	}
+	if got := logs.String(); !strings.Contains(got, `msg="failed to get transactions" address=0x123`) || !strings.Contains(got, "node down") {
+		t.Errorf("expected the failure to be logged with the client's logger, got %q", got)
+	}
+}
+
+func Test_Client_Balance(t *testing.T) {
+	want := eth_parser.BalanceReport{
+		Address: "0x123", Block: 120, AnchorBlock: 100,
+		Tracked: big.NewInt(1e18), Reported: new(big.Int).Lsh(big.NewInt(1e18), 70), MissingFees: 2,
+	}
+	client := setupClient(t, &test.ParserMock{ReturnBalance: want})
+
+	got, err := client.Balance("0x123")
+	if err != nil {
+		t.Fatalf("Balance() error = %v", err)
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b *big.Int) bool { return a.Cmp(b) == 0 })); diff != "" {
+		t.Errorf("Balance() mismatch (-want +got):\n%s", diff)
+	}
+
+	tt := []struct {
+		err  error
+		want codes.Code
+	}{
+		{err: fmt.Errorf("%w \"0x123\"", eth_parser.ErrInvalidAddress), want: codes.InvalidArgument},
+		{err: fmt.Errorf("%w to 0x123", eth_parser.ErrNotSubscribed), want: codes.NotFound},
+		{err: fmt.Errorf("%w, pick one of mainnet, polygon", eth_parser.ErrChainRequired), want: codes.FailedPrecondition},
+		{err: errors.New("failed to fetch the balance of 0x123: connection refused"), want: codes.Unavailable},
+	}
+	for _, tc := range tt {
+		client = setupClient(t, &test.ParserMock{ReturnBalanceErr: tc.err})
+		if _, err := client.Balance("0x123"); status.Code(err) != tc.want {
+			t.Errorf("Balance() with the parser error %q returned %v, want the code %v", tc.err, err, tc.want)
+		}
+	}
+}
+
+func Test_Server_GetTransactionsPaging(t *testing.T) {
+	address := "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5"
+	txs := []eth_parser.Transaction{
+		{Subscriber: address, Hash: "hash1", BlockNumber: "0x1", From: address, To: "0xdef", Value: "0x1"},
+		{Subscriber: address, Hash: "hash2", BlockNumber: "0x2", From: "0xabc", To: address, Value: "0x2"},
+		{Subscriber: address, Hash: "hash3", BlockNumber: "0x3", From: address, To: "0xabc", Value: "0x3"},
+	}
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(address)
+	for _, tx := range txs {
+		storage.AddTransaction(address, tx)
+	}
+	client := setupClient(t, eth_parser.NewEthereumParser(context.Background(), storage))
+
+	resp, err := client.rpc.GetTransactions(context.Background(), &pb.GetTransactionsRequest{Address: address, PageSize: 2})
+	if err != nil {
+		t.Fatalf("GetTransactions() error = %v", err)
+	}
+	if len(resp.GetTransactions()) != 2 || resp.GetNextPageToken() == "" {
+		t.Fatalf("first page = %d txs, token %q, want 2 txs and a token", len(resp.GetTransactions()), resp.GetNextPageToken())
+	}
+
+	resp, err = client.rpc.GetTransactions(context.Background(), &pb.GetTransactionsRequest{Address: address, PageSize: 2, PageToken: resp.GetNextPageToken()})
+	if err != nil {
+		t.Fatalf("GetTransactions() error = %v", err)
+	}
+	if len(resp.GetTransactions()) != 1 || resp.GetNextPageToken() != "" {
+		t.Fatalf("second page = %d txs, token %q, want 1 tx and no token", len(resp.GetTransactions()), resp.GetNextPageToken())
+	}
+
+	// The client walks every page transparently
+	if diff := cmp.Diff(txs, client.GetTransactions(address)); diff != "" {
+		t.Errorf("GetTransactions() mismatch (-want +got):\n%s", diff)
+	}
+
+	// Filters travel through the service
+	page, err := client.QueryTransactions(eth_parser.TxQuery{
+		Address:   address,
+		Direction: eth_parser.DirectionOutgoing,
+		Order:     eth_parser.OrderDescending,
+		MinValue:  big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("QueryTransactions() error = %v", err)
+	}
+	want := []eth_parser.Transaction{txs[2], txs[0]}
+	if diff := cmp.Diff(want, page.Transactions); diff != "" {
+		t.Errorf("QueryTransactions() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_Server_ListenFilter(t *testing.T) {
+	listenChan := make(chan eth_parser.Transaction)
+	client := setupClient(t, &test.ParserMock{ReturnListen: listenChan})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.rpc.Listen(ctx, &pb.ListenRequest{Addresses: []string{"0x456"}})
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	// Give the server time to register the stream before publishing
+	time.Sleep(50 * time.Millisecond)
+	listenChan <- eth_parser.Transaction{Subscriber: "0x123", Hash: "hash1", From: "0x123", To: "0xdef"}
+	listenChan <- eth_parser.Transaction{Subscriber: "0x456", Hash: "hash2", From: "0xabc", To: "0x456"}
+
+	got, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	if got.GetHash() != "hash2" {
+		t.Errorf("received %s, want hash2", got.GetHash())
+	}
+}
+
+func Test_Client_Labels(t *testing.T) {
+	address := "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5"
+	exchange := "0x00000000219ab540356cbb839cbe05303d7705fa"
+	storage := eth_parser.NewMemoryStorage()
+	storage.Subscribe(address)
+	storage.AddTransaction(address, eth_parser.Transaction{Subscriber: address, Hash: "hash1", BlockNumber: "0x1", From: exchange, To: address, Value: "0x1"})
+	storage.AddTransaction(address, eth_parser.Transaction{Subscriber: address, Hash: "hash2", BlockNumber: "0x2", From: address, To: "0xdef", Value: "0x2"})
+	client := setupClient(t, eth_parser.NewEthereumParser(context.Background(), storage))
+
+	if client.SetLabel("0x123", "Broken") {
+		t.Error("SetLabel() of an invalid address = true, want false")
+	}
+	if !client.SetLabel(exchange, "Exchange") {
+		t.Fatal("SetLabel() = false, want true")
+	}
+	want := []eth_parser.AddressLabel{{Address: exchange, Label: "Exchange"}}
+	if diff := cmp.Diff(want, client.ListLabels()); diff != "" {
+		t.Errorf("ListLabels() mismatch (-want +got):\n%s", diff)
+	}
+
+	// Labels and the counterparty filter travel through the service
+	page, err := client.QueryTransactions(eth_parser.TxQuery{Address: address, Counterparties: []string{exchange}})
+	if err != nil {
+		t.Fatalf("QueryTransactions() error = %v", err)
+	}
+	if len(page.Transactions) != 1 || page.Transactions[0].FromLabel != "Exchange" {
+		t.Errorf("QueryTransactions() = %+v, want hash1 from the labelled exchange", page.Transactions)
+	}
+
+	if !client.RemoveLabel(exchange) || client.RemoveLabel(exchange) {
+		t.Error("RemoveLabel() should succeed once")
+	}
+	if labels := client.ListLabels(); len(labels) != 0 {
+		t.Errorf("ListLabels() = %+v, want none", labels)
+	}
+}
+
+func Test_TransactionConversion(t *testing.T) {
+	tx := eth_parser.Transaction{
+		Subscriber:       "0x123",
+		Hash:             "hash1",
+		BlockNumber:      "0x1",
+		From:             "0x123",
+		To:               "0xabc",
+		Value:            "0x1",
+		Type:             "0x4",
+		YParity:          "0x1",
+		MaxFeePerBlobGas: "0x3b9aca00",
+		AccessList: []eth_parser.AccessTuple{
+			{Address: "0xabc", StorageKeys: []string{"0x01", "0x02"}},
+		},
+		BlobVersionedHashes: []string{"0x0100"},
+		AuthorizationList: []eth_parser.Authorization{
+			{ChainID: "0x1", Address: "0xdef", Nonce: "0x2", YParity: "0x0", R: "0xaa", S: "0xbb"},
+		},
+		Receipt: &eth_parser.Receipt{Status: "0x1", GasUsed: "0x5208", EffectiveGasPrice: "0x3b9aca00"},
+	}
+
+	if diff := cmp.Diff(tx, fromProtoTransaction(toProtoTransaction(tx))); diff != "" {
+		t.Errorf("transaction round trip mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,227 @@
+// Package parser_rpc exposes an eth_parser.Parser over gRPC and provides a
+// client that implements eth_parser.Parser on top of a remote server.
+package parser_rpc
+
+//go:generate protoc --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative -I proto parser.proto
+
+import (
+	"context"
+	"errors"
+	"eth-tx-parser/eth_parser"
+	"eth-tx-parser/parser_rpc/pb"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const listenBufferSize = 64
+
+type Server struct {
+	pb.UnimplementedParserServiceServer
+
+	parser eth_parser.Parser
+
+	mu         sync.Mutex
+	listeners  map[chan eth_parser.Transaction]struct{}
+	fanoutOnce sync.Once
+	stopped    bool
+}
+
+func NewServer(p eth_parser.Parser) *Server {
+	return &Server{
+		parser:    p,
+		listeners: make(map[chan eth_parser.Transaction]struct{}),
+	}
+}
+
+// Register attaches the service to a gRPC server.
+func (s *Server) Register(gs *grpc.Server) {
+	pb.RegisterParserServiceServer(gs, s)
+}
+
+func (s *Server) GetCurrentBlock(ctx context.Context, req *pb.GetCurrentBlockRequest) (*pb.GetCurrentBlockResponse, error) {
+	return &pb.GetCurrentBlockResponse{BlockNumber: s.parser.GetCurrentBlock()}, nil
+}
+
+func (s *Server) Subscribe(ctx context.Context, req *pb.SubscribeRequest) (*pb.SubscribeResponse, error) {
+	return &pb.SubscribeResponse{Subscribed: s.parser.AddSubscription(fromProtoSubscribeRequest(req))}, nil
+}
+
+func (s *Server) SubscribeMany(ctx context.Context, req *pb.SubscribeManyRequest) (*pb.SubscribeManyResponse, error) {
+	subs := make([]eth_parser.Subscription, 0, len(req.GetSubscriptions()))
+	for _, sub := range req.GetSubscriptions() {
+		subs = append(subs, fromProtoSubscribeRequest(sub))
+	}
+	return &pb.SubscribeManyResponse{Added: uint32(s.parser.SubscribeMany(subs))}, nil
+}
+
+func (s *Server) ImportTransactions(ctx context.Context, req *pb.ImportTransactionsRequest) (*pb.ImportTransactionsResponse, error) {
+	txs := make([]eth_parser.Transaction, 0, len(req.GetTransactions()))
+	for _, tx := range req.GetTransactions() {
+		txs = append(txs, fromProtoTransaction(tx))
+	}
+	return &pb.ImportTransactionsResponse{Imported: uint32(s.parser.ImportTransactions(txs))}, nil
+}
+
+func (s *Server) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb.GetBalanceResponse, error) {
+	report, err := s.parser.Balance(req.GetAddress())
+	if err != nil {
+		return nil, status.Error(balanceCode(err), err.Error())
+	}
+	return &pb.GetBalanceResponse{
+		Address:     report.Address,
+		Block:       report.Block,
+		AnchorBlock: report.AnchorBlock,
+		Tracked:     report.Tracked.String(),
+		Reported:    report.Reported.String(),
+		MissingFees: uint32(report.MissingFees),
+		Chain:       report.Chain,
+	}, nil
+}
+
+// balanceCode tells the errors of the request from those of the node the
+// balance is fetched from.
+func balanceCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, eth_parser.ErrInvalidAddress):
+		return codes.InvalidArgument
+	case errors.Is(err, eth_parser.ErrNotSubscribed):
+		return codes.NotFound
+	case errors.Is(err, eth_parser.ErrChainRequired):
+		return codes.FailedPrecondition
+	}
+	return codes.Unavailable
+}
+
+func (s *Server) Unsubscribe(ctx context.Context, req *pb.UnsubscribeRequest) (*pb.UnsubscribeResponse, error) {
+	return &pb.UnsubscribeResponse{Unsubscribed: s.parser.Unsubscribe(req.GetAddress(), req.GetPurgeTransactions())}, nil
+}
+
+func (s *Server) ListSubscriptions(ctx context.Context, req *pb.ListSubscriptionsRequest) (*pb.ListSubscriptionsResponse, error) {
+	resp := &pb.ListSubscriptionsResponse{}
+	for _, sub := range s.parser.ListSubscriptions() {
+		resp.Subscriptions = append(resp.Subscriptions, toProtoSubscription(sub))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetTransactions(ctx context.Context, req *pb.GetTransactionsRequest) (*pb.GetTransactionsResponse, error) {
+	q, err := fromProtoQuery(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page, err := s.parser.QueryTransactions(q)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := &pb.GetTransactionsResponse{NextPageToken: page.NextCursor}
+	for _, tx := range page.Transactions {
+		resp.Transactions = append(resp.Transactions, toProtoTransaction(tx))
+	}
+	return resp, nil
+}
+
+func (s *Server) SetLabel(ctx context.Context, req *pb.SetLabelRequest) (*pb.SetLabelResponse, error) {
+	return &pb.SetLabelResponse{Set: s.parser.SetLabel(req.GetAddress(), req.GetLabel())}, nil
+}
+
+func (s *Server) RemoveLabel(ctx context.Context, req *pb.RemoveLabelRequest) (*pb.RemoveLabelResponse, error) {
+	return &pb.RemoveLabelResponse{Removed: s.parser.RemoveLabel(req.GetAddress())}, nil
+}
+
+func (s *Server) ListLabels(ctx context.Context, req *pb.ListLabelsRequest) (*pb.ListLabelsResponse, error) {
+	resp := &pb.ListLabelsResponse{}
+	for _, l := range s.parser.ListLabels() {
+		resp.Labels = append(resp.Labels, &pb.AddressLabel{Address: l.Address, Label: l.Label})
+	}
+	return resp, nil
+}
+
+func (s *Server) Listen(req *pb.ListenRequest, stream pb.ParserService_ListenServer) error {
+	filter := make(map[string]bool, len(req.GetAddresses()))
+	for _, addr := range req.GetAddresses() {
+		filter[strings.ToLower(addr)] = true
+	}
+	direction := fromProtoDirection(req.GetDirection())
+
+	ch, cancel := s.listen()
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case tx, ok := <-ch:
+			if !ok {
+				return status.Error(codes.Unavailable, "parser stopped")
+			}
+			if (len(filter) > 0 && !filter[tx.Subscriber]) || !tx.MatchesDirection(direction) {
+				continue
+			}
+			if err := stream.Send(toProtoTransaction(tx)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// listen returns a live feed for a Listen stream, of its own when the parser
+// broadcasts its records, so the local CLI keeps getting all of them too.
+func (s *Server) listen() (<-chan eth_parser.Transaction, func()) {
+	if b, ok := s.parser.(eth_parser.Broadcaster); ok {
+		return b.NewListener()
+	}
+	ch := s.addListener()
+	return ch, func() { s.removeListener(ch) }
+}
+
+// Other parsers expose a single live feed, so it is read once and fanned out
+// to every open Listen stream.
+func (s *Server) fanout() {
+	for tx := range s.parser.Listen() {
+		s.mu.Lock()
+		for ch := range s.listeners {
+			select {
+			case ch <- tx:
+			default: // Skip slow listeners
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	for ch := range s.listeners {
+		close(ch)
+		delete(s.listeners, ch)
+	}
+}
+
+func (s *Server) addListener() chan eth_parser.Transaction {
+	s.fanoutOnce.Do(func() { go s.fanout() })
+
+	ch := make(chan eth_parser.Transaction, listenBufferSize)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		close(ch)
+		return ch
+	}
+	s.listeners[ch] = struct{}{}
+	return ch
+}
+
+func (s *Server) removeListener(ch chan eth_parser.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.listeners[ch]; exists {
+		delete(s.listeners, ch)
+		close(ch)
+	}
+}